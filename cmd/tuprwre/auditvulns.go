@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/discovery"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/vuln"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditVulnsImport string
+	auditVulnsFailOn string
+	auditVulnsJSON   bool
+)
+
+var auditVulnsCmd = &cobra.Command{
+	Use:   "audit-vulns",
+	Short: "Match installed packages against the local advisory database",
+	Long: `Checks the SBOM of every shim's image against OSV advisories stored in
+~/.tuprwre/advisories. Nothing is fetched from the network: load advisories
+first with --import, from an OSV JSON file or a zip of them such as
+https://osv-vulnerabilities.storage.googleapis.com/<ecosystem>/all.zip.`,
+	Example: `  tuprwre audit-vulns --import ./Debian-all.zip
+  tuprwre audit-vulns
+  tuprwre audit-vulns --fail-on high --json`,
+	Args: cobra.NoArgs,
+	RunE: runAuditVulns,
+}
+
+func init() {
+	auditVulnsCmd.Flags().StringVar(&auditVulnsImport, "import", "", "Import OSV advisories from a JSON file or zip archive, then exit")
+	auditVulnsCmd.Flags().StringVar(&auditVulnsFailOn, "fail-on", "", "Exit non-zero when a finding is at least this severe (low|medium|high|critical)")
+	auditVulnsCmd.Flags().BoolVar(&auditVulnsJSON, "json", false, "Emit machine-readable JSON output")
+}
+
+// imageFindings are the findings for one image and the shims it backs.
+type imageFindings struct {
+	Image    string         `json:"image"`
+	Shims    []string       `json:"shims"`
+	Findings []vuln.Finding `json:"findings"`
+}
+
+func runAuditVulns(cmd *cobra.Command, _ []string) error {
+	var failOn vuln.Severity
+	if auditVulnsFailOn != "" {
+		var err error
+		if failOn, err = vuln.ParseSeverity(auditVulnsFailOn); err != nil {
+			return fmt.Errorf("invalid --fail-on: %w", err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	out := cmd.OutOrStdout()
+
+	if auditVulnsImport != "" {
+		n, err := vuln.Import(advisoryDir(cfg), auditVulnsImport)
+		if err != nil {
+			return fmt.Errorf("failed to import advisories: %w", err)
+		}
+		_, _ = fmt.Fprintf(out, "Imported %d advisories into %s\n", n, advisoryDir(cfg))
+		return nil
+	}
+
+	db, err := vuln.Open(advisoryDir(cfg))
+	if err != nil {
+		return err
+	}
+	if db.Len() == 0 {
+		return fmt.Errorf("advisory database %s is empty; load it with 'tuprwre audit-vulns --import <file>'", advisoryDir(cfg))
+	}
+
+	results, err := auditShimImages(cfg, db, true)
+	if err != nil {
+		return err
+	}
+
+	worst := vuln.SeverityUnknown
+	total := 0
+	for _, r := range results {
+		for _, f := range r.Findings {
+			total++
+			if f.Severity > worst {
+				worst = f.Severity
+			}
+		}
+	}
+
+	if auditVulnsJSON {
+		if results == nil {
+			results = []imageFindings{}
+		}
+		payload, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode findings: %w", err)
+		}
+		_, _ = out.Write(payload)
+		_, _ = fmt.Fprintln(out)
+	} else {
+		if total > 0 {
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "IMAGE\tPACKAGE\tVERSION\tADVISORY\tSEVERITY\tFIXED")
+			for _, r := range results {
+				for _, f := range r.Findings {
+					_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Image, f.Package.Name, f.Package.Version, f.ID, f.Severity, f.Fixed)
+				}
+			}
+			_ = tw.Flush()
+		}
+		_, _ = fmt.Fprintf(out, "%d finding(s) across %d image(s) (%d advisories loaded)\n", total, len(results), db.Len())
+	}
+
+	if failOn != vuln.SeverityUnknown && total > 0 && worst >= failOn {
+		return fmt.Errorf("found vulnerabilities at or above %s", failOn)
+	}
+	return nil
+}
+
+// parseInstallFailOn parses install's --fail-on. The threshold is useless without
+// advisories, so an empty database is an error before anything is built.
+func parseInstallFailOn(cfg *config.Config, value string) (vuln.Severity, error) {
+	if value == "" {
+		return vuln.SeverityUnknown, nil
+	}
+	failOn, err := vuln.ParseSeverity(value)
+	if err != nil {
+		return vuln.SeverityUnknown, fmt.Errorf("invalid --fail-on: %w", err)
+	}
+	db, err := vuln.Open(advisoryDir(cfg))
+	if err != nil {
+		return vuln.SeverityUnknown, err
+	}
+	if db.Len() == 0 {
+		return vuln.SeverityUnknown, fmt.Errorf("--fail-on needs an advisory database; load one with 'tuprwre audit-vulns --import <file>'")
+	}
+	return failOn, nil
+}
+
+// advisoryDir is where imported OSV advisories are stored.
+func advisoryDir(cfg *config.Config) string {
+	return filepath.Join(cfg.BaseDir, vuln.DirName)
+}
+
+// auditShimImages matches the SBOM of every image behind a shim, in every
+// scope, against db. Images without a recorded SBOM are scanned when scan is
+// set and skipped otherwise.
+func auditShimImages(cfg *config.Config, db *vuln.DB, scan bool) ([]imageFindings, error) {
+	type imageShims struct {
+		gen   *shim.Generator
+		shims []string
+	}
+	images := map[string]*imageShims{}
+	for _, gen := range shim.Generators(cfg) {
+		all, err := gen.ListAllMetadata()
+		if err != nil {
+			return nil, err
+		}
+		for _, meta := range all {
+			entry, ok := images[meta.OutputImage]
+			if !ok {
+				entry = &imageShims{gen: gen}
+				images[meta.OutputImage] = entry
+			}
+			if entry.gen == gen {
+				entry.shims = append(entry.shims, meta.BinaryName)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(images))
+	for image := range images {
+		names = append(names, image)
+	}
+	sort.Strings(names)
+
+	var results []imageFindings
+	for _, image := range names {
+		entry := images[image]
+		sort.Strings(entry.shims)
+		s, ok, err := imageSBOM(cfg, entry.gen, entry.shims, scan)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		results = append(results, imageFindings{Image: image, Shims: entry.shims, Findings: db.Match(s)})
+	}
+	return results, nil
+}
+
+// imageSBOM returns the SBOM recorded for any of shims, which share one
+// image, scanning the image when none has one and scan is set.
+func imageSBOM(cfg *config.Config, gen *shim.Generator, shims []string, scan bool) (sbom.SBOM, bool, error) {
+	for _, name := range shims {
+		s, err := gen.LoadSBOM(name)
+		if err == nil {
+			return s, true, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return sbom.SBOM{}, false, err
+		}
+	}
+	if !scan || len(shims) == 0 {
+		return sbom.SBOM{}, false, nil
+	}
+
+	s, err := scanShimSBOM(cfg, gen, shims[0])
+	if err != nil {
+		return sbom.SBOM{}, false, err
+	}
+	for _, name := range shims[1:] {
+		if err := gen.SaveSBOM(name, s); err != nil {
+			return sbom.SBOM{}, false, err
+		}
+	}
+	return s, true, nil
+}
+
+// scanToolset scans image and checks it against the advisory database.
+// Findings at or above failOn refuse the toolset; any others are reported
+// as warnings. A failed scan only costs the SBOM, so it returns nil and no
+// error.
+func scanToolset(ctx context.Context, out io.Writer, cfg *config.Config, docker *sandbox.DockerRuntime, image string, failOn vuln.Severity) (*sbom.SBOM, error) {
+	s, err := discovery.New(cfg, docker).GenerateSBOM(ctx, image)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: failed to generate SBOM for %s: %v\n", image, err)
+		return nil, nil
+	}
+
+	db, err := vuln.Open(advisoryDir(cfg))
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: %v\n", err)
+		return &s, nil
+	}
+	if db.Len() == 0 {
+		return &s, nil
+	}
+
+	refuse := false
+	for _, f := range db.Match(s) {
+		label := "Warning"
+		if failOn != vuln.SeverityUnknown && f.Severity >= failOn {
+			label, refuse = "Error", true
+		}
+		fixed := ""
+		if f.Fixed != "" {
+			fixed = ", fixed in " + f.Fixed
+		}
+		_, _ = fmt.Fprintf(out, "%s: %s %s is affected by %s (%s%s)\n", label, f.Package.Name, f.Package.Version, f.ID, f.Severity, fixed)
+	}
+	if refuse {
+		return nil, fmt.Errorf("%s contains packages with %s or worse vulnerabilities; no shims were created", image, failOn)
+	}
+	return &s, nil
+}
+
+// saveToolsetSBOM stores s for each of shims. s is nil when the scan failed.
+func saveToolsetSBOM(out io.Writer, shimGen *shim.Generator, s *sbom.SBOM, shims []string) {
+	if s == nil || len(shims) == 0 {
+		return
+	}
+	for _, name := range shims {
+		if err := shimGen.SaveSBOM(name, *s); err != nil {
+			_, _ = fmt.Fprintf(out, "Warning: failed to store SBOM for %s: %v\n", name, err)
+		}
+	}
+	_, _ = fmt.Fprintf(out, "Recorded SBOM with %d package(s)\n", len(s.Packages))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/spf13/cobra"
+)
+
+const jqAdvisory = `{
+  "id": "DEBIAN-CVE-2023-0001",
+  "affected": [{
+    "package": {"ecosystem": "Ubuntu:22.04:LTS", "name": "jq"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-2.1ubuntu3.1"}]}]
+  }],
+  "database_specific": {"severity": "medium"}
+}`
+
+func TestRunAuditVulns(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	for _, name := range []string{"jq", "curl"} {
+		image := "tuprwre-jq:1"
+		if name == "curl" {
+			image = "tuprwre-curl:1"
+		}
+		if err := gen.SaveMetadata(shim.Metadata{BinaryName: name, OutputImage: image}); err != nil {
+			t.Fatalf("seed metadata: %v", err)
+		}
+		if err := gen.SaveSBOM(name, sbom.SBOM{
+			Image:    image,
+			Distro:   "ubuntu",
+			Packages: []sbom.Package{{Type: sbom.TypeDeb, Name: name, Version: "1.6-2.1ubuntu3"}},
+		}); err != nil {
+			t.Fatalf("seed SBOM: %v", err)
+		}
+	}
+
+	origImport, origFailOn, origJSON := auditVulnsImport, auditVulnsFailOn, auditVulnsJSON
+	t.Cleanup(func() { auditVulnsImport, auditVulnsFailOn, auditVulnsJSON = origImport, origFailOn, origJSON })
+	auditVulnsFailOn, auditVulnsJSON = "", false
+
+	auditVulnsImport = ""
+	if err := runAuditVulns(&cobra.Command{}, nil); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("expected an empty-database error, got %v", err)
+	}
+	if check := doctorCheckVulnerabilities(cfg); check.Status != doctorStatusPass {
+		t.Fatalf("doctor check without database = %+v, want PASS", check)
+	}
+
+	advisories := filepath.Join(t.TempDir(), "osv.json")
+	if err := os.WriteFile(advisories, []byte(jqAdvisory), 0o644); err != nil {
+		t.Fatalf("write advisories: %v", err)
+	}
+	auditVulnsImport = advisories
+	out := &bytes.Buffer{}
+	cmd := &cobra.Command{}
+	cmd.SetOut(out)
+	if err := runAuditVulns(cmd, nil); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Imported 1 advisories") {
+		t.Fatalf("unexpected import output: %q", out.String())
+	}
+
+	auditVulnsImport = ""
+	out.Reset()
+	if err := runAuditVulns(cmd, nil); err != nil {
+		t.Fatalf("audit failed: %v", err)
+	}
+	for _, want := range []string{"tuprwre-jq:1", "DEBIAN-CVE-2023-0001", "medium", "1.6-2.1ubuntu3.1", "1 finding(s) across 2 image(s)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("audit output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "tuprwre-curl:1  curl") {
+		t.Fatalf("curl reported without an advisory:\n%s", out.String())
+	}
+
+	auditVulnsFailOn = "high"
+	if err := runAuditVulns(cmd, nil); err != nil {
+		t.Fatalf("--fail-on high failed on a medium finding: %v", err)
+	}
+	auditVulnsFailOn = "medium"
+	if err := runAuditVulns(cmd, nil); err == nil {
+		t.Fatal("expected --fail-on medium to fail")
+	}
+
+	if check := doctorCheckVulnerabilities(cfg); check.Status != doctorStatusFail || check.Critical || !strings.Contains(check.Message, "tuprwre-jq:1") {
+		t.Fatalf("doctor check = %+v, want a non-critical FAIL naming tuprwre-jq:1", check)
+	}
+}
+
+func TestParseInstallFailOn(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if _, err := parseInstallFailOn(cfg, "high"); err == nil || !strings.Contains(err.Error(), "advisory database") {
+		t.Fatalf("expected an error without an advisory database, got %v", err)
+	}
+	if _, err := parseInstallFailOn(cfg, "severe"); err == nil {
+		t.Fatal("expected an unknown severity to fail")
+	}
+	if failOn, err := parseInstallFailOn(cfg, ""); err != nil || failOn != 0 {
+		t.Fatalf("empty --fail-on = %v, %v", failOn, err)
+	}
+}
@@ -110,6 +110,10 @@ func runClean(cmd *cobra.Command, _ []string) error {
 	removedCount := 0
 	failedCount := 0
 	for _, image := range images {
+		refs := []string{image.ID, image.Repository + ":" + image.Tag}
+		if _, err := docker.EvictPoolImages(ctx, refs, true); err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to evict warm containers for %s:%s: %v\n", image.Repository, image.Tag, err)
+		}
 		if err := docker.RemoveImage(ctx, image.ID); err != nil {
 			failedCount++
 			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to remove image %s:%s: %v\n", image.Repository, image.Tag, err)
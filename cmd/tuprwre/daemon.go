@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/daemon"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonSocket        string
+	daemonGCInterval    time.Duration
+	daemonPrewarmWindow time.Duration
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a long-lived pool daemon that serves shim invocations",
+	Long: `Runs in the foreground, owning the Docker client and the warm container pool.
+tuprwre run hands invocations to the daemon over a local unix socket (stdio is
+passed as file descriptors), skipping the per-invocation Docker connect, ping
+and pool lookup. When the daemon is not running, tuprwre run falls back to
+executing in-process.
+
+The daemon also runs pool GC on a timer and keeps containers warm for pool keys
+used within --prewarm-window.
+
+Set TUPRWRE_NO_DAEMON=1 to make tuprwre run ignore a running daemon.`,
+	Example: `  # Start the daemon in the background
+  tuprwre daemon &
+
+  # Custom socket path (run must see the same TUPRWRE_DAEMON_SOCKET)
+  TUPRWRE_DAEMON_SOCKET=/tmp/tuprwre.sock tuprwre daemon`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonSocket, "socket", "", "Unix socket path (default: $TUPRWRE_DAEMON_SOCKET or ~/.tuprwre/daemon.sock)")
+	daemonCmd.Flags().DurationVar(&daemonGCInterval, "gc-interval", time.Minute, "How often to run pool GC and pre-warming")
+	daemonCmd.Flags().DurationVar(&daemonPrewarmWindow, "prewarm-window", 30*time.Minute, "Keep pre-warming pool keys used within this window (0 disables)")
+}
+
+func runDaemon(cmd *cobra.Command, _ []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.WarmPoolEnabled {
+		return fmt.Errorf("warm pool is disabled; enable warm_pool to use the daemon")
+	}
+
+	socketPath := daemonSocket
+	if socketPath == "" {
+		socketPath = cfg.DaemonSocket
+	}
+
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
+	sb := sandbox.New(cfg)
+	defer sb.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Connect to Docker up front so a broken daemon setup fails fast instead
+	// of on the first shim invocation.
+	if _, err := sb.PoolGC(ctx); err != nil {
+		return err
+	}
+
+	stderr := cmd.ErrOrStderr()
+	server := daemon.NewServer(sb, daemon.ServerConfig{
+		SocketPath:    socketPath,
+		GCInterval:    daemonGCInterval,
+		PrewarmWindow: daemonPrewarmWindow,
+		Logf: func(format string, args ...any) {
+			_, _ = fmt.Fprintf(stderr, "[tuprwre][daemon] "+format+"\n", args...)
+		},
+	})
+	return server.Serve(ctx)
+}
@@ -7,12 +7,17 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/docker/go-units"
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/vuln"
 )
 
 const (
@@ -34,7 +39,8 @@ var doctorCmd = &cobra.Command{
 	Long: `Verifies critical runtime and environment assumptions required by tuprwre.
 
 Checks include binary discovery, version command sanity, runtime config validity,
-shim directory visibility, Docker daemon reachability, and writable state dirs.`,
+shim directory visibility, Docker daemon reachability, writable state dirs,
+known vulnerabilities in recorded SBOMs, and credentials left in shim metadata.`,
 	RunE: runDoctor,
 }
 
@@ -88,6 +94,9 @@ func runDoctor(cmd *cobra.Command, _ []string) error {
 		}
 
 		addDoctorCheck(doctorCheckResourceDefaults(cfg))
+		addDoctorCheck(doctorCheckHardening(cfg))
+		addDoctorCheck(doctorCheckVulnerabilities(cfg))
+		addDoctorCheck(doctorCheckMetadataRedaction(cfg))
 	}
 
 	criticalFailures := 0
@@ -420,3 +429,146 @@ func isPathEntry(target string, pathEnv string) bool {
 	}
 	return false
 }
+
+// doctorCheckHardening resolves the configured hardening profile so a bad
+// custom profile (e.g. a missing seccomp file) shows up before a run fails.
+func doctorCheckHardening(cfg *config.Config) doctorCheck {
+	p, err := hardening.Resolve(cfg, "")
+	if err != nil {
+		return doctorCheck{
+			Name:     "Hardening profile",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  err.Error(),
+		}
+	}
+
+	parts := []string{p.Name}
+	if len(p.CapDrop) > 0 {
+		parts = append(parts, "cap-drop="+strings.Join(p.CapDrop, ","))
+	}
+	if len(p.CapAdd) > 0 {
+		parts = append(parts, "cap-add="+strings.Join(p.CapAdd, ","))
+	}
+	if p.NoNewPrivileges {
+		parts = append(parts, "no-new-privileges")
+	}
+	if p.Seccomp != "" {
+		parts = append(parts, "custom seccomp")
+	}
+	if p.PidsLimit > 0 {
+		parts = append(parts, fmt.Sprintf("pids-limit=%d", p.PidsLimit))
+	}
+	if p.Nofile > 0 {
+		parts = append(parts, fmt.Sprintf("nofile=%d", p.Nofile))
+	}
+	if p.Nproc > 0 {
+		parts = append(parts, fmt.Sprintf("nproc=%d", p.Nproc))
+	}
+	parts = append(parts, "tmpfs="+p.TmpfsSize)
+	return doctorCheck{
+		Name:     "Hardening profile",
+		Status:   doctorStatusPass,
+		Critical: false,
+		Message:  strings.Join(parts, " "),
+	}
+}
+
+// doctorCheckVulnerabilities matches recorded SBOMs against the advisory
+// database. It never scans images, so it stays fast and works offline.
+func doctorCheckVulnerabilities(cfg *config.Config) doctorCheck {
+	db, err := vuln.Open(advisoryDir(cfg))
+	if err != nil {
+		return doctorCheck{
+			Name:     "Known vulnerabilities",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  err.Error(),
+		}
+	}
+	if db.Len() == 0 {
+		return doctorCheck{
+			Name:     "Known vulnerabilities",
+			Status:   doctorStatusPass,
+			Critical: false,
+			Message:  "no advisory database (import one with 'tuprwre audit-vulns --import')",
+		}
+	}
+
+	results, err := auditShimImages(cfg, db, false)
+	if err != nil {
+		return doctorCheck{
+			Name:     "Known vulnerabilities",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  fmt.Sprintf("failed to audit shims: %v", err),
+		}
+	}
+
+	var affected []string
+	total := 0
+	for _, r := range results {
+		if len(r.Findings) > 0 {
+			total += len(r.Findings)
+			affected = append(affected, r.Image)
+		}
+	}
+	if total > 0 {
+		return doctorCheck{
+			Name:     "Known vulnerabilities",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  fmt.Sprintf("%d finding(s) in %s — run 'tuprwre audit-vulns' for details", total, strings.Join(affected, ", ")),
+		}
+	}
+	return doctorCheck{
+		Name:     "Known vulnerabilities",
+		Status:   doctorStatusPass,
+		Critical: false,
+		Message:  fmt.Sprintf("no known vulnerabilities in %d image(s) (%d advisories loaded)", len(results), db.Len()),
+	}
+}
+
+// doctorCheckMetadataRedaction flags stored metadata that still carries
+// credentials: installs from before redaction, and install steps or script
+// arguments, which are stored verbatim.
+func doctorCheckMetadataRedaction(cfg *config.Config) doctorCheck {
+	redactor, err := redact.New(cfg.RedactPatterns)
+	if err != nil {
+		return doctorCheck{
+			Name:     "Stored credentials",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  err.Error(),
+		}
+	}
+
+	var exposed []string
+	for _, gen := range shim.Generators(cfg) {
+		metas, err := gen.ListAllMetadata()
+		if err != nil {
+			continue
+		}
+		for _, meta := range metas {
+			fields := append([]string{meta.InstallCommand}, meta.InstallSteps...)
+			fields = append(fields, meta.InstallScriptArgs...)
+			if !slices.Equal(redactor.Strings(fields), fields) {
+				exposed = append(exposed, meta.BinaryName)
+			}
+		}
+	}
+	if len(exposed) > 0 {
+		return doctorCheck{
+			Name:     "Stored credentials",
+			Status:   doctorStatusFail,
+			Critical: false,
+			Message:  fmt.Sprintf("metadata for %s contains credentials — reinstall passing them with --secret", strings.Join(exposed, ", ")),
+		}
+	}
+	return doctorCheck{
+		Name:     "Stored credentials",
+		Status:   doctorStatusPass,
+		Critical: false,
+		Message:  "no credentials found in shim metadata",
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var envShell string
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print shell commands that put tuprwre shims on PATH",
+	Long: `Prints shell-appropriate export lines that place the global tuprwre
+shim directory on PATH. The output is meant for shell startup files, which
+run once in whatever directory the shell starts in, so workspace-scoped
+shims are left out; 'tuprwre shell' adds them for the workspace it starts
+in.
+
+The shell is detected from $SHELL unless --shell is given. Supported shells
+are bash, zsh, fish and sh (POSIX).`,
+	Example: `  # bash / zsh (~/.bashrc, ~/.zshrc)
+  eval "$(tuprwre env)"
+
+  # fish (~/.config/fish/config.fish)
+  tuprwre env --shell fish | source`,
+	Args: cobra.NoArgs,
+	RunE: runEnvCommand,
+}
+
+func init() {
+	envCmd.Flags().StringVar(&envShell, "shell", "", "Shell syntax to emit (bash|zsh|fish|sh; default: detected from $SHELL)")
+}
+
+func runEnvCommand(cmd *cobra.Command, _ []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	shellName := envShell
+	if shellName == "" {
+		shellName = detectEnvShell(os.Getenv("SHELL"))
+	}
+
+	lines, err := formatEnvExports(shellName, []string{cfg.ShimDir})
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// detectEnvShell maps a $SHELL value to one of the supported shell names,
+// falling back to POSIX sh.
+func detectEnvShell(shellPath string) string {
+	switch name := filepath.Base(shellPath); name {
+	case "bash", "zsh", "fish":
+		return name
+	default:
+		return "sh"
+	}
+}
+
+// formatEnvExports renders the PATH setup for shellName with dirs prepended
+// in order.
+func formatEnvExports(shellName string, dirs []string) ([]string, error) {
+	switch strings.ToLower(strings.TrimSpace(shellName)) {
+	case "bash", "zsh", "sh":
+		quoted := make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+			quoted = append(quoted, posixDoubleQuoteEscape(dir))
+		}
+		path := strings.Join(append(quoted, "$PATH"), string(os.PathListSeparator))
+		return []string{fmt.Sprintf("export PATH=\"%s\"", path)}, nil
+	case "fish":
+		quoted := make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+			quoted = append(quoted, fishSingleQuote(dir))
+		}
+		return []string{fmt.Sprintf("set -gx PATH %s $PATH", strings.Join(quoted, " "))}, nil
+	default:
+		return nil, fmt.Errorf("shell %q is not supported (supported: bash, zsh, fish, sh)", shellName)
+	}
+}
+
+func posixDoubleQuoteEscape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return replacer.Replace(value)
+}
+
+func fishSingleQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + replacer.Replace(value) + "'"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFormatEnvExports(t *testing.T) {
+	dirs := []string{"/ws/.tuprwre/bin", "/home/u/.tuprwre/bin"}
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{shell: "bash", want: `export PATH="/ws/.tuprwre/bin:/home/u/.tuprwre/bin:$PATH"`},
+		{shell: "zsh", want: `export PATH="/ws/.tuprwre/bin:/home/u/.tuprwre/bin:$PATH"`},
+		{shell: "sh", want: `export PATH="/ws/.tuprwre/bin:/home/u/.tuprwre/bin:$PATH"`},
+		{shell: "fish", want: `set -gx PATH '/ws/.tuprwre/bin' '/home/u/.tuprwre/bin' $PATH`},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.shell, func(t *testing.T) {
+			lines, err := formatEnvExports(tc.shell, dirs)
+			if err != nil {
+				t.Fatalf("formatEnvExports: %v", err)
+			}
+			if len(lines) != 1 || lines[0] != tc.want {
+				t.Fatalf("unexpected output: got=%q want=%q", lines, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatEnvExportsEscapesSpecialCharacters(t *testing.T) {
+	lines, err := formatEnvExports("bash", []string{`/tmp/a "b" $c`})
+	if err != nil {
+		t.Fatalf("formatEnvExports: %v", err)
+	}
+	if want := `export PATH="/tmp/a \"b\" \$c:$PATH"`; lines[0] != want {
+		t.Fatalf("unexpected bash escaping: got=%q want=%q", lines[0], want)
+	}
+
+	lines, err = formatEnvExports("fish", []string{`/tmp/it's`})
+	if err != nil {
+		t.Fatalf("formatEnvExports: %v", err)
+	}
+	if want := `set -gx PATH '/tmp/it\'s' $PATH`; lines[0] != want {
+		t.Fatalf("unexpected fish escaping: got=%q want=%q", lines[0], want)
+	}
+}
+
+func TestFormatEnvExportsRejectsUnknownShell(t *testing.T) {
+	if _, err := formatEnvExports("powershell", []string{"/x"}); err == nil {
+		t.Fatal("expected error for unsupported shell")
+	}
+}
+
+func TestDetectEnvShell(t *testing.T) {
+	cases := map[string]string{
+		"/bin/bash":          "bash",
+		"/usr/local/bin/zsh": "zsh",
+		"/usr/bin/fish":      "fish",
+		"/bin/dash":          "sh",
+		"":                   "sh",
+	}
+	for input, want := range cases {
+		if got := detectEnvShell(input); got != want {
+			t.Fatalf("detectEnvShell(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRunEnvCommandPrintsGlobalShimDir(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("TUPRWRE_DIR", tempHome)
+
+	prevShell := envShell
+	envShell = "bash"
+	t.Cleanup(func() { envShell = prevShell })
+
+	out := &bytes.Buffer{}
+	cmd := &cobra.Command{}
+	cmd.SetOut(out)
+
+	if err := runEnvCommand(cmd, nil); err != nil {
+		t.Fatalf("runEnvCommand: %v", err)
+	}
+
+	want := filepath.Join(tempHome, "bin") + ":$PATH"
+	if !strings.Contains(out.String(), want) {
+		t.Fatalf("expected %q in output, got %q", want, out.String())
+	}
+}
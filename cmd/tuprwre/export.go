@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/toolset"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportArchive    string
+	exportDockerfile string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <shim|image>",
+	Short: "Export an installed toolset as a Dockerfile and optional image archive",
+	Long: `Reconstructs a Dockerfile for the image behind a shim (or an image name)
+from install metadata. With --archive, also writes a tarball holding the
+saved image, the shim metadata and the Dockerfile, which 'tuprwre import'
+turns back into shims on another machine.`,
+	Example: `  # Print a Dockerfile that reproduces the jq install
+  tuprwre export jq
+
+  # Bundle the image and shims for a teammate
+  tuprwre export jq --archive jq-toolset.tar`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportArchive, "archive", "", "Write a toolset archive (image + metadata + Dockerfile) to this path")
+	exportCmd.Flags().StringVar(&exportDockerfile, "dockerfile", "", "Write the Dockerfile to this path instead of stdout")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	_, manifest, err := toolset.Find(shim.Generators(cfg), args[0])
+	if err != nil {
+		return err
+	}
+	dockerfile, err := reconstructDockerfile(manifest.Shims[0])
+	if err != nil {
+		return err
+	}
+	if meta := manifest.Shims[0]; meta.InstallDockerfile == "" &&
+		(meta.InstallCommandHash != "" || meta.InstallStepsHash != "" || meta.InstallScriptArgsHash != "") {
+		// stderr, since the Dockerfile itself may be going to stdout.
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: the install source of %s was stored redacted; replace the %s placeholders in the Dockerfile before building it\n", args[0], redact.Mask)
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case exportDockerfile != "":
+		if err := os.WriteFile(exportDockerfile, []byte(dockerfile), 0o644); err != nil {
+			return fmt.Errorf("failed to write Dockerfile: %w", err)
+		}
+	case exportArchive == "":
+		_, _ = fmt.Fprint(out, dockerfile)
+	}
+	if exportArchive == "" {
+		return nil
+	}
+
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+	ctx := context.Background()
+
+	manifest.ImageID, err = docker.ImageID(ctx, manifest.Image)
+	if err != nil {
+		return err
+	}
+	imageTar, err := os.CreateTemp("", "tuprwre-export-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(imageTar.Name())
+	err = docker.SaveImage(ctx, manifest.Image, imageTar)
+	if closeErr := imageTar.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to save image %s: %w", manifest.Image, closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	archive, err := os.Create(exportArchive)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	err = toolset.WriteArchive(archive, manifest, dockerfile, imageTar.Name())
+	if closeErr := archive.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write archive: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(exportArchive)
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Exported %s (%d shim(s)) to %s\n", manifest.Image, len(manifest.Shims), exportArchive)
+	return nil
+}
+
+// reconstructDockerfile renders a Dockerfile that reproduces the install
+// recorded in meta.
+func reconstructDockerfile(meta shim.Metadata) (string, error) {
+	if meta.InstallDockerfile != "" {
+		content, err := os.ReadFile(meta.InstallDockerfile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Dockerfile %s: %w", meta.InstallDockerfile, err)
+		}
+		return string(content), nil
+	}
+
+	if meta.BaseImage == "" {
+		return "", fmt.Errorf("metadata for shim %q is missing the base image", meta.BinaryName)
+	}
+
+	commands := append([]string(nil), meta.InstallSteps...)
+	switch {
+	case meta.InstallScriptPath != "":
+		content, err := os.ReadFile(meta.InstallScriptPath)
+		if err != nil {
+			return "", fmt.Errorf("stored script for shim %q is unavailable: %w", meta.BinaryName, err)
+		}
+		commands = append(commands, buildScriptInstallCommand(content, meta.InstallScriptArgs))
+	case meta.InstallCommand != "":
+		commands = append(commands, meta.InstallCommand)
+	}
+	if len(commands) == 0 {
+		return "", fmt.Errorf("metadata for shim %q is missing install source", meta.BinaryName)
+	}
+	return toolset.Dockerfile(meta.BaseImage, commands)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/toolset"
+	"github.com/spf13/cobra"
+)
+
+func TestRunExportPrintsDockerfile(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	if err := gen.SaveMetadata(shim.Metadata{
+		BinaryName:     "jq",
+		InstallMode:    "steps",
+		InstallSteps:   []string{"apt-get update"},
+		InstallCommand: "apt-get install -y jq",
+		BaseImage:      "ubuntu:22.04",
+		OutputImage:    "tuprwre-jq:1",
+	}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+
+	origArchive, origDockerfile := exportArchive, exportDockerfile
+	t.Cleanup(func() { exportArchive, exportDockerfile = origArchive, origDockerfile })
+	exportArchive, exportDockerfile = "", ""
+
+	out := &bytes.Buffer{}
+	cmd := &cobra.Command{}
+	cmd.SetOut(out)
+	if err := runExport(cmd, []string{"jq"}); err != nil {
+		t.Fatalf("runExport failed: %v", err)
+	}
+
+	want := "FROM ubuntu:22.04\n" +
+		`RUN ["sh","-c","apt-get update"]` + "\n" +
+		`RUN ["sh","-c","apt-get install -y jq"]` + "\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Fatalf("unexpected Dockerfile:\n%s", out.String())
+	}
+}
+
+func TestRestoreToolsetShims(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+
+	m := toolset.Manifest{
+		Version: toolset.ManifestVersion,
+		Image:   "tuprwre-node:1",
+		Shims: []shim.Metadata{
+			{BinaryName: "node", InstallCommand: "echo install", BaseImage: "ubuntu:22.04", Workspace: "/elsewhere"},
+			{BinaryName: "../evil"},
+		},
+	}
+	out := &bytes.Buffer{}
+	if _, err := restoreToolsetShims(out, gen, cfg, m, false, nil); err != nil {
+		t.Fatalf("restoreToolsetShims failed: %v", err)
+	}
+
+	if _, err := os.Stat(gen.GetPath("node")); err != nil {
+		t.Fatalf("expected node shim: %v", err)
+	}
+	meta, err := gen.LoadMetadata("node")
+	if err != nil {
+		t.Fatalf("load metadata: %v", err)
+	}
+	if meta.OutputImage != "tuprwre-node:1" || meta.Workspace != "" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if !strings.Contains(out.String(), `skipping invalid shim name "../evil"`) {
+		t.Fatalf("expected invalid name warning, got %q", out.String())
+	}
+}
+
+func TestRestoreToolsetShimsWarnsOnDroppedSignature(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	// Exported before redaction: the signature covers the raw command.
+	meta := shim.Metadata{BinaryName: "tool", InstallCommand: "GITHUB_TOKEN=ghp_1234 ./install.sh", BaseImage: "alpine:3.19"}
+	if err := signMetadata(key, &meta, "sha256:aaa"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	m := toolset.Manifest{Version: toolset.ManifestVersion, Image: "tuprwre-tool:1", Shims: []shim.Metadata{meta}}
+
+	out := &bytes.Buffer{}
+	saved, err := restoreToolsetShims(out, shim.NewGenerator(cfg), cfg, m, false, nil)
+	if err != nil {
+		t.Fatalf("restoreToolsetShims failed: %v", err)
+	}
+	if len(saved) != 1 || saved[0].Signature != nil || saved[0].InstallCommandHash == "" {
+		t.Fatalf("expected the redacted shim to be saved unsigned: %+v", saved)
+	}
+
+	out.Reset()
+	warnUntrustedShims(out, cfg, saved)
+	if !strings.Contains(out.String(), "tool is not signed") {
+		t.Fatalf("expected a not-signed warning, got %q", out.String())
+	}
+}
+
+func TestApplyImportRunSettings(t *testing.T) {
+	signed := &provenance.Signature{KeyID: "k"}
+	shims := []shim.Metadata{
+		{BinaryName: "git", Forward: []string{"ssh-agent", "kubeconfig"}, State: true, PathPolicy: "mount", Signature: signed},
+		{BinaryName: "jq", Signature: signed},
+	}
+	out := &bytes.Buffer{}
+	applyImportRunSettings(out, shims, nil, false, "")
+
+	git, jq := shims[0], shims[1]
+	if git.Forward != nil || git.State || git.PathPolicy != "" || git.Signature != nil {
+		t.Fatalf("exporter's run settings kept: %+v", git)
+	}
+	if jq.Signature == nil {
+		t.Fatal("signature dropped although no setting changed")
+	}
+	if !strings.Contains(out.String(), "dropping the signature of git") || strings.Contains(out.String(), "jq") {
+		t.Fatalf("unexpected warnings: %q", out.String())
+	}
+
+	applyImportRunSettings(out, shims, []string{"gitconfig"}, true, "refuse")
+	if !slices.Equal(shims[1].Forward, []string{"gitconfig"}) || !shims[1].State || shims[1].PathPolicy != "refuse" {
+		t.Fatalf("importer's run settings not applied: %+v", shims[1])
+	}
+}
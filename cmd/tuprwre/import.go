@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/argpath"
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/discovery"
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/toolset"
+	"github.com/c4rb0nx1/tuprwre/internal/vuln"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importForce      bool
+	importGlobal     bool
+	importForward    []string
+	importState      bool
+	importPathPolicy string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Load a toolset archive and regenerate its shims",
+	Long: `Loads the image from an archive written by 'tuprwre export --archive',
+restores the shim metadata and generates the shims. No install command is
+run.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().BoolVarP(&importForce, "force", "f", false, "Overwrite existing shims")
+	importCmd.Flags().BoolVar(&importGlobal, "global", false, "Import shims globally even when inside a workspace")
+	importCmd.Flags().StringArrayVar(&importForward, "forward", nil, "Host credentials the shims' runs get, as for install --forward (repeatable)")
+	importCmd.Flags().BoolVar(&importState, "state", false, "Give the shims a persistent home directory, as for install --state")
+	importCmd.Flags().StringVar(&importPathPolicy, "path-policy", "", "What the shims' runs do with arguments naming host paths outside the workspace, as for install --path-policy")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	forwardSpecs, err := forward.Normalize(importForward)
+	if err != nil {
+		return err
+	}
+	pathPolicy, err := argpath.ParsePolicy(importPathPolicy)
+	if err != nil {
+		return err
+	}
+
+	scope := shim.DefaultScope(cfg)
+	if importGlobal {
+		scope = shim.ScopeGlobal
+	}
+	shimGen, err := shim.NewScopedGenerator(cfg, scope)
+	if err != nil {
+		return err
+	}
+
+	archive, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archive.Close()
+
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+	ctx := context.Background()
+
+	manifest, _, err := toolset.ReadArchive(archive, func(r io.Reader) error {
+		return docker.LoadImage(ctx, r)
+	})
+	if err != nil {
+		return err
+	}
+	if manifest.ImageID != "" {
+		imageID, err := docker.ImageID(ctx, manifest.Image)
+		if err != nil {
+			return err
+		}
+		if imageID != manifest.ImageID {
+			return fmt.Errorf("image %s resolves to %s after load, archive expects %s", manifest.Image, imageID, manifest.ImageID)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if evicted, err := docker.EvictPoolImages(ctx, []string{manifest.Image}, false); err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: failed to evict warm containers for %s: %v\n", manifest.Image, err)
+	} else if evicted > 0 {
+		_, _ = fmt.Fprintf(out, "Evicted %d warm container(s) for %s\n", evicted, manifest.Image)
+	}
+
+	applyImportRunSettings(out, manifest.Shims, forwardSpecs, importState, pathPolicy)
+
+	toolsetSBOM, err := scanToolset(ctx, out, cfg, docker, manifest.Image, vuln.SeverityUnknown)
+	if err != nil {
+		return err
+	}
+	saved, err := restoreToolsetShims(out, shimGen, cfg, manifest, importForce, nil)
+	if err != nil {
+		return err
+	}
+	saveToolsetSBOM(out, shimGen, toolsetSBOM, shimNames(saved))
+	// Warn on what was saved: redaction on restore can drop a signature.
+	warnUntrustedShims(out, cfg, saved)
+	return nil
+}
+
+// applyImportRunSettings gives the imported shims the importer's credential
+// forwarding, persistent state and path policy. Like for install --from,
+// these are never taken from the exporter. A carried signature covers the
+// exporter's settings, so it is dropped when they differ.
+func applyImportRunSettings(out io.Writer, shims []shim.Metadata, forwardSpecs []string, state bool, pathPolicy argpath.Policy) {
+	for i := range shims {
+		meta := &shims[i]
+		if meta.Signature != nil && (!slices.Equal(meta.Forward, forwardSpecs) || meta.State != state || meta.PathPolicy != string(pathPolicy)) {
+			_, _ = fmt.Fprintf(out, "Warning: dropping the signature of %s; the archive's forwarding, state or path policy settings were replaced by this import's\n", meta.BinaryName)
+			meta.Signature = nil
+		}
+		meta.Forward = forwardSpecs
+		meta.State = state
+		meta.PathPolicy = string(pathPolicy)
+	}
+}
+
+// restoreToolsetShims generates shims and metadata for every shim in m and
+// returns the metadata saved for those created. With a key, the shims are
+// signed afresh against m.ImageID. Without one, a carried signature is kept
+// only while it still covers the metadata: it is dropped, with a warning,
+// when the install source is redacted here. applyImportRunSettings has
+// already dropped those whose run settings this import replaced.
+func restoreToolsetShims(out io.Writer, shimGen *shim.Generator, cfg *config.Config, m toolset.Manifest, force bool, key *provenance.Key) ([]shim.Metadata, error) {
+	workspace := ""
+	if shimGen.Scope() == shim.ScopeWorkspace {
+		workspace = cfg.WorkspaceRoot
+	}
+
+	redactor, err := redact.New(cfg.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []shim.Metadata
+	for _, meta := range m.Shims {
+		if !validShimName(meta.BinaryName) {
+			_, _ = fmt.Fprintf(out, "Warning: skipping invalid shim name %q\n", meta.BinaryName)
+			continue
+		}
+		if err := shimGen.Create(discovery.Binary{Name: meta.BinaryName}, m.Image, force); err != nil {
+			_, _ = fmt.Fprintf(out, "Warning: failed to create shim for %s: %v\n", meta.BinaryName, err)
+			continue
+		}
+
+		meta.OutputImage = m.Image
+		meta.Workspace = workspace
+		meta.InstallForceUsed = force
+		// Toolsets from before redaction carry the raw install source.
+		masked, err := redactInstallSource(cfg, redactor, &meta)
+		if err != nil {
+			return nil, err
+		}
+		if masked && key == nil && meta.Signature != nil {
+			// The carried signature covers the raw install source.
+			_, _ = fmt.Fprintf(out, "Warning: dropping the signature of %s; its install source contained credentials and was redacted\n", meta.BinaryName)
+			meta.Signature = nil
+		}
+		if key != nil {
+			if err := signMetadata(key, &meta, m.ImageID); err != nil {
+				_, _ = fmt.Fprintf(out, "Warning: failed to sign metadata for %s: %v\n", meta.BinaryName, err)
+				continue
+			}
+		}
+		if err := shimGen.SaveMetadata(meta); err != nil {
+			_, _ = fmt.Fprintf(out, "Warning: failed to persist metadata for %s: %v\n", meta.BinaryName, err)
+			continue
+		}
+		saved = append(saved, meta)
+		_, _ = fmt.Fprintf(out, "Created shim: %s\n", meta.BinaryName)
+	}
+
+	if len(saved) == 0 {
+		return nil, fmt.Errorf("no shims were created from %s", m.Image)
+	}
+	return saved, nil
+}
+
+// shimNames returns the binary names of metas.
+func shimNames(metas []shim.Metadata) []string {
+	names := make([]string, len(metas))
+	for i, meta := range metas {
+		names[i] = meta.BinaryName
+	}
+	return names
+}
+
+// validShimName rejects names that would escape the shim directory.
+func validShimName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -6,14 +6,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/c4rb0nx1/tuprwre/internal/argpath"
 	"github.com/c4rb0nx1/tuprwre/internal/config"
 	"github.com/c4rb0nx1/tuprwre/internal/discovery"
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
 	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
 	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
+	"github.com/c4rb0nx1/tuprwre/internal/vuln"
 	"github.com/spf13/cobra"
 )
 
@@ -25,9 +34,34 @@ var (
 	installScriptPath  string
 	installMemoryLimit string
 	installCPULimit    float64
+	installGlobal      bool
+	installSteps       []string
+	installNoCache     bool
+	installDockerfile  string
+	installContextDir  string
+	installFrom        string
+	installFailOn      string
+	installHardening   string
+	installForward     []string
+	installState       bool
+	installPathPolicy  string
 	installArgsReader  = func() []string { return os.Args }
+
+	installCapDrop         []string
+	installNoNewPrivileges bool
+	installPidsLimit       int64
+	installEgress          string
+	installROMounts        []string
+	installUser            string
+	installFakeroot        bool
+	installTimeout         time.Duration
+	installSecrets         []string
 )
 
+// installPolicyFlags harden the install container itself; they only apply
+// when tuprwre runs the install command.
+var installPolicyFlags = []string{"cap-drop", "no-new-privileges", "pids-limit", "egress", "ro-mount", "user", "fakeroot", "timeout", "secret"}
+
 type installRequest struct {
 	installCommand       string
 	baseImage            string
@@ -39,6 +73,18 @@ type installRequest struct {
 	installScriptArgs    []string
 	memoryLimit          string
 	cpuLimit             float64
+	scope                shim.Scope
+	steps                []string
+	noCache              bool
+	dockerfile           string
+	contextDir           string
+	from                 string
+	failOn               vuln.Severity
+	hardening            string
+	forward              []string
+	state                bool
+	pathPolicy           string
+	policy               sandbox.InstallPolicy
 }
 
 var installFlow = runInstallFlow
@@ -55,14 +101,23 @@ Workflow:
 - Execute the installation command
 - Commit the container state to a new image
 - Discover new binaries by diffing PATH or filesystem
-- Generate shim scripts in ~/.tuprwre/bin/`,
+- Generate shim scripts in ~/.tuprwre/bin/ (or the workspace's directory
+  under ~/.tuprwre/workspaces/ when run inside a workspace; use --global
+  to opt out)`,
 	Example: `  # Install from a curl script
 	  tuprwre install --base-image ubuntu:22.04 -- \
 	    "curl -fsSL https://example.com/install-tool.sh | bash"
 
 	  # Install with specific output image name
 	  tuprwre install --base-image alpine:latest --image toolset:latest -- \
-	    "wget -qO- https://example.com/install-tool.sh | sh"`,
+	    "wget -qO- https://example.com/install-tool.sh | sh"
+
+	  # Layered install; unchanged leading steps are reused on re-install
+	  tuprwre install --step "apt-get update" \
+	    --step "apt-get install -y --no-install-recommends jq"
+
+	  # Build an existing Dockerfile
+	  tuprwre install --dockerfile ./tools.Dockerfile --context .`,
 	RunE: runInstall,
 }
 
@@ -74,31 +129,284 @@ func init() {
 	installCmd.Flags().BoolVarP(&installForce, "force", "f", false, "Overwrite existing shims")
 	installCmd.Flags().StringVar(&installMemoryLimit, "memory", "", "Memory limit for the install container (e.g. 512m, 1g)")
 	installCmd.Flags().Float64Var(&installCPULimit, "cpus", 0, "CPU limit for the install container (e.g. 0.5, 1.0, 2.0)")
+	installCmd.Flags().BoolVar(&installGlobal, "global", false, "Install shims globally even when inside a workspace")
+	installCmd.Flags().StringArrayVar(&installSteps, "step", nil, "Install step committed as its own cached layer (repeatable; runs before the -- command or --script)")
+	installCmd.Flags().BoolVar(&installNoCache, "no-cache", false, "Re-run every --step instead of reusing cached layers")
+	installCmd.Flags().StringVar(&installDockerfile, "dockerfile", "", "Build the tool image from this Dockerfile instead of running a command")
+	installCmd.Flags().StringVar(&installContextDir, "context", "", "Build context for --dockerfile (default: the Dockerfile's directory)")
+	installCmd.Flags().StringVar(&installFrom, "from", "", "Install a toolset published with 'tuprwre publish' from a registry reference")
+	installCmd.Flags().StringVar(&installHardening, "hardening", "", "Hardening profile the shims run under (default|strict|custom; default: the configured profile)")
+	installCmd.Flags().StringArrayVar(&installForward, "forward", nil, "Host credentials the shims' runs get: ssh-agent, gitconfig, kubeconfig, file=PATH[,env=VAR] or socket=PATH[,env=VAR] (repeatable)")
+	installCmd.Flags().BoolVar(&installState, "state", false, "Give the shims a persistent home directory (~/.tuprwre/state/<shim>) mounted as $HOME in their runs")
+	installCmd.Flags().StringVar(&installPathPolicy, "path-policy", "", "What the shims' runs do with arguments naming host paths outside the workspace (ask|mount|refuse|off; default: the configured policy)")
+	installCmd.Flags().StringVar(&installFailOn, "fail-on", "", "Refuse the toolset when it has known vulnerabilities at least this severe (low|medium|high|critical)")
+	installCmd.Flags().StringArrayVar(&installCapDrop, "cap-drop", nil, "Drop a Linux capability from the install container (repeatable; ALL drops every one)")
+	installCmd.Flags().BoolVar(&installNoNewPrivileges, "no-new-privileges", false, "Stop the install command from gaining privileges through setuid binaries")
+	installCmd.Flags().Int64Var(&installPidsLimit, "pids-limit", 0, "Maximum number of processes in the install container")
+	installCmd.Flags().StringVar(&installEgress, "egress", "", "Install network policy: open, none, or allow=host[,host...] for an HTTP(S) proxy allowlist")
+	installCmd.Flags().StringArrayVar(&installROMounts, "ro-mount", nil, "Mount a host file or directory read-only as host-path[:container-path] (repeatable)")
+	installCmd.Flags().StringVar(&installUser, "user", "", "Run the install command as this user (name or uid[:gid]) instead of root")
+	installCmd.Flags().BoolVar(&installFakeroot, "fakeroot", false, "With --user, run the install command under fakeroot when the image provides it")
+	installCmd.Flags().DurationVar(&installTimeout, "timeout", 0, "Kill the install command after this long (e.g. 10m)")
+	installCmd.Flags().StringArrayVar(&installSecrets, "secret", nil, "Expose a secret to the install only, as id=ID,src=PATH (file in /run/secrets) or id=ID,env=VAR (env var ID) (repeatable)")
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
+	if installFrom != "" {
+		return runRegistryInstall(cmd, args)
+	}
+	if installDockerfile != "" {
+		return runDockerfileInstall(cmd, args)
+	}
+	if installContextDir != "" {
+		return fmt.Errorf("--context requires --dockerfile")
+	}
+	if len(installSteps) > 0 && installContainerID != "" {
+		return fmt.Errorf("--step cannot be combined with --container")
+	}
+	if installContainerID != "" {
+		if name := changedInstallPolicyFlag(cmd, nil); name != "" {
+			return fmt.Errorf("--%s cannot be combined with --container", name)
+		}
+	}
+	policy, err := installPolicyFromFlags(installSecrets)
+	if err != nil {
+		return err
+	}
+
 	req, err := resolveInstallRequest(args, installArgsReader())
+	if err != nil {
+		if len(installSteps) == 0 || installScriptPath != "" {
+			return err
+		}
+		// The steps alone are the whole install.
+		req = &installRequest{}
+	}
+
+	cfg, settings, err := loadInstallSettings()
+	if err != nil {
+		return err
+	}
+
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
+	settings.installCommand = req.installCommand
+	settings.baseImage = installBaseImage
+	settings.containerID = installContainerID
+	settings.imageName = installImageName
+	settings.installScriptPath = req.installScriptPath
+	settings.installScriptContent = req.installScriptContent
+	settings.installScriptArgs = req.installScriptArgs
+	settings.memoryLimit = installMemoryLimit
+	settings.cpuLimit = installCPULimit
+	settings.steps = installSteps
+	settings.noCache = installNoCache
+	settings.policy = policy
+	return installFlow(cmd, cfg, settings)
+}
+
+// runRegistryInstall handles `install --from`.
+func runRegistryInstall(cmd *cobra.Command, args []string) error {
+	switch {
+	case installDockerfile != "", installScriptPath != "", installContainerID != "", len(installSteps) > 0:
+		return fmt.Errorf("--from cannot be combined with --dockerfile, --script, --container or --step")
+	case len(args) > 0:
+		return fmt.Errorf("--from does not take an installation command")
+	}
+	if name := changedInstallPolicyFlag(cmd, nil); name != "" {
+		return fmt.Errorf("--%s cannot be combined with --from", name)
+	}
+
+	cfg, req, err := loadInstallSettings()
 	if err != nil {
 		return err
 	}
 
-	// Load configuration
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
+	req.from = installFrom
+	return installFlow(cmd, cfg, req)
+}
+
+// runDockerfileInstall handles `install --dockerfile`.
+func runDockerfileInstall(cmd *cobra.Command, args []string) error {
+	switch {
+	case installScriptPath != "":
+		return fmt.Errorf("--dockerfile cannot be combined with --script")
+	case installContainerID != "":
+		return fmt.Errorf("--dockerfile cannot be combined with --container")
+	case len(installSteps) > 0:
+		return fmt.Errorf("--dockerfile cannot be combined with --step")
+	case len(args) > 0:
+		return fmt.Errorf("--dockerfile does not take an installation command")
+	}
+	// A build only honours the network policy, and only "none".
+	if name := changedInstallPolicyFlag(cmd, []string{"egress"}); name != "" {
+		return fmt.Errorf("--%s cannot be combined with --dockerfile", name)
+	}
+	policy, err := installPolicyFromFlags(nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := policy.EgressAllowlist(); ok {
+		return fmt.Errorf("--dockerfile supports --egress open or none, not an allowlist")
+	}
+
+	dockerfile, err := filepath.Abs(installDockerfile)
+	if err != nil {
+		return fmt.Errorf("failed to resolve Dockerfile path: %w", err)
+	}
+	contextDir := filepath.Dir(dockerfile)
+	if installContextDir != "" {
+		if contextDir, err = filepath.Abs(installContextDir); err != nil {
+			return fmt.Errorf("failed to resolve build context: %w", err)
+		}
+	}
+
+	// An explicit --base-image wins; otherwise discovery diffs against the
+	// Dockerfile's final FROM.
+	baseImage := ""
+	if cmd.Flags().Changed("base-image") {
+		baseImage = installBaseImage
+	} else if baseImage, err = dockerfileDiscoveryBase(dockerfile, ""); err != nil {
+		return err
+	}
+
+	cfg, req, err := loadInstallSettings()
+	if err != nil {
+		return err
+	}
+
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
+	req.baseImage = baseImage
+	req.imageName = installImageName
+	req.memoryLimit = installMemoryLimit
+	req.cpuLimit = installCPULimit
+	req.dockerfile = dockerfile
+	req.contextDir = contextDir
+	req.policy = policy
+	return installFlow(cmd, cfg, req)
+}
+
+// loadInstallSettings loads the config and resolves the flags every install
+// mode shares into the request fields they set: force, scope, fail-on,
+// hardening, forwards, state and path policy.
+func loadInstallSettings() (*config.Config, installRequest, error) {
 	cfg, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
-	return installFlow(cmd, cfg, installRequest{
-		installCommand:       req.installCommand,
-		baseImage:            installBaseImage,
-		containerID:          installContainerID,
-		imageName:            installImageName,
-		force:                installForce,
-		installScriptPath:    req.installScriptPath,
-		installScriptContent: req.installScriptContent,
-		installScriptArgs:    req.installScriptArgs,
-		memoryLimit:          installMemoryLimit,
-		cpuLimit:             installCPULimit,
-	})
+		return nil, installRequest{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	failOn, err := parseInstallFailOn(cfg, installFailOn)
+	if err != nil {
+		return nil, installRequest{}, err
+	}
+	hardeningName, err := resolveInstallHardening(cfg, installHardening)
+	if err != nil {
+		return nil, installRequest{}, err
+	}
+	forwardSpecs, err := forward.Normalize(installForward)
+	if err != nil {
+		return nil, installRequest{}, err
+	}
+	pathPolicy, err := argpath.ParsePolicy(installPathPolicy)
+	if err != nil {
+		return nil, installRequest{}, err
+	}
+
+	scope := shim.DefaultScope(cfg)
+	if installGlobal {
+		scope = shim.ScopeGlobal
+	}
+	return cfg, installRequest{
+		force:      installForce,
+		scope:      scope,
+		failOn:     failOn,
+		hardening:  hardeningName,
+		forward:    forwardSpecs,
+		state:      installState,
+		pathPolicy: string(pathPolicy),
+	}, nil
+}
+
+// installPolicyFromFlags builds the install policy from the hardening flags
+// and the --secret specs.
+func installPolicyFromFlags(secretSpecs []string) (sandbox.InstallPolicy, error) {
+	policy := sandbox.InstallPolicy{
+		CapDrop:         installCapDrop,
+		NoNewPrivileges: installNoNewPrivileges,
+		PidsLimit:       installPidsLimit,
+		Egress:          installEgress,
+		ReadOnlyMounts:  installROMounts,
+		User:            installUser,
+		Fakeroot:        installFakeroot,
+	}
+	if installTimeout < 0 {
+		return sandbox.InstallPolicy{}, fmt.Errorf("--timeout must not be negative")
+	}
+	if installTimeout > 0 {
+		policy.Timeout = installTimeout.String()
+	}
+	if installFakeroot && strings.TrimSpace(installUser) == "" {
+		return sandbox.InstallPolicy{}, fmt.Errorf("--fakeroot requires --user")
+	}
+	secrets, err := parseInstallSecrets(secretSpecs)
+	if err != nil {
+		return sandbox.InstallPolicy{}, err
+	}
+	policy.Secrets = secrets
+	return policy.Normalize()
+}
+
+// parseInstallSecrets reads the --secret values from the host.
+func parseInstallSecrets(specs []string) ([]sandbox.Secret, error) {
+	var secrets []sandbox.Secret
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		secret, err := sandbox.ParseSecret(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[secret.ID] {
+			return nil, fmt.Errorf("secret %q given more than once", secret.ID)
+		}
+		seen[secret.ID] = true
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// changedInstallPolicyFlag returns the first install policy flag set on the
+// command line that is not in allowed, or "".
+func changedInstallPolicyFlag(cmd *cobra.Command, allowed []string) string {
+	for _, name := range installPolicyFlags {
+		if cmd.Flags().Changed(name) && !slices.Contains(allowed, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// dockerfileDiscoveryBase returns the image discovery should diff a
+// Dockerfile build against, or fallback when the Dockerfile does not say.
+func dockerfileDiscoveryBase(dockerfile, fallback string) (string, error) {
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("Dockerfile not found: %s", dockerfile)
+		}
+		return "", fmt.Errorf("failed to read Dockerfile %s: %w", dockerfile, err)
+	}
+	if base := sandbox.DockerfileBaseImage(content); base != "" {
+		return base, nil
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("cannot determine the base image of %s (build args or scratch); pass --base-image", dockerfile)
 }
 
 func resolveInstallRequest(argsFromCobra []string, argv []string) (*installRequest, error) {
@@ -184,7 +492,16 @@ func parseInstallCommandFromArgv(argv []string) (string, bool, error) {
 	return "", false, nil
 }
 
-func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest) error {
+func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest) (err error) {
+	ctx, span := telemetry.Start(telemetry.FromEnv(context.Background()), "install",
+		telemetry.AttrImage.String(req.baseImage),
+	)
+	defer func() { telemetry.End(span, err) }()
+
+	if req.from != "" {
+		return installFromRegistry(cmd, cfg, req)
+	}
+
 	installCommand := req.installCommand
 	if req.installScriptPath != "" {
 		scriptContent := req.installScriptContent
@@ -201,27 +518,116 @@ func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest)
 		installCommand = buildScriptInstallCommand(scriptContent, req.installScriptArgs)
 	}
 
+	scope := req.scope
+	if scope == "" {
+		scope = shim.DefaultScope(cfg)
+	}
+	shimGen, err := shim.NewScopedGenerator(cfg, scope)
+	if err != nil {
+		return err
+	}
+	workspace := ""
+	if shimGen.Scope() == shim.ScopeWorkspace {
+		workspace = cfg.WorkspaceRoot
+	}
+	signingKey, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		return err
+	}
+	redactor, err := redact.New(cfg.RedactPatterns)
+	if err != nil {
+		return err
+	}
+	source := shim.Metadata{
+		InstallCommand:    req.installCommand,
+		InstallSteps:      req.steps,
+		InstallScriptArgs: req.installScriptArgs,
+	}
+	if _, err := redactInstallSource(cfg, redactor, &source); err != nil {
+		return err
+	}
+	if source.InstallCommandHash != "" {
+		cmd.Printf("Install command contains credentials; storing it as: %s\n", source.InstallCommand)
+	}
+	if source.InstallStepsHash != "" {
+		cmd.Printf("Install steps contain credentials; storing them as: %s\n", strings.Join(source.InstallSteps, " && "))
+	}
+	if source.InstallScriptArgsHash != "" {
+		cmd.Printf("Script arguments contain credentials; storing them as: %s\n", strings.Join(source.InstallScriptArgs, " "))
+	}
+
 	// Create Docker runtime
 	docker := sandbox.New(cfg)
 	defer docker.Close()
 
-	ctx := context.Background()
 	var containerID string
+	var stepImageID string
+	var contextDigest string
 	var resources sandbox.ResourcePolicy
+	var policy *sandbox.InstallPolicy
+	var secretIDs []string
 
-	if req.containerID != "" {
-		// Use existing container
-		containerID = req.containerID
-		fmt.Printf("Using existing container: %s\n", containerID)
-	} else {
+	imageName := req.imageName
+	if imageName == "" {
+		imageName = docker.GenerateImageName()
+	}
+
+	if req.containerID == "" {
 		// Resolve resource limits: CLI flags override config defaults
 		spec := sandbox.MergeResourceSpec(req.memoryLimit, req.cpuLimit, cfg.DefaultMemory, cfg.DefaultCPUs)
-		var err error
 		resources, err = docker.ResolveResourceSpec(ctx, spec)
 		if err != nil {
 			return fmt.Errorf("failed to resolve resource limits: %w", err)
 		}
 
+		effective, err := req.policy.Normalize()
+		if err != nil {
+			return fmt.Errorf("invalid install policy: %w", err)
+		}
+		policy = &effective
+		if !effective.IsZero() {
+			fmt.Printf("Install policy: %s\n", describeInstallPolicy(effective))
+		}
+		if secretIDs = sandbox.SecretIDs(effective.Secrets); len(secretIDs) > 0 {
+			fmt.Printf("Install secrets: %s\n", strings.Join(secretIDs, ", "))
+		}
+	}
+
+	switch {
+	case req.dockerfile != "":
+		contextDigest, err = sandbox.BuildContextDigest(req.contextDir, req.dockerfile)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Building %s (context %s)\n\n", req.dockerfile, req.contextDir)
+		_, err = docker.BuildDockerfile(ctx, sandbox.DockerfileBuild{
+			Dockerfile: req.dockerfile,
+			ContextDir: req.contextDir,
+			Tag:        imageName,
+			Resources:  resources,
+			NoNetwork:  policy.Egress == sandbox.EgressNone,
+		})
+		if err != nil {
+			return fmt.Errorf("installation failed: %w", err)
+		}
+		fmt.Printf("\nBuilt image: %s\n", imageName)
+	case len(req.steps) > 0:
+		steps := installStepList(&req, installCommand)
+		for i := range steps {
+			steps[i].Display = redactor.String(steps[i].Command)
+		}
+		fmt.Printf("Building %d step(s) on image: %s\n\n", len(steps), req.baseImage)
+		stepImageID, err = docker.BuildSteps(ctx, req.baseImage, steps, resources, *policy, req.noCache)
+		if err != nil {
+			return fmt.Errorf("installation failed: %w", err)
+		}
+		fmt.Println()
+	case req.containerID != "":
+		// Use existing container
+		containerID = req.containerID
+		fmt.Printf("Using existing container: %s\n", containerID)
+	default:
 		// Create and run container with installation command
 		fmt.Printf("Creating sandbox container from image: %s\n", req.baseImage)
 		if !resources.IsZero() {
@@ -234,7 +640,7 @@ func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest)
 		}
 		fmt.Printf("Running installation command...\n\n")
 
-		containerID, err = docker.CreateAndRunContainer(ctx, req.baseImage, installCommand, resources)
+		containerID, err = docker.CreateAndRunContainer(ctx, req.baseImage, installCommand, resources, *policy)
 
 		// ALWAYS cleanup the container we just created, regardless of success/fail
 		defer func() {
@@ -248,31 +654,67 @@ func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest)
 		fmt.Printf("\nContainer finished successfully: %s\n", containerID)
 	}
 
-	// Commit container state
-	imageName := req.imageName
-	if imageName == "" {
-		imageName = docker.GenerateImageName()
+	// Commit container state; a Dockerfile build is already tagged.
+	if req.dockerfile == "" {
+		commitCtx, phase := telemetry.Start(ctx, "install.commit", telemetry.AttrImage.String(imageName))
+		if stepImageID != "" {
+			err = docker.TagImage(commitCtx, stepImageID, imageName)
+		} else {
+			fmt.Printf("Committing container to image: %s\n", imageName)
+			err = docker.Commit(commitCtx, containerID, imageName)
+		}
+		telemetry.End(phase, err)
+		if err != nil {
+			return fmt.Errorf("failed to commit container: %w", err)
+		}
+	}
+
+	// Warm containers still run the previous image behind this tag.
+	if evicted, err := docker.EvictPoolImages(ctx, []string{imageName}, false); err != nil {
+		cmd.Printf("Warning: failed to evict warm containers for %s: %v\n", imageName, err)
+	} else if evicted > 0 {
+		fmt.Printf("Evicted %d warm container(s) for %s\n", evicted, imageName)
 	}
 
-	fmt.Printf("Committing container to image: %s\n", imageName)
-	if err := docker.Commit(ctx, containerID, imageName); err != nil {
-		return fmt.Errorf("failed to commit container: %w", err)
+	// Shims are signed against the ID the tag resolves to now.
+	imageID, err := docker.ImageID(ctx, imageName)
+	if err != nil {
+		return err
 	}
 
 	// Discover binaries
 	fmt.Printf("Discovering installed binaries...\n")
 	disc := discovery.New(cfg, docker)
+	_, phase := telemetry.Start(ctx, "install.discovery", telemetry.AttrImage.String(imageName))
 	binaries, err := disc.DiscoverBinaries(req.baseImage, imageName)
+	phase.SetAttributes(telemetry.AttrBinaries.Int(len(binaries)))
+	telemetry.End(phase, err)
 	if err != nil {
 		return fmt.Errorf("failed to discover binaries: %w", err)
 	}
 
 	cmd.Printf("Discovered %d new binaries\n", len(binaries))
 
+	// Scan before any shim exists so --fail-on can refuse the toolset.
+	var toolsetSBOM *sbom.SBOM
+	if len(binaries) > 0 {
+		sbomCtx, phase := telemetry.Start(ctx, "install.sbom", telemetry.AttrImage.String(imageName))
+		toolsetSBOM, err = scanToolset(sbomCtx, cmd.OutOrStderr(), cfg, docker, imageName, req.failOn)
+		telemetry.End(phase, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(req.forward) > 0 {
+		fmt.Printf("Credential forwarding for runs: %s\n", strings.Join(req.forward, ", "))
+	}
+
 	// Generate shims
+	_, phase = telemetry.Start(ctx, "install.shims", telemetry.AttrBinaries.Int(len(binaries)))
+	var created []string
 	if len(binaries) > 0 {
 		fmt.Printf("Generating shim scripts...\n")
-		shimGen := shim.NewGenerator(cfg)
 		for _, binary := range binaries {
 			if err := shimGen.Create(binary, imageName, req.force); err != nil {
 				cmd.Printf("Warning: failed to create shim for %s: %v\n", binary.Name, err)
@@ -280,33 +722,180 @@ func runInstallFlow(cmd *cobra.Command, cfg *config.Config, req installRequest)
 			}
 
 			metadata := shim.Metadata{
-				BinaryName:        binary.Name,
-				InstallMode:       metadataInstallMode(&req),
-				InstallCommand:    req.installCommand,
-				InstallScriptPath: req.installScriptPath,
-				InstallScriptArgs: req.installScriptArgs,
-				BaseImage:         req.baseImage,
-				OutputImage:       imageName,
-				InstalledAt:       time.Now().UTC().Format(time.RFC3339),
-				InstallForceUsed:  req.force,
-				Workspace:         cfg.WorkspaceRoot,
+				BinaryName:         binary.Name,
+				InstallMode:        metadataInstallMode(&req),
+				InstallCommand:     source.InstallCommand,
+				InstallCommandHash: source.InstallCommandHash,
+				InstallScriptPath:  req.installScriptPath,
+				InstallScriptArgs:  source.InstallScriptArgs,
+				InstallSteps:       source.InstallSteps,
+				InstallDockerfile:  req.dockerfile,
+				InstallContext:     req.contextDir,
+				ContextDigest:      contextDigest,
+				BaseImage:          req.baseImage,
+				OutputImage:        imageName,
+				InstalledAt:        time.Now().UTC().Format(time.RFC3339),
+				InstallForceUsed:   req.force,
+				Workspace:          workspace,
+				Hardening:          req.hardening,
+				Forward:            req.forward,
+				State:              req.state,
+				PathPolicy:         req.pathPolicy,
+				InstallPolicy:      policy,
+				InstallSecrets:     secretIDs,
+			}
+			if err := signMetadata(signingKey, &metadata, imageID); err != nil {
+				cmd.Printf("Warning: failed to sign metadata for %s: %v\n", binary.Name, err)
+				continue
 			}
 			if err := shimGen.SaveMetadata(metadata); err != nil {
 				cmd.Printf("Warning: failed to persist metadata for %s: %v\n", binary.Name, err)
 			} else {
+				created = append(created, binary.Name)
 				cmd.Printf("Created shim: %s\n", binary.Name)
 			}
 		}
 	}
+	phase.End()
+
+	saveToolsetSBOM(cmd.OutOrStderr(), shimGen, toolsetSBOM, created)
 
-	cmd.Printf("\nInstallation complete! Add %s to your PATH.\n", cfg.ShimDir)
-	cmd.Printf("Run: export PATH=\"%s:$PATH\"\n", cfg.ShimDir)
+	cmd.Printf("\nInstallation complete!\n")
+	if err := shimGen.ValidateShimDir(); err != nil {
+		cmd.Printf("Add %s to your PATH (or use 'tuprwre shell').\n", shimGen.ShimDir())
+		cmd.Printf("Run: eval \"$(tuprwre env)\"\n")
+	}
 	return nil
 }
 
+// redactInstallCommand masks credentials in command for storage. When
+// anything was masked it also returns a salted hash of the original, so
+// update can tell whether a re-supplied command differs.
+func redactInstallCommand(cfg *config.Config, r *redact.Redactor, command string) (stored, hash string, err error) {
+	stored = r.String(command)
+	if stored == command {
+		return command, "", nil
+	}
+	salt, err := redact.LoadOrCreateSalt(cfg.BaseDir)
+	if err != nil {
+		return "", "", err
+	}
+	return stored, redact.Hash(salt, command), nil
+}
+
+// redactInstallList masks credentials in each of values for storage, like
+// redactInstallCommand. The hash covers the whole list.
+func redactInstallList(cfg *config.Config, r *redact.Redactor, values []string) (stored []string, hash string, err error) {
+	stored = r.Strings(values)
+	if slices.Equal(stored, values) {
+		return values, "", nil
+	}
+	salt, err := redact.LoadOrCreateSalt(cfg.BaseDir)
+	if err != nil {
+		return nil, "", err
+	}
+	return stored, redact.Hash(salt, joinInstallList(values)), nil
+}
+
+// joinInstallList encodes a list of steps or arguments for hashing.
+func joinInstallList(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+// redactInstallSource masks credentials in every install source field of
+// meta (command, steps and script arguments) that is not stored redacted
+// yet, setting their hashes. It reports whether anything was masked.
+func redactInstallSource(cfg *config.Config, r *redact.Redactor, meta *shim.Metadata) (bool, error) {
+	masked := false
+	var err error
+	if meta.InstallCommandHash == "" {
+		if meta.InstallCommand, meta.InstallCommandHash, err = redactInstallCommand(cfg, r, meta.InstallCommand); err != nil {
+			return false, err
+		}
+		masked = meta.InstallCommandHash != ""
+	}
+	if meta.InstallStepsHash == "" {
+		if meta.InstallSteps, meta.InstallStepsHash, err = redactInstallList(cfg, r, meta.InstallSteps); err != nil {
+			return false, err
+		}
+		masked = masked || meta.InstallStepsHash != ""
+	}
+	if meta.InstallScriptArgsHash == "" {
+		if meta.InstallScriptArgs, meta.InstallScriptArgsHash, err = redactInstallList(cfg, r, meta.InstallScriptArgs); err != nil {
+			return false, err
+		}
+		masked = masked || meta.InstallScriptArgsHash != ""
+	}
+	return masked, nil
+}
+
+// resolveInstallHardening validates --hardening and returns the profile
+// name to record, or "" to follow the configured profile.
+func resolveInstallHardening(cfg *config.Config, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	p, err := hardening.Resolve(cfg, name)
+	if err != nil {
+		return "", err
+	}
+	return p.Name, nil
+}
+
+// describeInstallPolicy renders p for the install log.
+func describeInstallPolicy(p sandbox.InstallPolicy) string {
+	var parts []string
+	if len(p.CapDrop) > 0 {
+		parts = append(parts, "cap-drop="+strings.Join(p.CapDrop, ","))
+	}
+	if p.NoNewPrivileges {
+		parts = append(parts, "no-new-privileges")
+	}
+	if p.PidsLimit > 0 {
+		parts = append(parts, fmt.Sprintf("pids-limit=%d", p.PidsLimit))
+	}
+	parts = append(parts, "egress="+p.Egress)
+	for _, mount := range p.ReadOnlyMounts {
+		parts = append(parts, "ro-mount="+mount)
+	}
+	if p.User != "" {
+		parts = append(parts, "user="+p.User)
+	}
+	if p.Fakeroot {
+		parts = append(parts, "fakeroot")
+	}
+	if p.Timeout != "" {
+		parts = append(parts, "timeout="+p.Timeout)
+	}
+	return strings.Join(parts, " ")
+}
+
 func metadataInstallMode(req *installRequest) string {
+	if req != nil && req.dockerfile != "" {
+		return "dockerfile"
+	}
+	if req != nil && len(req.steps) > 0 {
+		return "steps"
+	}
 	if req == nil || req.installScriptPath == "" {
 		return "command"
 	}
 	return "script"
 }
+
+// installStepList turns a stepped install into build steps. The -- command
+// or --script, when given, runs as the last step.
+func installStepList(req *installRequest, installCommand string) []sandbox.BuildStep {
+	steps := make([]sandbox.BuildStep, 0, len(req.steps)+1)
+	for _, command := range req.steps {
+		steps = append(steps, sandbox.BuildStep{Command: command})
+	}
+	if installCommand != "" {
+		step := sandbox.BuildStep{Command: installCommand}
+		if req.installScriptPath != "" {
+			step.Files = []string{req.installScriptPath}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
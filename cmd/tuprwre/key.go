@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/spf13/cobra"
+)
+
+var keyResign bool
+
+var keyCmd = &cobra.Command{
+	Use:   "key [--resign [shim]]",
+	Short: "Print the public key shims are signed with",
+	Long: `Prints the base64 ed25519 public key tuprwre signs shim metadata with,
+creating the key under the tuprwre directory if needed.
+
+Teammates add this value to "trusted_keys" in their global config
+(~/.tuprwre/config.json) to run toolsets you export.
+
+With --resign, signs the metadata of the named shim, or of every unsigned
+global shim, with the local key instead: for shims installed before signing
+existed, or whose signature was dropped on import. Workspace shims are only
+signed when named. A shim is only re-signed while its image tag still
+resolves to the image ID it recorded and any existing signature verifies;
+the credentials, state and path policy being signed are printed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runKey,
+}
+
+func init() {
+	keyCmd.Flags().BoolVar(&keyResign, "resign", false, "Sign existing shim metadata with the local key")
+}
+
+// keyImageID resolves an image tag to its local image ID.
+var keyImageID = func(cfg *config.Config, image string) (string, error) {
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+	return docker.ImageID(context.Background(), image)
+}
+
+func runKey(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 && !keyResign {
+		return fmt.Errorf("a shim name is only accepted with --resign")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		return err
+	}
+	if keyResign {
+		return resignShims(cmd.OutOrStdout(), cfg, key, args)
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), key.PublicKey())
+	return nil
+}
+
+// resignShims signs the metadata of the shim named in args, or of every
+// unsigned global shim, against the image ID its tag resolves to now.
+// Workspace metadata is only signed when named: it may have come from
+// whoever last touched the workspace. Shims whose tag moved since the
+// recorded ID, or whose existing signature does not verify, are left
+// alone: that is what signatures guard against, and only a reinstall
+// vouches for the new image.
+func resignShims(out io.Writer, cfg *config.Config, key *provenance.Key, args []string) error {
+	type owned struct {
+		gen  *shim.Generator
+		meta shim.Metadata
+	}
+	var shims []owned
+	if len(args) == 1 {
+		gen, err := shim.Resolve(cfg, args[0])
+		if err != nil {
+			// Metadata-only entries live in the global scope, as for update.
+			gen = shim.NewGenerator(cfg)
+		}
+		meta, err := gen.LoadMetadata(args[0])
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("shim %q has no metadata to sign; reinstall it with `tuprwre install`", args[0])
+			}
+			return fmt.Errorf("failed to load metadata for shim %q: %w", args[0], err)
+		}
+		shims = append(shims, owned{gen, meta})
+	} else {
+		gen := shim.NewGenerator(cfg)
+		metas, err := gen.ListAllMetadata()
+		if err != nil {
+			return err
+		}
+		for _, meta := range metas {
+			if meta.Signature == nil || meta.ImageID == "" {
+				shims = append(shims, owned{gen, meta})
+			}
+		}
+	}
+
+	trusted, err := trustedKeys(cfg)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, s := range shims {
+		meta := s.meta
+		if meta.Signature != nil {
+			if err := provenance.Verify(meta.Statement(), meta.Signature, trusted); err != nil {
+				_, _ = fmt.Fprintf(out, "Warning: not re-signing %s: its signature does not verify (%v); reinstall it with 'tuprwre update %s'\n",
+					meta.BinaryName, err, meta.BinaryName)
+				failed++
+				continue
+			}
+		}
+		imageID, err := keyImageID(cfg, meta.OutputImage)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "Warning: not re-signing %s: %v\n", meta.BinaryName, err)
+			failed++
+			continue
+		}
+		if meta.ImageID != "" && meta.ImageID != imageID {
+			_, _ = fmt.Fprintf(out, "Warning: not re-signing %s: %s now resolves to %s, not the recorded %s; reinstall it with 'tuprwre update %s'\n",
+				meta.BinaryName, meta.OutputImage, imageID, meta.ImageID, meta.BinaryName)
+			failed++
+			continue
+		}
+		if err := signMetadata(key, &meta, imageID); err != nil {
+			return err
+		}
+		if err := s.gen.SaveMetadata(meta); err != nil {
+			return fmt.Errorf("failed to persist metadata for %s: %w", meta.BinaryName, err)
+		}
+		_, _ = fmt.Fprintf(out, "Re-signed shim: %s (%s scope, image %s)\n", meta.BinaryName, s.gen.Scope(), meta.OutputImage)
+		printSignedGrants(out, meta)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d shim(s) were not re-signed", failed)
+	}
+	return nil
+}
+
+// printSignedGrants lists what a signature on meta vouches for beyond the
+// image: the credentials, state and host paths its runs get.
+func printSignedGrants(out io.Writer, meta shim.Metadata) {
+	forward := "none"
+	if len(meta.Forward) > 0 {
+		forward = strings.Join(meta.Forward, ", ")
+	}
+	pathPolicy := meta.PathPolicy
+	if pathPolicy == "" {
+		pathPolicy = "configured default"
+	}
+	_, _ = fmt.Fprintf(out, "  forward: %s\n", forward)
+	_, _ = fmt.Fprintf(out, "  state: %t\n", meta.State)
+	_, _ = fmt.Fprintf(out, "  path_policy: %s\n", pathPolicy)
+}
+
+// signMetadata binds meta to imageID and signs it with key.
+func signMetadata(key *provenance.Key, meta *shim.Metadata, imageID string) error {
+	meta.ImageID = imageID
+	sig, err := key.Sign(meta.Statement())
+	if err != nil {
+		return err
+	}
+	meta.Signature = sig
+	return nil
+}
+
+// trustedKeys returns the public keys whose signatures are accepted: the
+// local signing key, when one exists, and the configured trusted keys.
+func trustedKeys(cfg *config.Config) ([]string, error) {
+	keys := append([]string(nil), cfg.TrustedKeys...)
+	local, err := provenance.LoadKey(cfg.BaseDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, local.PublicKey()), nil
+}
+
+// verifyRunProvenance checks the signed metadata of the shim that runs
+// binaryName in image and returns that metadata, nil when no shim owns the
+// run, with the image ID the run must use. Runs that no shim owns are not
+// checked. With allowUnsigned, a missing or unverifiable
+// signature is not an error, but a valid one is still enforced.
+func verifyRunProvenance(cfg *config.Config, binaryName, image string, allowUnsigned bool) (*shim.Metadata, string, error) {
+	meta, ok, err := shim.FindMetadata(shim.Generators(cfg), binaryName, image)
+	if err != nil || !ok {
+		return nil, "", err
+	}
+	imageID, err := verifyShimMetadata(cfg, meta, allowUnsigned)
+	if err != nil {
+		return nil, "", err
+	}
+	return &meta, imageID, nil
+}
+
+// verifyShimMetadata checks the signature of meta itself, as
+// verifyRunProvenance does, and returns the signed image ID.
+func verifyShimMetadata(cfg *config.Config, meta shim.Metadata, allowUnsigned bool) (string, error) {
+	binaryName := meta.BinaryName
+	if meta.Signature == nil || meta.ImageID == "" {
+		if allowUnsigned {
+			return "", nil
+		}
+		return "", fmt.Errorf("shim %q is not signed; %s or pass --allow-unsigned", binaryName, unsignedShimHint(meta))
+	}
+
+	trusted, err := trustedKeys(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := provenance.Verify(meta.Statement(), meta.Signature, trusted); err != nil {
+		if allowUnsigned {
+			return "", nil
+		}
+		if errors.Is(err, provenance.ErrUntrustedKey) {
+			return "", fmt.Errorf("shim %q is %w; add its public key to trusted_keys in the global config or pass --allow-unsigned", binaryName, err)
+		}
+		return "", fmt.Errorf("shim %q failed signature verification: %w", binaryName, err)
+	}
+	return meta.ImageID, nil
+}
+
+// unsignedShimHint says how to get an unsigned shim signed. Workspace
+// metadata may come from anyone with write access to the workspace, so it
+// is pointed at a reinstall rather than at signing what is there.
+func unsignedShimHint(meta shim.Metadata) string {
+	if meta.Workspace != "" {
+		return fmt.Sprintf("review it and reinstall it with 'tuprwre update %s'", meta.BinaryName)
+	}
+	return fmt.Sprintf("review its metadata and sign it with 'tuprwre key --resign %s'", meta.BinaryName)
+}
+
+// warnUntrustedShims tells the user about restored shims that run will
+// refuse until their signer is trusted.
+func warnUntrustedShims(out io.Writer, cfg *config.Config, shims []shim.Metadata) {
+	trusted, err := trustedKeys(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: failed to load trusted keys: %v\n", err)
+		return
+	}
+	for _, meta := range shims {
+		if meta.Signature == nil || meta.ImageID == "" {
+			_, _ = fmt.Fprintf(out, "Warning: %s is not signed; %s, or run it with --allow-unsigned\n", meta.BinaryName, unsignedShimHint(meta))
+			continue
+		}
+		err := provenance.Verify(meta.Statement(), meta.Signature, trusted)
+		switch {
+		case errors.Is(err, provenance.ErrUntrustedKey):
+			_, _ = fmt.Fprintf(out, "Warning: %s is %v; it only runs once that key is in trusted_keys or with --allow-unsigned\n", meta.BinaryName, err)
+		case err != nil:
+			_, _ = fmt.Fprintf(out, "Warning: %s failed signature verification (%v); it only runs with --allow-unsigned\n", meta.BinaryName, err)
+		}
+	}
+}
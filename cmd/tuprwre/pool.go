@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox/pool"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
+	"github.com/c4rb0nx1/tuprwre/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	poolStatusJSON bool
+	poolDrainForce bool
+	poolStatsJSON  bool
+	poolStatsReset bool
+)
+
+var poolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Inspect and manage the warm container pool",
+	Long: `Inspect and manage the warm container pool used by tuprwre run.
+
+All subcommands use the same pool settings as tuprwre run (warm_pool_ttl,
+warm_pool_max_per_key, warm_pool_max_total, and the TUPRWRE_WARM_POOL and
+TUPRWRE_WARM_POOL_TTL overrides).`,
+}
+
+var poolStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show warm pool containers",
+	Args:  cobra.NoArgs,
+	RunE:  runPoolStatus,
+}
+
+var poolGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove dead and TTL-expired warm containers",
+	Args:  cobra.NoArgs,
+	RunE:  runPoolGC,
+}
+
+var poolDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Remove all warm containers",
+	Long: `Removes every warm pool container. Containers currently leased by a running
+shim are skipped unless --force is given.`,
+	Args: cobra.NoArgs,
+	RunE: runPoolDrain,
+}
+
+var poolWarmCmd = &cobra.Command{
+	Use:   "warm <shim>",
+	Short: "Create a warm container for a shim ahead of first use",
+	Long: `Creates (or keeps) an idle warm container matching what the shim would use
+when invoked from the current directory: same image, workspace mount and
+default resource limits.`,
+	Example: `  # From inside the project the tool will run in
+  tuprwre pool warm node`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPoolWarm,
+}
+
+var poolStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show pool counters and per-image shim overhead",
+	Long: `Shows warm pool hit, miss, eviction and cold-path fallback counters, and
+p50/p95 shim overhead per image: the time from tuprwre run starting to the
+command starting in the container. Samples cover the most recent runs of
+each image.`,
+	Args: cobra.NoArgs,
+	RunE: runPoolStats,
+}
+
+func init() {
+	poolStatusCmd.Flags().BoolVar(&poolStatusJSON, "json", false, "Emit machine-readable JSON output")
+	poolStatsCmd.Flags().BoolVar(&poolStatsJSON, "json", false, "Emit machine-readable JSON output")
+	poolStatsCmd.Flags().BoolVar(&poolStatsReset, "reset", false, "Clear collected counters and samples")
+	poolDrainCmd.Flags().BoolVar(&poolDrainForce, "force", false, "Also remove containers leased by running shims")
+
+	poolCmd.AddCommand(poolStatusCmd)
+	poolCmd.AddCommand(poolGCCmd)
+	poolCmd.AddCommand(poolDrainCmd)
+	poolCmd.AddCommand(poolWarmCmd)
+	poolCmd.AddCommand(poolStatsCmd)
+}
+
+// loadPoolRuntime loads config and returns a runtime with the warm pool
+// enabled; pool commands are meaningless otherwise.
+func loadPoolRuntime() (*config.Config, *sandbox.DockerRuntime, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.WarmPoolEnabled {
+		return nil, nil, fmt.Errorf("warm pool is disabled (warm_pool: false)")
+	}
+	return cfg, sandbox.New(cfg), nil
+}
+
+type poolStatusEntry struct {
+	ContainerID string    `json:"container_id"`
+	KeyHash     string    `json:"key_hash"`
+	Image       string    `json:"image"`
+	State       string    `json:"state"`
+	Workspace   string    `json:"workspace,omitempty"`
+	Locked      bool      `json:"locked"`
+	ActiveExecs int       `json:"active_execs"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsed    time.Time `json:"last_used"`
+	IdleSeconds int64     `json:"idle_seconds"`
+}
+
+func runPoolStatus(cmd *cobra.Command, _ []string) error {
+	_, sb, err := loadPoolRuntime()
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	statuses, err := sb.PoolStatus(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read pool status: %w", err)
+	}
+
+	entries := make([]poolStatusEntry, 0, len(statuses))
+	for _, st := range statuses {
+		entries = append(entries, poolStatusEntry{
+			ContainerID: st.ContainerID,
+			KeyHash:     st.KeyHash,
+			Image:       st.Image,
+			State:       st.State,
+			Workspace:   st.WorkspaceRoot,
+			Locked:      st.Locked,
+			ActiveExecs: st.ActiveExecs,
+			CreatedAt:   st.CreatedAt,
+			LastUsed:    st.LastUsed,
+			IdleSeconds: int64(st.IdleFor / time.Second),
+		})
+	}
+
+	out := cmd.OutOrStdout()
+	if poolStatusJSON {
+		payload, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode pool status: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(payload))
+		return nil
+	}
+
+	writePoolStatusTable(out, statuses)
+	return nil
+}
+
+func writePoolStatusTable(out io.Writer, statuses []pool.ContainerStatus) {
+	if len(statuses) == 0 {
+		_, _ = fmt.Fprintln(out, "No warm containers.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONTAINER\tKEY\tIMAGE\tSTATE\tACTIVE\tIDLE\tWORKSPACE")
+	for _, st := range statuses {
+		idle := "-"
+		if !st.LastUsed.IsZero() {
+			idle = st.IdleFor.Round(time.Second).String()
+		}
+		workspace := st.WorkspaceRoot
+		if workspace == "" {
+			workspace = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			shortContainerID(st.ContainerID), st.KeyHash, st.Image, st.State, st.ActiveExecs, idle, workspace)
+	}
+	_ = w.Flush()
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func runPoolGC(cmd *cobra.Command, _ []string) error {
+	_, sb, err := loadPoolRuntime()
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	removed, err := sb.PoolGC(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to run pool gc: %w", err)
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Removed %d warm container(s).\n", removed)
+	return nil
+}
+
+func runPoolDrain(cmd *cobra.Command, _ []string) error {
+	_, sb, err := loadPoolRuntime()
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	removed, err := sb.PoolDrain(context.Background(), poolDrainForce)
+	if err != nil {
+		return fmt.Errorf("failed to drain pool: %w", err)
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Removed %d warm container(s).\n", removed)
+	return nil
+}
+
+func runPoolWarm(cmd *cobra.Command, args []string) error {
+	cfg, sb, err := loadPoolRuntime()
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	binaryName := args[0]
+	shimGen, err := shim.Resolve(cfg, binaryName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("shim %q is not installed", binaryName)
+		}
+		return err
+	}
+	metadata, err := shimGen.LoadMetadata(binaryName)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata for %s: %w", binaryName, err)
+	}
+	if metadata.OutputImage == "" {
+		return fmt.Errorf("metadata for %s has no image", binaryName)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current working directory: %w", err)
+	}
+
+	profile, err := hardening.Resolve(cfg, metadata.Hardening)
+	if err != nil {
+		return err
+	}
+
+	// As in run, forwards are only honoured for a shim whose signature
+	// verifies.
+	var forwardSpecs []string
+	if len(metadata.Forward) > 0 {
+		if _, err := verifyShimMetadata(cfg, metadata, false); err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: not forwarding credentials for %s: %v\n", binaryName, err)
+		} else {
+			forwardSpecs = metadata.Forward
+		}
+	}
+	forwardMounts, err := resolveForward(cmd.ErrOrStderr(), forwardSpecs)
+	if err != nil {
+		return err
+	}
+
+	stateDir := ""
+	if metadata.State {
+		if stateDir, err = state.Ensure(cfg.StateDir, binaryName); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	spec := sandbox.MergeResourceSpec("", 0, cfg.DefaultMemory, cfg.DefaultCPUs)
+	resources, err := sb.ResolveResourceSpec(ctx, spec)
+	if err != nil {
+		return fmt.Errorf("failed to resolve resource limits: %w", err)
+	}
+
+	opts := sandbox.RunOptions{
+		Image:       metadata.OutputImage,
+		Binary:      binaryName,
+		Volumes:     runVolumeMounts(cfg, cwd, nil, false),
+		Runtime:     "docker",
+		MemoryLimit: resources.Memory,
+		CPULimit:    resources.CPUs,
+		Hardening:   profile,
+		Forward:     forwardMounts,
+		StateDir:    stateDir,
+	}
+	if err := sb.Prewarm(ctx, opts); err != nil {
+		return fmt.Errorf("failed to warm container for %s: %w", binaryName, err)
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Warm container ready for %s (%s).\n", binaryName, metadata.OutputImage)
+	return nil
+}
+
+type poolStatsReport struct {
+	stats.Counters
+	HitRate float64              `json:"hit_rate"`
+	Images  []stats.ImageSummary `json:"images"`
+}
+
+func runPoolStats(cmd *cobra.Command, _ []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if poolStatsReset {
+		if err := stats.Reset(cfg.StatsFile); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, "Pool stats reset.")
+		return nil
+	}
+
+	file, err := stats.Load(cfg.StatsFile)
+	if err != nil {
+		return err
+	}
+	report := poolStatsReport{Counters: file.Counters, Images: file.Summaries()}
+	if leases := file.PoolHits + file.PoolMisses; leases > 0 {
+		report.HitRate = float64(file.PoolHits) / float64(leases)
+	}
+
+	if poolStatsJSON {
+		payload, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode pool stats: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(payload))
+		return nil
+	}
+
+	writePoolStatsReport(out, report)
+	return nil
+}
+
+func writePoolStatsReport(out io.Writer, report poolStatsReport) {
+	_, _ = fmt.Fprintf(out, "Pool hits: %d  misses: %d  hit rate: %.0f%%\n", report.PoolHits, report.PoolMisses, report.HitRate*100)
+	_, _ = fmt.Fprintf(out, "Evictions: %d  cold-path fallbacks: %d\n", report.Evictions, report.Fallbacks)
+
+	if len(report.Images) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo shim runs recorded yet.")
+		return
+	}
+
+	_, _ = fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "IMAGE\tRUNS\tPOOL\tCOLD\tP50\tP95")
+	for _, img := range report.Images {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n",
+			img.Image, img.Runs, img.PoolRuns, img.ColdRuns, formatOverhead(img.P50), formatOverhead(img.P95))
+	}
+	_ = w.Flush()
+}
+
+func formatOverhead(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox/pool"
+	"github.com/c4rb0nx1/tuprwre/internal/stats"
+	"github.com/spf13/cobra"
+)
+
+func TestWritePoolStatusTable(t *testing.T) {
+	var out bytes.Buffer
+	writePoolStatusTable(&out, []pool.ContainerStatus{
+		{
+			ContainerID:   "0123456789abcdef0123",
+			KeyHash:       "deadbeefdeadbeef",
+			Image:         "tuprwre-node:latest",
+			State:         "running",
+			Locked:        true,
+			ActiveExecs:   2,
+			LastUsed:      time.Now().Add(-90 * time.Second),
+			IdleFor:       90 * time.Second,
+			WorkspaceRoot: "/src/app",
+		},
+		{
+			ContainerID: "feedface",
+			Image:       "tuprwre-jq:latest",
+			State:       "running",
+		},
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header + 2 rows, got %q", out.String())
+	}
+	for _, want := range []string{"0123456789ab", "deadbeefdeadbeef", " 2 ", "1m30s", "/src/app"} {
+		if !strings.Contains(lines[1], want) {
+			t.Fatalf("row %q missing %q", lines[1], want)
+		}
+	}
+	if strings.Contains(lines[1], "0123456789abc") {
+		t.Fatalf("container ID not shortened: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[len(fields)-1] != "-" || fields[len(fields)-2] != "-" {
+		t.Fatalf("missing placeholders for unknown idle/workspace: %q", lines[2])
+	}
+}
+
+func TestWritePoolStatusTableEmpty(t *testing.T) {
+	var out bytes.Buffer
+	writePoolStatusTable(&out, nil)
+	if got := out.String(); got != "No warm containers.\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPoolCommandsRequireWarmPool(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	t.Setenv("TUPRWRE_WARM_POOL", "false")
+	t.Chdir(t.TempDir())
+
+	if _, _, err := loadPoolRuntime(); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("expected warm pool disabled error, got %v", err)
+	}
+}
+
+func TestRunPoolStats(t *testing.T) {
+	baseDir := t.TempDir()
+	t.Setenv("TUPRWRE_DIR", baseDir)
+	t.Chdir(t.TempDir())
+
+	statsFile := filepath.Join(baseDir, "stats.json")
+	for _, run := range []stats.Run{
+		{Image: "tuprwre-jq:latest", Path: stats.PathPool, PoolMiss: true, Overhead: 300 * time.Millisecond},
+		{Image: "tuprwre-jq:latest", Path: stats.PathPool, PoolHit: true, Overhead: 30 * time.Millisecond},
+		{Image: "tuprwre-jq:latest", Path: stats.PathPool, PoolHit: true, Overhead: 35 * time.Millisecond},
+		{Image: "tuprwre-jq:latest", Path: stats.PathCold, Fallback: true, Overhead: 700 * time.Millisecond},
+	} {
+		if err := stats.Record(statsFile, run); err != nil {
+			t.Fatalf("stats.Record: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	if err := runPoolStats(cmd, nil); err != nil {
+		t.Fatalf("runPoolStats: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"hits: 2", "misses: 1", "hit rate: 67%", "fallbacks: 1", "tuprwre-jq:latest", "35ms", "700ms"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	poolStatsReset = true
+	t.Cleanup(func() { poolStatsReset = false })
+	out.Reset()
+	if err := runPoolStats(cmd, nil); err != nil {
+		t.Fatalf("runPoolStats --reset: %v", err)
+	}
+	if _, err := os.Stat(statsFile); !os.IsNotExist(err) {
+		t.Fatalf("expected stats file removed, stat err = %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/toolset"
+	"github.com/distribution/reference"
+	"github.com/spf13/cobra"
+)
+
+var publishTo string
+
+var publishCmd = &cobra.Command{
+	Use:   "publish <shim|image> --to <registry/ns/name:tag>",
+	Short: "Push an installed toolset to an OCI registry",
+	Long: `Tags the image behind a shim (or an image name) as the target reference
+with the toolset's shim metadata stored in the "tuprwre.toolset" image label,
+then pushes it. 'tuprwre install --from <ref>' pulls it and generates the
+shims without running any install command.`,
+	Example: `  tuprwre publish jq --to registry.example.com/tools/jq:1.7`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runPublish,
+}
+
+func init() {
+	publishCmd.Flags().StringVar(&publishTo, "to", "", "Registry reference to push to (registry/ns/name:tag)")
+	_ = publishCmd.MarkFlagRequired("to")
+}
+
+func runPublish(cmd *cobra.Command, args []string) error {
+	named, err := reference.ParseNormalizedNamed(publishTo)
+	if err != nil {
+		return fmt.Errorf("invalid --to reference %q: %w", publishTo, err)
+	}
+	if _, ok := named.(reference.Canonical); ok {
+		return fmt.Errorf("--to must be a tag, not a digest")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	_, manifest, err := toolset.Find(shim.Generators(cfg), args[0])
+	if err != nil {
+		return err
+	}
+	label, err := toolsetLabel(manifest)
+	if err != nil {
+		return err
+	}
+
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+	ctx := context.Background()
+
+	if err := docker.RelabelImage(ctx, manifest.Image, publishTo, map[string]string{sandbox.LabelToolset: label}); err != nil {
+		return err
+	}
+	digest, err := docker.PushImage(ctx, publishTo)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	_, _ = fmt.Fprintf(out, "Published %d shim(s) to %s\n", len(manifest.Shims), publishTo)
+	_, _ = fmt.Fprintf(out, "Digest: %s\n", digest)
+	_, _ = fmt.Fprintf(out, "Install with: tuprwre install --from %s@%s\n", named.Name(), digest)
+	return nil
+}
+
+// toolsetLabel encodes m for the tuprwre.toolset label, dropping fields that
+// only make sense on the publishing machine. Signatures are dropped too:
+// labelling changes the image ID they cover, and the registry digest takes
+// their place.
+func toolsetLabel(m toolset.Manifest) (string, error) {
+	m.ImageID = ""
+	shims := make([]shim.Metadata, len(m.Shims))
+	for i, meta := range m.Shims {
+		meta.Workspace = ""
+		meta.ImageID = ""
+		meta.Signature = nil
+		shims[i] = meta
+	}
+	m.Shims = shims
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode toolset metadata: %w", err)
+	}
+	return string(payload), nil
+}
+
+// installFromRegistry pulls a published toolset and generates its shims.
+// Shims are pinned to the pulled digest, so retagging in the registry does
+// not change what they run until the next update.
+func installFromRegistry(cmd *cobra.Command, cfg *config.Config, req installRequest) error {
+	scope := req.scope
+	if scope == "" {
+		scope = shim.DefaultScope(cfg)
+	}
+	shimGen, err := shim.NewScopedGenerator(cfg, scope)
+	if err != nil {
+		return err
+	}
+
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+	ctx := context.Background()
+
+	fmt.Printf("Pulling toolset %s...\n", req.from)
+	img, err := docker.PullRegistryImage(ctx, req.from)
+	if err != nil {
+		return err
+	}
+
+	raw := img.Labels[sandbox.LabelToolset]
+	if raw == "" {
+		return fmt.Errorf("%s is not a tuprwre toolset image (missing %s label)", req.from, sandbox.LabelToolset)
+	}
+	var manifest toolset.Manifest
+	if err := json.Unmarshal([]byte(raw), &manifest); err != nil {
+		return fmt.Errorf("failed to decode toolset metadata from %s: %w", req.from, err)
+	}
+	if manifest.Version != toolset.ManifestVersion || len(manifest.Shims) == 0 {
+		return fmt.Errorf("%s carries unsupported toolset metadata", req.from)
+	}
+
+	named, err := reference.ParseNormalizedNamed(req.from)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", req.from, err)
+	}
+	pinned := reference.FamiliarName(named) + "@" + img.Digest
+	if _, ok := named.(reference.Digested); ok {
+		fmt.Printf("Verified %s\n", req.from)
+	} else if err := confirmTagInstall(req.from, pinned); err != nil {
+		return err
+	}
+
+	// The digest was checked on pull, or the tag's digest confirmed, so the
+	// shims are signed locally against the pulled image.
+	signingKey, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		return err
+	}
+
+	manifest.Image = pinned
+	manifest.ImageID = img.ID
+	installedAt := time.Now().UTC().Format(time.RFC3339)
+	for i := range manifest.Shims {
+		manifest.Shims[i].InstallMode = "registry"
+		manifest.Shims[i].SourceRef = req.from
+		manifest.Shims[i].SourceDigest = img.Digest
+		manifest.Shims[i].InstalledAt = installedAt
+		if req.hardening != "" {
+			manifest.Shims[i].Hardening = req.hardening
+		}
+		// Credential forwarding, persistent state and host path mounts are
+		// the installer's decision, never the publisher's.
+		manifest.Shims[i].Forward = req.forward
+		manifest.Shims[i].State = req.state
+		manifest.Shims[i].PathPolicy = req.pathPolicy
+	}
+
+	out := cmd.OutOrStdout()
+	if evicted, err := docker.EvictPoolImages(ctx, []string{pinned}, false); err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: failed to evict warm containers for %s: %v\n", pinned, err)
+	} else if evicted > 0 {
+		_, _ = fmt.Fprintf(out, "Evicted %d warm container(s) for %s\n", evicted, pinned)
+	}
+	toolsetSBOM, err := scanToolset(ctx, out, cfg, docker, pinned, req.failOn)
+	if err != nil {
+		return err
+	}
+	saved, err := restoreToolsetShims(out, shimGen, cfg, manifest, req.force, signingKey)
+	if err != nil {
+		return err
+	}
+	saveToolsetSBOM(out, shimGen, toolsetSBOM, shimNames(saved))
+	return nil
+}
+
+// confirmTagInstall asks on the terminal before shims pulled by tag are
+// signed: nothing vouches for what the tag resolved to. Without a terminal
+// the install is refused in favour of the pinned reference.
+func confirmTagInstall(ref, pinned string) error {
+	tty, err := openTerminal()
+	if err != nil {
+		return fmt.Errorf("%s is a tag, so its image cannot be verified; install %s to trust that digest", ref, pinned)
+	}
+	defer tty.Close()
+
+	_, _ = fmt.Fprintf(tty, "tuprwre: %s resolved to %s, which is not verified against a known digest. Sign its shims? [y/N]: ", ref, pinned)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if answer = strings.TrimSpace(answer); answer != "y" && answer != "Y" {
+		return fmt.Errorf("install of %s cancelled; install %s to pin that digest", ref, pinned)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirmTagInstall(t *testing.T) {
+	const ref = "registry.example.com/tools/jq:1.7"
+	const pinned = "registry.example.com/tools/jq@sha256:aaa"
+
+	prevOpen := openTerminal
+	t.Cleanup(func() { openTerminal = prevOpen })
+
+	openTerminal = func() (io.ReadWriteCloser, error) { return nil, errors.New("no tty") }
+	if err := confirmTagInstall(ref, pinned); err == nil || !strings.Contains(err.Error(), pinned) {
+		t.Fatalf("without a terminal: err=%v, want a refusal naming %s", err, pinned)
+	}
+
+	prompts := &bytes.Buffer{}
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return fakeTerminal{Reader: strings.NewReader("y\n"), out: prompts}, nil
+	}
+	if err := confirmTagInstall(ref, pinned); err != nil {
+		t.Fatalf("confirmed: %v", err)
+	}
+	if !strings.Contains(prompts.String(), "not verified") {
+		t.Fatalf("unexpected prompt %q", prompts.String())
+	}
+
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return fakeTerminal{Reader: strings.NewReader("\n"), out: &bytes.Buffer{}}, nil
+	}
+	if err := confirmTagInstall(ref, pinned); err == nil {
+		t.Fatal("expected a declined prompt to cancel the install")
+	}
+}
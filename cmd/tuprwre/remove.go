@@ -12,8 +12,10 @@ import (
 )
 
 var (
-	removeAll    bool
-	removeImages bool
+	removeAll       bool
+	removeImages    bool
+	removeGlobal    bool
+	removeWorkspace bool
 )
 
 var removeCmd = &cobra.Command{
@@ -25,6 +27,26 @@ var removeCmd = &cobra.Command{
 func init() {
 	removeCmd.Flags().BoolVar(&removeAll, "all", false, "Remove all shims and metadata")
 	removeCmd.Flags().BoolVar(&removeImages, "images", false, "Remove Docker images referenced by shim metadata")
+	removeCmd.Flags().BoolVar(&removeGlobal, "global", false, "Only remove globally installed shims")
+	removeCmd.Flags().BoolVar(&removeWorkspace, "workspace", false, "Only remove shims installed in the current workspace")
+	removeCmd.MarkFlagsMutuallyExclusive("global", "workspace")
+}
+
+// removeScopeGenerators returns the shim scopes remove operates on, in
+// lookup order.
+func removeScopeGenerators(cfg *config.Config) ([]*shim.Generator, error) {
+	switch {
+	case removeGlobal:
+		return []*shim.Generator{shim.NewGenerator(cfg)}, nil
+	case removeWorkspace:
+		gen, err := shim.NewScopedGenerator(cfg, shim.ScopeWorkspace)
+		if err != nil {
+			return nil, err
+		}
+		return []*shim.Generator{gen}, nil
+	default:
+		return shim.Generators(cfg), nil
+	}
 }
 
 func runRemove(cmd *cobra.Command, args []string) error {
@@ -46,57 +68,65 @@ func runRemove(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	shimGen := shim.NewGenerator(cfg)
+	gens, err := removeScopeGenerators(cfg)
+	if err != nil {
+		return err
+	}
 	out := cmd.OutOrStdout()
 
 	if removeAll {
 		imageSet := make(map[string]struct{})
-		if removeImages {
-			metadataList, err := shimGen.ListAllMetadata()
+		removedShims := 0
+		for _, shimGen := range gens {
+			if removeImages {
+				metadataList, err := shimGen.ListAllMetadata()
+				if err != nil {
+					return fmt.Errorf("failed to list metadata: %w", err)
+				}
+				for _, metadata := range metadataList {
+					if metadata.OutputImage != "" {
+						imageSet[metadata.OutputImage] = struct{}{}
+					}
+				}
+			}
+
+			shims, err := shimGen.List()
 			if err != nil {
-				return fmt.Errorf("failed to list metadata: %w", err)
+				return fmt.Errorf("failed to list shims: %w", err)
 			}
-			for _, metadata := range metadataList {
-				if metadata.OutputImage != "" {
-					imageSet[metadata.OutputImage] = struct{}{}
+			for _, shimName := range shims {
+				if removeImages {
+					metadata, err := shimGen.LoadMetadata(shimName)
+					if err == nil && metadata.OutputImage != "" {
+						imageSet[metadata.OutputImage] = struct{}{}
+					}
 				}
-			}
-		}
 
-		shims, err := shimGen.List()
-		if err != nil {
-			return fmt.Errorf("failed to list shims: %w", err)
-		}
-		for _, shimName := range shims {
-			if removeImages {
-				metadata, err := shimGen.LoadMetadata(shimName)
-				if err == nil && metadata.OutputImage != "" {
-					imageSet[metadata.OutputImage] = struct{}{}
+				if err := shimGen.Remove(shimName); err != nil {
+					return fmt.Errorf("failed to remove shim %q: %w", shimName, err)
 				}
+				_ = shimGen.RemoveMetadata(shimName)
 			}
 
-			if err := shimGen.Remove(shimName); err != nil {
-				return fmt.Errorf("failed to remove shim %q: %w", shimName, err)
+			if _, err := shimGen.RemoveAllMetadata(); err != nil {
+				return fmt.Errorf("failed to remove metadata: %w", err)
 			}
-			_ = shimGen.RemoveMetadata(shimName)
+			removedShims += len(shims)
 		}
 
-		if _, err := shimGen.RemoveAllMetadata(); err != nil {
-			return fmt.Errorf("failed to remove metadata: %w", err)
-		}
-
-		if len(shims) == 0 {
+		if removedShims == 0 {
 			_, _ = fmt.Fprintln(out, "No shims installed")
 			return nil
 		}
 
-		_, _ = fmt.Fprintf(out, "Removed %d shims\n", len(shims))
+		_, _ = fmt.Fprintf(out, "Removed %d shims\n", removedShims)
 		if removeImages {
 			removedCount := 0
 			failedCount := 0
 			docker := sandbox.New(cfg)
 			defer docker.Close()
 			for imageName := range imageSet {
+				removeImageWarmContainers(cmd, docker, imageName)
 				if err := docker.RemoveImage(context.Background(), imageName); err != nil {
 					failedCount++
 					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to remove image %q: %v\n", imageName, err)
@@ -110,6 +140,17 @@ func runRemove(cmd *cobra.Command, args []string) error {
 	}
 
 	shimName := args[0]
+	var shimGen *shim.Generator
+	for _, gen := range gens {
+		if _, err := os.Stat(gen.GetPath(shimName)); err == nil {
+			shimGen = gen
+			break
+		}
+	}
+	if shimGen == nil {
+		return fmt.Errorf("shim %q not found", shimName)
+	}
+
 	imageName := ""
 	if removeImages {
 		metadata, err := shimGen.LoadMetadata(shimName)
@@ -130,6 +171,7 @@ func runRemove(cmd *cobra.Command, args []string) error {
 	if removeImages && imageName != "" {
 		docker := sandbox.New(cfg)
 		defer docker.Close()
+		removeImageWarmContainers(cmd, docker, imageName)
 		if err := docker.RemoveImage(context.Background(), imageName); err != nil {
 			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to remove image %q: %v\n", imageName, err)
 		}
@@ -138,3 +180,11 @@ func runRemove(cmd *cobra.Command, args []string) error {
 	_, _ = fmt.Fprintln(out, "Removed shim:", shimName)
 	return nil
 }
+
+// removeImageWarmContainers evicts warm pool containers that would otherwise
+// keep imageName in use and block its removal.
+func removeImageWarmContainers(cmd *cobra.Command, docker *sandbox.DockerRuntime, imageName string) {
+	if _, err := docker.EvictPoolImages(context.Background(), []string{imageName}, true); err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to evict warm containers for %q: %v\n", imageName, err)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/record"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayKeep bool
+	replayEnv  []string
+)
+
+// replayDiffLines caps the diff printed per stream, and replayDiffCells the
+// size of the table computing it.
+const (
+	replayDiffLines = 40
+	replayDiffCells = 4 << 20
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <record-id|dir>",
+	Short: "Re-execute a recorded run and compare its output",
+	Long: `Re-executes a run recorded with 'tuprwre run --record' against the same
+image, with the recorded argv, environment and stdin. When the record holds a
+workspace snapshot, the run gets a scratch copy of it mounted where the
+workspace was; the real workspace is never mounted.
+
+The exit code, stdout and stderr are compared with the recording. replay
+exits non-zero and prints a diff when they differ.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func init() {
+	replayCmd.Flags().BoolVar(&replayKeep, "keep", false, "Keep the scratch workspace and print its path")
+	replayCmd.Flags().StringArrayVarP(&replayEnv, "env", "e", nil, "Set an environment variable, overriding the recorded one (e.g., a masked credential)")
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	dir, err := resolveRecordDir(cfg, args[0])
+	if err != nil {
+		return err
+	}
+	rec, err := record.Load(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load record: %w", err)
+	}
+	if len(rec.Argv) == 0 {
+		return fmt.Errorf("record %s has no command", args[0])
+	}
+	// The state directory and credentials have moved on since the run;
+	// replaying against their current contents would not reproduce it.
+	if rec.State {
+		return fmt.Errorf("record %s ran with the shim's persistent state directory, which replay cannot reproduce", args[0])
+	}
+	if rec.Forwarded {
+		return fmt.Errorf("record %s ran with forwarded credential files, which replay cannot reproduce", args[0])
+	}
+	pathMounts, err := replayPathMounts(rec.PathMounts)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", args[0], err)
+	}
+
+	profile, err := hardening.Resolve(cfg, rec.Hardening)
+	if err != nil {
+		return err
+	}
+
+	scratch, err := os.MkdirTemp("", "tuprwre-replay-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch workspace: %w", err)
+	}
+	out := cmd.OutOrStdout()
+	if replayKeep {
+		_, _ = fmt.Fprintf(out, "Scratch workspace: %s\n", scratch)
+	} else {
+		defer os.RemoveAll(scratch)
+	}
+
+	errOut := cmd.ErrOrStderr()
+	var volumes []string
+	for _, v := range rec.Volumes {
+		parts := strings.Split(v, ":")
+		if rec.Snapshot != nil && len(parts) >= 2 && parts[1] == rec.Snapshot.Root {
+			continue
+		}
+		_, _ = fmt.Fprintf(errOut, "Warning: not mounting %s; replay mounts only the workspace snapshot and argument paths\n", v)
+	}
+	if rec.Snapshot != nil {
+		if err := record.Restore(dir, scratch); err != nil {
+			return fmt.Errorf("failed to restore workspace snapshot: %w", err)
+		}
+		volumes = append(volumes, scratch+":"+rec.Snapshot.Root)
+		if rec.Snapshot.Skipped > 0 {
+			// The tool may read them; a diff can come from their absence.
+			_, _ = fmt.Fprintf(errOut, "Warning: the workspace snapshot left out %d file(s) over its size cap (see skipped_paths in %s)\n",
+				rec.Snapshot.Skipped, filepath.Join(dir, record.File))
+		}
+	}
+
+	stdin, err := os.Open(filepath.Join(dir, record.StdinFile))
+	if err != nil {
+		return fmt.Errorf("failed to open recorded stdin: %w", err)
+	}
+	defer stdin.Close()
+
+	// Run by image ID so a moved tag still replays the recorded image.
+	image := rec.ImageID
+	if image == "" {
+		image = rec.Image
+	}
+	var stdout, stderr bytes.Buffer
+	opts := sandbox.RunOptions{
+		Image:      image,
+		Binary:     rec.Argv[0],
+		Args:       rec.Argv[1:],
+		WorkDir:    rec.WorkDir,
+		Env:        mergeEnv(rec.Env, replayEnv),
+		Volumes:    volumes,
+		PathMounts: pathMounts,
+		Stdin:      stdin,
+		Stdout:     &stdout,
+		Stderr:     &stderr,
+		NoNetwork:  rec.NoNetwork,
+		NoPool:     true,
+		Hardening:  profile,
+	}
+	exitCode, err := sandbox.New(cfg).RunContext(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("sandbox execution failed: %w", err)
+	}
+
+	same := true
+	if exitCode != rec.ExitCode {
+		same = false
+		_, _ = fmt.Fprintf(out, "exit code: recorded %d, replayed %d\n", rec.ExitCode, exitCode)
+	}
+	for _, s := range []struct {
+		name string
+		got  []byte
+	}{{record.StdoutFile, stdout.Bytes()}, {record.StderrFile, stderr.Bytes()}} {
+		want, err := os.ReadFile(filepath.Join(dir, s.name))
+		if err != nil {
+			return fmt.Errorf("failed to read recorded %s: %w", s.name, err)
+		}
+		if !bytes.Equal(want, s.got) {
+			same = false
+			writeLineDiff(out, s.name, string(want), string(s.got), replayDiffLines)
+		}
+	}
+	if !same {
+		return errors.New("replay differs from the recording")
+	}
+	_, _ = fmt.Fprintln(out, "Replay matches the recording.")
+	return nil
+}
+
+// replayPathMounts returns the recorded argument path binds, read-only,
+// checking that their host directories still exist. They are live host
+// directories, not copies: the files in them may have changed since.
+func replayPathMounts(recorded []string) ([]string, error) {
+	mounts := make([]string, 0, len(recorded))
+	for _, m := range recorded {
+		parts := strings.Split(m, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("malformed path mount %q", m)
+		}
+		if info, err := os.Stat(parts[0]); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("host directory %s, mounted for an argument path, no longer exists", parts[0])
+		}
+		mounts = append(mounts, parts[0]+":"+parts[1]+":ro")
+	}
+	return mounts, nil
+}
+
+// resolveRecordDir finds the record named by arg: a record directory, or
+// an ID under cfg.RecordsDir.
+func resolveRecordDir(cfg *config.Config, arg string) (string, error) {
+	if _, err := os.Stat(filepath.Join(arg, record.File)); err == nil {
+		return arg, nil
+	}
+	if !strings.ContainsRune(arg, filepath.Separator) {
+		dir := filepath.Join(cfg.RecordsDir, arg)
+		if _, err := os.Stat(filepath.Join(dir, record.File)); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no record %q (looked for a record directory and in %s)", arg, cfg.RecordsDir)
+}
+
+// mergeEnv returns base with the variables of overrides replaced or added.
+func mergeEnv(base, overrides []string) []string {
+	merged := append([]string{}, base...)
+	for _, kv := range overrides {
+		key, _, _ := strings.Cut(kv, "=")
+		replaced := false
+		for i, existing := range merged {
+			if k, _, _ := strings.Cut(existing, "="); k == key {
+				merged[i] = kv
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, kv)
+		}
+	}
+	return merged
+}
+
+// writeLineDiff prints a line diff of want and got, labelled name, of at
+// most maxLines changed lines.
+func writeLineDiff(w io.Writer, name, want, got string, maxLines int) {
+	a, b := splitLines(want), splitLines(got)
+	_, _ = fmt.Fprintf(w, "--- recorded %s\n+++ replayed %s\n", name, name)
+	if len(a)*len(b) > replayDiffCells {
+		_, _ = fmt.Fprintf(w, "(%d lines recorded, %d replayed; too long to diff)\n", len(a), len(b))
+		return
+	}
+
+	// Longest common subsequence table, built from the end.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	printed := 0
+	emit := func(prefix, line string) bool {
+		if printed == maxLines {
+			_, _ = fmt.Fprintln(w, "...")
+			printed++
+		}
+		if printed > maxLines {
+			return false
+		}
+		_, _ = fmt.Fprintf(w, "%s%s\n", prefix, line)
+		printed++
+		return true
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			if !emit("-", a[i]) {
+				return
+			}
+			i++
+		default:
+			if !emit("+", b[j]) {
+				return
+			}
+			j++
+		}
+	}
+	if printed == 0 {
+		_, _ = fmt.Fprintln(w, "(differs only in the final newline)")
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
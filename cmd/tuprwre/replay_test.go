@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/record"
+)
+
+func TestResolveRecordDir(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	id, dir, err := runRecordTarget(cfg, recordAuto)
+	if err != nil || id == "" || dir != filepath.Join(cfg.RecordsDir, id) {
+		t.Fatalf("runRecordTarget(auto) = %q, %q, %v", id, dir, err)
+	}
+	streams, err := record.Create(dir)
+	if err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	streams.Close()
+	if err := record.Write(dir, record.Record{Argv: []string{"jq", "."}}); err != nil {
+		t.Fatalf("write record: %v", err)
+	}
+
+	if got, err := resolveRecordDir(cfg, id); err != nil || got != dir {
+		t.Fatalf("resolveRecordDir(id) = %q, %v; want %q", got, err, dir)
+	}
+	if got, err := resolveRecordDir(cfg, dir); err != nil || got != dir {
+		t.Fatalf("resolveRecordDir(dir) = %q, %v; want %q", got, err, dir)
+	}
+	if _, err := resolveRecordDir(cfg, "20990101-000000-abcdef"); err == nil {
+		t.Fatal("expected an unknown record ID to fail")
+	}
+
+	t.Chdir(t.TempDir())
+	if _, dir, err := runRecordTarget(cfg, "out"); err != nil || !filepath.IsAbs(dir) {
+		t.Fatalf("runRecordTarget(out) = %q, %v; want an absolute directory", dir, err)
+	}
+	if id, dir, err := runRecordTarget(cfg, ""); id != "" || dir != "" || err != nil {
+		t.Fatalf("runRecordTarget(\"\") = %q, %q, %v; want no record", id, dir, err)
+	}
+}
+
+func TestReplayRefusesUnreproducibleMounts(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	for _, tc := range []struct {
+		rec  record.Record
+		want string
+	}{
+		{record.Record{Argv: []string{"npm", "ci"}, State: true}, "persistent state directory"},
+		{record.Record{Argv: []string{"git", "fetch"}, Forwarded: true}, "forwarded credential files"},
+		{record.Record{Argv: []string{"jq", "."}, PathMounts: []string{"/nonexistent/downloads:/run/tuprwre/host/nonexistent/downloads:ro"}}, "no longer exists"},
+	} {
+		dir := t.TempDir()
+		if err := record.Write(dir, tc.rec); err != nil {
+			t.Fatalf("write record: %v", err)
+		}
+		if err := runReplay(replayCmd, []string{dir}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("runReplay(%v) = %v; want an error mentioning %q", tc.rec.Argv, err, tc.want)
+		}
+	}
+}
+
+func TestReplayPathMounts(t *testing.T) {
+	downloads := t.TempDir()
+	got, err := replayPathMounts([]string{downloads + ":/run/tuprwre/host" + downloads + ":ro"})
+	if err != nil {
+		t.Fatalf("replayPathMounts: %v", err)
+	}
+	if want := []string{downloads + ":/run/tuprwre/host" + downloads + ":ro"}; !slices.Equal(got, want) {
+		t.Fatalf("replayPathMounts = %v, want %v", got, want)
+	}
+	if _, err := replayPathMounts([]string{"malformed"}); err == nil {
+		t.Fatal("expected a malformed path mount to fail")
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	got := mergeEnv([]string{"A=1", "TOKEN=***"}, []string{"TOKEN=secret", "B=2"})
+	if want := []string{"A=1", "TOKEN=secret", "B=2"}; !slices.Equal(got, want) {
+		t.Fatalf("mergeEnv = %v, want %v", got, want)
+	}
+}
+
+func TestWriteLineDiff(t *testing.T) {
+	var buf bytes.Buffer
+	writeLineDiff(&buf, "stdout", "a\nb\nc\n", "a\nx\nc\nd\n", 10)
+	want := "--- recorded stdout\n+++ replayed stdout\n-b\n+x\n+d\n"
+	if buf.String() != want {
+		t.Fatalf("diff = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeLineDiff(&buf, "stdout", "", strings.Repeat("line\n", 5), 2)
+	if got := strings.Count(buf.String(), "+line"); got != 2 || !strings.Contains(buf.String(), "...") {
+		t.Fatalf("expected a diff cut at 2 lines, got %q", buf.String())
+	}
+
+	buf.Reset()
+	writeLineDiff(&buf, "stderr", "a", "a\n", 10)
+	if !strings.Contains(buf.String(), "final newline") {
+		t.Fatalf("expected a final-newline note, got %q", buf.String())
+	}
+}
@@ -42,8 +42,19 @@ func init() {
 	rootCmd.AddCommand(removeCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(envCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(shellCmd)
 	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(poolCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(keyCmd)
+	rootCmd.AddCommand(sbomCmd)
+	rootCmd.AddCommand(auditVulnsCmd)
+	rootCmd.AddCommand(stateCmd)
+	rootCmd.AddCommand(replayCmd)
 }
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/c4rb0nx1/tuprwre/internal/argpath"
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/daemon"
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/record"
 	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
 	"github.com/c4rb0nx1/tuprwre/internal/sandbox/pool"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
 )
 
@@ -21,12 +33,22 @@ var (
 	runDebugIO        bool
 	runDebugIOJSON    bool
 	runCaptureFile    string
+	runCaptureStdout  string
+	runCaptureStderr  string
+	runRecordDir      string
+	runRecordSnapshot bool
+	runSnapshotMax    string
 	runReadOnlyCwd    bool
 	runNoNetwork      bool
 	runMemoryLimit    string
 	runCPULimit       float64
 	runNoPool         bool
 	runContainerID    string
+	runAllowUnsigned  bool
+	runHardening      string
+	runForward        []string
+	runState          bool
+	runPathPolicy     string
 	// For Containerd migration (future)
 	runRuntime string
 )
@@ -60,6 +82,12 @@ func init() {
 	runCmd.Flags().BoolVar(&runDebugIO, "debug-io", false, "Print human-readable container I/O lifecycle diagnostics")
 	runCmd.Flags().BoolVar(&runDebugIOJSON, "debug-io-json", false, "Emit container I/O diagnostics as NDJSON (optional JSON mode)")
 	runCmd.Flags().StringVar(&runCaptureFile, "capture-file", "", "Write combined stdout/stderr stream to a file")
+	runCmd.Flags().StringVar(&runCaptureStdout, "capture-stdout", "", "Write the stdout stream to a file")
+	runCmd.Flags().StringVar(&runCaptureStderr, "capture-stderr", "", "Write the stderr stream to a file")
+	runCmd.Flags().StringVar(&runRecordDir, "record", "", "Record the run: stdin, stdout, stderr and record.json, into ~/.tuprwre/records/<id> or --record=<dir>")
+	runCmd.Flags().Lookup("record").NoOptDefVal = recordAuto
+	runCmd.Flags().BoolVar(&runRecordSnapshot, "record-snapshot", false, "Copy the workspace into the record before the run, for tuprwre replay")
+	runCmd.Flags().StringVar(&runSnapshotMax, "record-snapshot-max", "64m", "Size cap of the --record-snapshot copy; files beyond it are left out")
 	runCmd.Flags().BoolVar(&runReadOnlyCwd, "read-only-cwd", false, "Mount working directory as read-only inside the container")
 	runCmd.Flags().BoolVar(&runNoNetwork, "no-network", false, "Disable network access inside the container")
 	runCmd.Flags().StringVar(&runMemoryLimit, "memory", "", "Memory limit for the container (e.g. 512m, 1g)")
@@ -67,11 +95,20 @@ func init() {
 	runCmd.Flags().BoolVar(&runNoPool, "no-pool", false, "Disable warm container pool, use cold path")
 	runCmd.Flags().StringVar(&runContainerID, "container-id", "", "Run command in an existing container via exec (debug/testing)")
 	_ = runCmd.Flags().MarkHidden("container-id")
+	runCmd.Flags().BoolVar(&runAllowUnsigned, "allow-unsigned", false, "Run shims whose metadata is unsigned or signed by an untrusted key")
+	runCmd.Flags().StringVar(&runHardening, "hardening", "", "Hardening profile (default|strict|custom; default: the shim's, then the configured profile)")
+
+	runCmd.Flags().StringArrayVar(&runForward, "forward", nil, "Forward host credentials instead of the shim's: ssh-agent, gitconfig, kubeconfig, file=PATH[,env=VAR] or socket=PATH[,env=VAR] (repeatable)")
+
+	runCmd.Flags().BoolVar(&runState, "state", false, "Mount the binary's persistent state directory as $HOME (default: the shim's setting)")
+
+	runCmd.Flags().StringVar(&runPathPolicy, "path-policy", "", "What to do with arguments naming host paths outside the workspace (ask|mount|refuse|off; default: the shim's, then the configured policy)")
 
 	_ = runCmd.MarkFlagRequired("image")
 }
 
 func runSandboxed(cmd *cobra.Command, args []string) error {
+	invokedAt := time.Now()
 	if len(args) == 0 {
 		return fmt.Errorf("no binary specified")
 	}
@@ -79,6 +116,15 @@ func runSandboxed(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// --record takes its directory only as --record=<dir>; a separate
+	// word would be taken for the binary.
+	if runRecordDir == recordAuto && cmd.ArgsLenAtDash() > 0 {
+		return fmt.Errorf("--record takes a directory only as --record=<dir>")
+	}
+	if runRecordSnapshot && runRecordDir == "" {
+		return fmt.Errorf("--record-snapshot requires --record")
+	}
+
 	binaryName := args[0]
 	binaryArgs := args[1:]
 
@@ -87,8 +133,50 @@ func runSandboxed(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	configLoadedAt := time.Now()
+
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
+	// The metadata of the shim owning the run is loaded and verified once;
+	// nil when no shim owns it or the run execs into a container.
+	var meta *shim.Metadata
+	expectedImageID := ""
+	if runContainerID == "" {
+		allowUnsigned := runAllowUnsigned || os.Getenv("TUPRWRE_ALLOW_UNSIGNED") == "1"
+		meta, expectedImageID, err = verifyRunProvenance(cfg, binaryName, runContainerImage, allowUnsigned)
+		if err != nil {
+			return err
+		}
+	}
+	verified := expectedImageID != ""
 
 	// Setup sandbox
+	hardeningName := runHardening
+	if hardeningName == "" {
+		hardeningName = shimHardening(meta)
+	}
+	profile, err := hardening.Resolve(cfg, hardeningName)
+	if err != nil {
+		return err
+	}
+
+	forwardSpecs := runForward
+	if len(forwardSpecs) == 0 {
+		forwardSpecs = shimForward(meta, verified)
+	}
+	forwardMounts, err := resolveForward(os.Stderr, forwardSpecs)
+	if err != nil {
+		return err
+	}
+
+	stateDir := ""
+	if runState || shimState(meta) {
+		if stateDir, err = state.Ensure(cfg.StateDir, binaryName); err != nil {
+			return err
+		}
+	}
+
 	sb := sandbox.New(cfg)
 
 	// Get current working directory for host mount
@@ -103,20 +191,37 @@ func runSandboxed(cmd *cobra.Command, args []string) error {
 		workDir = cwd
 	}
 
-	volumes := append([]string{}, runVolumes...)
-	mountRoot := cwd
-	if cfg.WorkspaceRoot != "" && pathIsInside(cwd, cfg.WorkspaceRoot) {
-		mountRoot = cfg.WorkspaceRoot
+	volumes := runVolumeMounts(cfg, cwd, runVolumes, runReadOnlyCwd)
+
+	recordID, recordDir, err := runRecordTarget(cfg, runRecordDir)
+	if err != nil {
+		return err
 	}
-	mountRoot = pool.CanonicalizePath(mountRoot)
-	cwdMount := fmt.Sprintf("%s:%s", mountRoot, mountRoot)
-	if runReadOnlyCwd {
-		cwdMount += ":ro"
+	recordSnapshot := ""
+	var snapshotMax int64
+	if runRecordSnapshot {
+		recordSnapshot = runMountRoot(cfg, cwd)
+		if snapshotMax, err = units.RAMInBytes(runSnapshotMax); err != nil {
+			return fmt.Errorf("invalid --record-snapshot-max %q: %w", runSnapshotMax, err)
+		}
+	}
+
+	// Arguments naming host files the container cannot see; an exec into
+	// an existing container cannot add mounts.
+	var pathMounts []string
+	if runContainerID == "" {
+		policy, err := runArgPathPolicy(cfg, meta, verified)
+		if err != nil {
+			return err
+		}
+		binaryArgs, pathMounts, err = applyPathPolicy(policy, binaryName, binaryArgs, cwd, sameMountDirs(volumes))
+		if err != nil {
+			return err
+		}
 	}
-	volumes = append(volumes, cwdMount)
 
 	// Resolve resource limits: CLI flags override config defaults
-	ctx := context.Background()
+	ctx := telemetry.FromEnv(context.Background())
 	spec := sandbox.MergeResourceSpec(runMemoryLimit, runCPULimit, cfg.DefaultMemory, cfg.DefaultCPUs)
 	resources, err := sb.ResolveResourceSpec(ctx, spec)
 	if err != nil {
@@ -144,18 +249,242 @@ func runSandboxed(cmd *cobra.Command, args []string) error {
 		MemoryLimit: resources.Memory,
 		CPULimit:    resources.CPUs,
 		NoPool:      runNoPool,
+
+		InvokedAt:      invokedAt,
+		ConfigLoadedAt: configLoadedAt,
+		TraceParent:    telemetry.TraceParent(ctx),
+
+		ExpectedImageID: expectedImageID,
+		Hardening:       profile,
+		Forward:         forwardMounts,
+		StateDir:        stateDir,
+		PathMounts:      pathMounts,
+
+		CaptureStdout:     runCaptureStdout,
+		CaptureStderr:     runCaptureStderr,
+		RecordDir:         recordDir,
+		RecordSnapshot:    recordSnapshot,
+		RecordSnapshotMax: snapshotMax,
 	}
 
-	// Execute in sandbox
-	exitCode, err := sb.Run(opts)
+	// Prefer a running daemon; fall back to executing in-process.
+	exitCode, handled, err := runViaDaemon(cfg, opts)
+	if !handled {
+		exitCode, err = sb.RunContext(ctx, opts)
+	}
 	if err != nil {
 		return fmt.Errorf("sandbox execution failed: %w", err)
 	}
+	if recordID != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "tuprwre: recorded run %s (tuprwre replay %s)\n", recordID, recordID)
+	}
 
+	flushTraces()
 	os.Exit(exitCode)
 	return nil
 }
 
+// shimHardening returns the hardening profile the shim owning a run was
+// installed with, or "" for the configured one.
+func shimHardening(meta *shim.Metadata) string {
+	if meta == nil {
+		return ""
+	}
+	return meta.Hardening
+}
+
+// shimForward returns the credential forwards of the shim owning a run.
+// Forwarding needs verified metadata, so a shim run with --allow-unsigned
+// gets none.
+func shimForward(meta *shim.Metadata, verified bool) []string {
+	if meta == nil || len(meta.Forward) == 0 {
+		return nil
+	}
+	if !verified {
+		_, _ = fmt.Fprintf(os.Stderr, "tuprwre: not forwarding credentials to unverified shim %q\n", meta.BinaryName)
+		return nil
+	}
+	return meta.Forward
+}
+
+// shimState reports whether the shim owning a run was installed with a
+// persistent state directory.
+func shimState(meta *shim.Metadata) bool {
+	return meta != nil && meta.State
+}
+
+// runArgPathPolicy returns the path policy for a run: --path-policy, then
+// the shim's, then the configured one. A shim's policy needs verified
+// metadata, since "mount" exposes host directories.
+func runArgPathPolicy(cfg *config.Config, meta *shim.Metadata, verified bool) (argpath.Policy, error) {
+	name := runPathPolicy
+	if name == "" && verified && meta != nil {
+		name = meta.PathPolicy
+	}
+	if name == "" {
+		name = cfg.PathPolicy
+	}
+	policy, err := argpath.ParsePolicy(name)
+	if err != nil || policy != "" {
+		return policy, err
+	}
+	return argpath.Ask, nil
+}
+
+// openTerminal opens the controlling terminal for the ask policy's prompt;
+// stdin belongs to the tool.
+var openTerminal = func() (io.ReadWriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// applyPathPolicy finds the arguments naming host paths outside mounted
+// and handles them per policy. It returns the arguments to run with and
+// the read-only binds they need.
+func applyPathPolicy(policy argpath.Policy, binaryName string, args []string, cwd string, mounted []string) ([]string, []string, error) {
+	if policy == argpath.Off {
+		return args, nil, nil
+	}
+	outside := argpath.Find(args, cwd, mounted)
+	if len(outside) == 0 {
+		return args, nil, nil
+	}
+
+	switch policy {
+	case argpath.Mount:
+		translated, binds := argpath.Translate(args, outside)
+		return translated, binds, nil
+	case argpath.Refuse:
+		return nil, nil, fmt.Errorf("%s is outside the mounted workspace and not visible to %s (use --path-policy mount to mount it read-only)", outside[0].Path, binaryName)
+	}
+
+	tty, err := openTerminal()
+	if err != nil {
+		for _, o := range outside {
+			_, _ = fmt.Fprintf(os.Stderr, "tuprwre: not mounting %s for %s: outside the workspace (path policy ask, no terminal)\n", o.Path, binaryName)
+		}
+		return args, nil, nil
+	}
+	defer tty.Close()
+
+	reader := bufio.NewReader(tty)
+	answers := make(map[string]bool)
+	var accepted []argpath.Outside
+	for _, o := range outside {
+		ok, asked := answers[o.Dir]
+		if !asked {
+			_, _ = fmt.Fprintf(tty, "tuprwre: %s is outside the workspace. Mount %s read-only for %s? [y/N]: ", o.Path, o.Dir, binaryName)
+			answer, err := reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return nil, nil, fmt.Errorf("failed to read confirmation: %w", err)
+			}
+			answer = strings.TrimSpace(answer)
+			ok = answer == "y" || answer == "Y"
+			answers[o.Dir] = ok
+		}
+		if ok {
+			accepted = append(accepted, o)
+		}
+	}
+	translated, binds := argpath.Translate(args, accepted)
+	return translated, binds, nil
+}
+
+// sameMountDirs returns the host directories of volumes mounted at their
+// own path, which arguments can name unchanged.
+func sameMountDirs(volumes []string) []string {
+	var dirs []string
+	for _, v := range volumes {
+		parts := strings.Split(v, ":")
+		if len(parts) >= 2 && parts[0] == parts[1] {
+			dirs = append(dirs, parts[0])
+		}
+	}
+	return dirs
+}
+
+// resolveForward resolves forward specs for a run, noting on w the ones
+// whose source is unavailable.
+func resolveForward(w io.Writer, specs []string) (forward.Mounts, error) {
+	mounts, skipped, err := forward.Resolve(specs)
+	if err != nil {
+		return forward.Mounts{}, err
+	}
+	for _, err := range skipped {
+		_, _ = fmt.Fprintf(w, "tuprwre: not forwarding %v\n", err)
+	}
+	return mounts, nil
+}
+
+// runViaDaemon hands the run to `tuprwre daemon` when one is reachable.
+// handled is false when the caller should execute in-process instead.
+func runViaDaemon(cfg *config.Config, opts sandbox.RunOptions) (exitCode int, handled bool, err error) {
+	if opts.ContainerID != "" || os.Getenv("TUPRWRE_NO_DAEMON") == "1" {
+		return 0, false, nil
+	}
+
+	client, dialErr := daemon.Dial(cfg.DaemonSocket)
+	if dialErr != nil {
+		return 0, false, nil
+	}
+	defer client.Close()
+
+	// The daemon resolves paths against its own working directory.
+	for _, path := range []*string{&opts.CaptureFile, &opts.CaptureStdout, &opts.CaptureStderr, &opts.RecordDir} {
+		if *path == "" {
+			continue
+		}
+		if abs, absErr := filepath.Abs(*path); absErr == nil {
+			*path = abs
+		}
+	}
+
+	exitCode, err = client.Run(opts, os.Stdin, os.Stdout, os.Stderr)
+	return exitCode, true, err
+}
+
+// recordAuto is the --record value given without a directory.
+const recordAuto = "auto"
+
+// runRecordTarget resolves --record to the record directory. A record
+// without a directory gets a new ID under cfg.RecordsDir; id is empty
+// for an explicit directory.
+func runRecordTarget(cfg *config.Config, flag string) (id, dir string, err error) {
+	switch flag {
+	case "":
+		return "", "", nil
+	case recordAuto:
+		id = record.NewID()
+		return id, filepath.Join(cfg.RecordsDir, id), nil
+	}
+	if dir, err = filepath.Abs(flag); err != nil {
+		return "", "", fmt.Errorf("failed to resolve record directory: %w", err)
+	}
+	return "", dir, nil
+}
+
+// runMountRoot returns the directory mounted for a run from cwd: the
+// workspace root, or cwd outside a workspace.
+func runMountRoot(cfg *config.Config, cwd string) string {
+	mountRoot := cwd
+	if cfg.WorkspaceRoot != "" && pathIsInside(cwd, cfg.WorkspaceRoot) {
+		mountRoot = cfg.WorkspaceRoot
+	}
+	return pool.CanonicalizePath(mountRoot)
+}
+
+// runVolumeMounts returns the bind mounts for a run from cwd: the extra
+// volumes followed by the workspace root (or cwd outside a workspace)
+// mounted at the same path.
+func runVolumeMounts(cfg *config.Config, cwd string, extra []string, readOnly bool) []string {
+	volumes := append([]string{}, extra...)
+	mountRoot := runMountRoot(cfg, cwd)
+	cwdMount := fmt.Sprintf("%s:%s", mountRoot, mountRoot)
+	if readOnly {
+		cwdMount += ":ro"
+	}
+	return append(volumes, cwdMount)
+}
+
 func validateRunRuntime(runtime string) error {
 	switch strings.ToLower(strings.TrimSpace(runtime)) {
 	case "docker":
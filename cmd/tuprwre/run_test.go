@@ -1,8 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/argpath"
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
 )
 
 func TestRunRuntimeValidation(t *testing.T) {
@@ -33,3 +44,240 @@ func TestRunRuntimeValidation(t *testing.T) {
 		}
 	})
 }
+
+func TestShimHardening(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if owner, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err != nil || owner != nil {
+		t.Fatalf("unowned run: meta=%v err=%v", owner, err)
+	}
+	if name := shimHardening(nil); name != "" {
+		t.Fatalf("unowned run: name=%q", name)
+	}
+
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	meta := shim.Metadata{BinaryName: "jq", OutputImage: "tuprwre-jq:1", Hardening: "strict"}
+	if err := signMetadata(key, &meta, "sha256:aaa"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+	owner, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if name := shimHardening(owner); name != "strict" {
+		t.Fatalf("shimHardening = %q; want strict", name)
+	}
+
+	// The profile is covered by the signature.
+	meta.Hardening = "default"
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "jq", "tuprwre-jq:1", false); err == nil {
+		t.Fatal("expected a downgraded profile to fail signature verification")
+	}
+
+	if _, err := resolveInstallHardening(cfg, "paranoid"); err == nil {
+		t.Fatal("expected an unknown --hardening to fail")
+	}
+	if name, err := resolveInstallHardening(cfg, "STRICT"); err != nil || name != "strict" {
+		t.Fatalf("resolveInstallHardening = %q, %v; want strict", name, err)
+	}
+}
+
+func TestShimForward(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	meta := shim.Metadata{BinaryName: "git", OutputImage: "tuprwre-git:1", Forward: []string{"ssh-agent", "gitconfig"}}
+	if err := signMetadata(key, &meta, "sha256:aaa"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+
+	if specs := shimForward(&meta, true); len(specs) != 2 {
+		t.Fatalf("shimForward = %v; want the recorded forwards", specs)
+	}
+	if specs := shimForward(&meta, false); specs != nil {
+		t.Fatalf("shimForward(unverified) = %v; want none", specs)
+	}
+	if specs := shimForward(nil, true); specs != nil {
+		t.Fatalf("shimForward(unowned) = %v; want none", specs)
+	}
+
+	// Forwarding is covered by the signature.
+	meta.Forward = append(meta.Forward, "file=~/.aws/credentials")
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "git", "tuprwre-git:1", false); err == nil {
+		t.Fatal("expected added forwarding to fail signature verification")
+	}
+}
+
+func TestShimState(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	key, err := provenance.LoadOrCreateKey(cfg.BaseDir)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	meta := shim.Metadata{BinaryName: "npm", OutputImage: "tuprwre-npm:1", State: true}
+	if err := signMetadata(key, &meta, "sha256:aaa"); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+
+	owner, _, err := verifyRunProvenance(cfg, "npm", "tuprwre-npm:1", false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !shimState(owner) {
+		t.Fatal("shimState = false; want true")
+	}
+	owner, _, err = verifyRunProvenance(cfg, "node", "tuprwre-npm:1", false)
+	if err != nil {
+		t.Fatalf("verify other binary: %v", err)
+	}
+	if shimState(owner) {
+		t.Fatal("shimState(other binary) = true; want false")
+	}
+
+	// Persistent state is covered by the signature.
+	meta.State = false
+	if err := gen.SaveMetadata(meta); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	if _, _, err := verifyRunProvenance(cfg, "npm", "tuprwre-npm:1", false); err == nil {
+		t.Fatal("expected a changed state setting to fail signature verification")
+	}
+}
+
+// fakeTerminal answers prompts from in and records them in out.
+type fakeTerminal struct {
+	io.Reader
+	out *bytes.Buffer
+}
+
+func (f fakeTerminal) Write(p []byte) (int, error) { return f.out.Write(p) }
+func (f fakeTerminal) Close() error                { return nil }
+
+func TestApplyPathPolicy(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("resolve tempdir: %v", err)
+	}
+	workspace := filepath.Join(root, "workspace")
+	downloads := filepath.Join(root, "downloads")
+	for _, dir := range []string{workspace, downloads} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	file := filepath.Join(downloads, "file.json")
+	if err := os.WriteFile(file, []byte("{}"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	args := []string{".", file}
+	mounted := []string{workspace}
+	wantArgs := []string{".", argpath.HostRoot + file}
+	wantBind := downloads + ":" + argpath.HostRoot + downloads + ":ro"
+
+	got, binds, err := applyPathPolicy(argpath.Mount, "jq", args, workspace, mounted)
+	if err != nil || !slices.Equal(got, wantArgs) || !slices.Equal(binds, []string{wantBind}) {
+		t.Fatalf("mount policy = %v, %v, %v", got, binds, err)
+	}
+
+	if _, _, err := applyPathPolicy(argpath.Refuse, "jq", args, workspace, mounted); err == nil || !strings.Contains(err.Error(), file) {
+		t.Fatalf("refuse policy error = %v, want one naming %s", err, file)
+	}
+
+	got, binds, err = applyPathPolicy(argpath.Off, "jq", args, workspace, mounted)
+	if err != nil || !slices.Equal(got, args) || binds != nil {
+		t.Fatalf("off policy = %v, %v, %v", got, binds, err)
+	}
+
+	prevOpen := openTerminal
+	t.Cleanup(func() { openTerminal = prevOpen })
+	prompts := &bytes.Buffer{}
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return fakeTerminal{Reader: strings.NewReader("y\n"), out: prompts}, nil
+	}
+	got, binds, err = applyPathPolicy(argpath.Ask, "jq", args, workspace, mounted)
+	if err != nil || !slices.Equal(got, wantArgs) || !slices.Equal(binds, []string{wantBind}) {
+		t.Fatalf("ask policy (yes) = %v, %v, %v", got, binds, err)
+	}
+	if !strings.Contains(prompts.String(), "Mount "+downloads+" read-only for jq?") {
+		t.Fatalf("unexpected prompt %q", prompts.String())
+	}
+
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return fakeTerminal{Reader: strings.NewReader("\n"), out: &bytes.Buffer{}}, nil
+	}
+	if got, binds, err = applyPathPolicy(argpath.Ask, "jq", args, workspace, mounted); err != nil || !slices.Equal(got, args) || binds != nil {
+		t.Fatalf("ask policy (no) = %v, %v, %v", got, binds, err)
+	}
+
+	openTerminal = func() (io.ReadWriteCloser, error) { return nil, errors.New("no terminal") }
+	if got, binds, err = applyPathPolicy(argpath.Ask, "jq", args, workspace, mounted); err != nil || !slices.Equal(got, args) || binds != nil {
+		t.Fatalf("ask policy without a terminal = %v, %v, %v", got, binds, err)
+	}
+}
+
+func TestRunArgPathPolicy(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	t.Setenv("TUPRWRE_PATH_POLICY", "refuse")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	meta := &shim.Metadata{BinaryName: "jq", OutputImage: "tuprwre-jq:1", PathPolicy: "mount"}
+
+	prevFlag := runPathPolicy
+	t.Cleanup(func() { runPathPolicy = prevFlag })
+
+	for _, tc := range []struct {
+		flag     string
+		verified bool
+		want     argpath.Policy
+	}{
+		{flag: "", verified: true, want: argpath.Mount},
+		{flag: "", verified: false, want: argpath.Refuse},
+		{flag: "off", verified: true, want: argpath.Off},
+	} {
+		runPathPolicy = tc.flag
+		if got, err := runArgPathPolicy(cfg, meta, tc.verified); err != nil || got != tc.want {
+			t.Fatalf("runArgPathPolicy(flag=%q, verified=%v) = %q, %v; want %q", tc.flag, tc.verified, got, err, tc.want)
+		}
+	}
+
+	runPathPolicy = "sometimes"
+	if _, err := runArgPathPolicy(cfg, meta, true); err == nil {
+		t.Fatal("expected an invalid --path-policy to be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/discovery"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sbomFormat  string
+	sbomOutput  string
+	sbomRefresh bool
+)
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom <shim>",
+	Short: "Print the software bill of materials of a shim's image",
+	Long: `Prints the packages installed in the image behind a shim as CycloneDX or
+SPDX JSON. The SBOM is recorded at install time from dpkg, apk, Python
+dist-info, npm package.json files and Go build info; shims installed before
+that are scanned on first use.`,
+	Example: `  tuprwre sbom jq
+  tuprwre sbom jq --format spdx -o jq.spdx.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSBOM,
+}
+
+func init() {
+	sbomCmd.Flags().StringVar(&sbomFormat, "format", sbom.FormatCycloneDX, "Output format (cyclonedx|spdx)")
+	sbomCmd.Flags().StringVarP(&sbomOutput, "output", "o", "", "Write the SBOM to this file instead of stdout")
+	sbomCmd.Flags().BoolVar(&sbomRefresh, "refresh", false, "Rescan the image instead of using the recorded SBOM")
+}
+
+func runSBOM(cmd *cobra.Command, args []string) error {
+	if sbomFormat != sbom.FormatCycloneDX && sbomFormat != sbom.FormatSPDX {
+		return fmt.Errorf("unsupported --format %q (supported: %s, %s)", sbomFormat, sbom.FormatCycloneDX, sbom.FormatSPDX)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	shimName := args[0]
+	shimGen, err := shim.Resolve(cfg, shimName)
+	if err != nil {
+		return err
+	}
+
+	s, err := shimGen.LoadSBOM(shimName)
+	if sbomRefresh || errors.Is(err, os.ErrNotExist) {
+		s, err = scanShimSBOM(cfg, shimGen, shimName)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if sbomOutput != "" {
+		f, err := os.Create(sbomOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", sbomOutput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	return sbom.Write(out, s, sbomFormat, version)
+}
+
+// scanShimSBOM scans the image behind shimName and records the result.
+func scanShimSBOM(cfg *config.Config, shimGen *shim.Generator, shimName string) (sbom.SBOM, error) {
+	meta, err := shimGen.LoadMetadata(shimName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sbom.SBOM{}, fmt.Errorf("shim %q is missing lifecycle metadata (legacy shim); reinstall it to get an SBOM", shimName)
+		}
+		return sbom.SBOM{}, fmt.Errorf("failed to load metadata for shim %q: %w", shimName, err)
+	}
+
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+
+	s, err := discovery.New(cfg, docker).GenerateSBOM(context.Background(), meta.OutputImage)
+	if err != nil {
+		return sbom.SBOM{}, err
+	}
+	if err := shimGen.SaveSBOM(shimName, s); err != nil {
+		return sbom.SBOM{}, err
+	}
+	return s, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/spf13/cobra"
+)
+
+func TestRunSBOMUsesRecordedSBOM(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	if err := os.WriteFile(gen.GetPath("jq"), []byte("#!bin\n"), 0o755); err != nil {
+		t.Fatalf("seed shim: %v", err)
+	}
+	if err := gen.SaveSBOM("jq", sbom.SBOM{
+		Image:       "tuprwre-jq:1",
+		Distro:      "ubuntu",
+		GeneratedAt: "2026-01-02T03:04:05Z",
+		Packages:    []sbom.Package{{Type: sbom.TypeDeb, Name: "jq", Version: "1.6", Location: "/var/lib/dpkg/status"}},
+	}); err != nil {
+		t.Fatalf("seed SBOM: %v", err)
+	}
+
+	origFormat, origOutput, origRefresh := sbomFormat, sbomOutput, sbomRefresh
+	t.Cleanup(func() { sbomFormat, sbomOutput, sbomRefresh = origFormat, origOutput, origRefresh })
+	sbomOutput, sbomRefresh = "", false
+
+	for format, marker := range map[string]string{
+		sbom.FormatCycloneDX: `"bomFormat": "CycloneDX"`,
+		sbom.FormatSPDX:      `"spdxVersion": "SPDX-2.3"`,
+	} {
+		sbomFormat = format
+		out := &bytes.Buffer{}
+		cmd := &cobra.Command{}
+		cmd.SetOut(out)
+		if err := runSBOM(cmd, []string{"jq"}); err != nil {
+			t.Fatalf("runSBOM(%s) failed: %v", format, err)
+		}
+		if !strings.Contains(out.String(), marker) || !strings.Contains(out.String(), "pkg:deb/ubuntu/jq@1.6") {
+			t.Fatalf("unexpected %s output:\n%s", format, out.String())
+		}
+	}
+
+	sbomFormat = "swid"
+	if err := runSBOM(&cobra.Command{}, []string{"jq"}); err == nil {
+		t.Fatal("expected an unknown format to fail")
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
 	"github.com/spf13/cobra"
 )
 
@@ -31,6 +33,10 @@ var shellCmd = &cobra.Command{
 	Long: `Starts a new subshell with a modified PATH that intercepts dangerous commands
 (apt, pip, curl, etc.), blocks them, and guides users to run them via tuprwre install.
 
+Installed shims are placed on PATH right after the interception wrappers, with
+workspace-scoped shims ahead of global ones, so sandboxed tools are available
+without any extra PATH setup.
+
 Modes:
   - Interactive (no -c): starts a protected shell session.
   - Non-interactive (-c "<cmd>"): runs one command as a POSIX proxy shell.
@@ -114,8 +120,10 @@ func runShell(cmd *cobra.Command, args []string) error {
 	// Determine which shell to use
 	shell := determineShell()
 
-	// Prepare modified PATH with wrapper directory at the front
-	newPath := wrapperDir + string(os.PathListSeparator) + os.Getenv("PATH")
+	// Prepare modified PATH: wrapper directory first, then shim directories
+	// (workspace before global), then the caller's PATH.
+	shimDirs := shim.NewGenerator(cfg).PathDirs()
+	newPath := buildShellPath(wrapperDir, shimDirs, os.Getenv("PATH"))
 
 	// Prepare environment
 	env := os.Environ()
@@ -133,6 +141,9 @@ func runShell(cmd *cobra.Command, args []string) error {
 			interceptPreview = strings.Join(interceptList[:5], ", ") + ", ..."
 		}
 		fmt.Fprintf(shellStderr, "[tuprwre] Dangerous commands (%s) are intercepted\n", interceptPreview)
+		for _, warning := range shimShadowWarnings(shimDirs, interceptList, cfg.WorkspaceShimDir, os.Getenv("PATH")) {
+			fmt.Fprintf(shellStderr, "[tuprwre] Warning: %s\n", warning)
+		}
 		fmt.Fprintln(shellStderr, "[tuprwre] Type 'exit' to return to normal shell")
 	}
 
@@ -207,6 +218,74 @@ func parseShellCommandFromArgv(argv []string) (string, bool, error) {
 	return "", false, nil
 }
 
+// buildShellPath composes the session PATH. Shim directories already present
+// in basePath are dropped so the session ordering always wins.
+func buildShellPath(wrapperDir string, shimDirs []string, basePath string) string {
+	skip := make(map[string]bool, len(shimDirs))
+	entries := []string{wrapperDir}
+	for _, dir := range shimDirs {
+		skip[filepath.Clean(dir)] = true
+		entries = append(entries, dir)
+	}
+	for _, entry := range filepath.SplitList(basePath) {
+		if entry != "" && skip[filepath.Clean(entry)] {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
+}
+
+// shimShadowWarnings reports shims that will never run in the session because
+// an interception wrapper or an earlier shim directory provides the same name,
+// and shims in workspaceDir that take the place of a command on basePath.
+func shimShadowWarnings(shimDirs []string, intercepted []string, workspaceDir, basePath string) []string {
+	owner := make(map[string]string)
+	for _, name := range intercepted {
+		owner[name] = "the interception wrapper"
+	}
+
+	var warnings []string
+	for _, dir := range shimDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if previous, ok := owner[name]; ok {
+				warnings = append(warnings, fmt.Sprintf("shim %q in %s is shadowed by %s", name, dir, previous))
+				continue
+			}
+			owner[name] = dir
+			if dir == workspaceDir {
+				if host := lookPathOutside(name, basePath, shimDirs); host != "" {
+					warnings = append(warnings, fmt.Sprintf("workspace shim %q in %s takes the place of %s", name, dir, host))
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// lookPathOutside returns the executable name resolves to on path, skipping
+// the shim directories, or "" when there is none.
+func lookPathOutside(name, path string, shimDirs []string) string {
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" || slices.ContainsFunc(shimDirs, func(s string) bool { return filepath.Clean(s) == filepath.Clean(dir) }) {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return candidate
+		}
+	}
+	return ""
+}
+
 // generateWrappers creates wrapper scripts for dangerous commands
 func generateWrappers(wrapperDir string, commands []string) error {
 	for _, cmdName := range commands {
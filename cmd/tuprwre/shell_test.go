@@ -428,3 +428,75 @@ func TestRunShellInteractiveStillPrintsBanners(t *testing.T) {
 		t.Fatalf("missing interactive exit banner: %q", stderr)
 	}
 }
+
+func TestBuildShellPathOrdersShimDirsAfterWrappers(t *testing.T) {
+	got := buildShellPath("/wrap", []string{"/ws/.tuprwre/bin", "/home/u/.tuprwre/bin"}, "/usr/bin:/home/u/.tuprwre/bin:/bin")
+	want := "/wrap:/ws/.tuprwre/bin:/home/u/.tuprwre/bin:/usr/bin:/bin"
+	if got != want {
+		t.Fatalf("buildShellPath mismatch:\nwant=%q\n got=%q", want, got)
+	}
+}
+
+func TestShimShadowWarnings(t *testing.T) {
+	workspaceDir := t.TempDir()
+	globalDir := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(workspaceDir, "jq"),
+		filepath.Join(globalDir, "jq"),
+		filepath.Join(globalDir, "curl"),
+		filepath.Join(globalDir, "rg"),
+	} {
+		if err := os.WriteFile(path, []byte("#!bin\n"), 0o755); err != nil {
+			t.Fatalf("seed shim: %v", err)
+		}
+	}
+
+	hostDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(hostDir, "jq"), []byte("#!bin\n"), 0o755); err != nil {
+		t.Fatalf("seed host binary: %v", err)
+	}
+
+	basePath := strings.Join([]string{globalDir, hostDir}, string(os.PathListSeparator))
+	warnings := shimShadowWarnings([]string{workspaceDir, globalDir}, []string{"curl"}, workspaceDir, basePath)
+	if len(warnings) != 3 {
+		t.Fatalf("expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+	joined := strings.Join(warnings, "\n")
+	if !strings.Contains(joined, `"jq" in `+globalDir+" is shadowed by "+workspaceDir) {
+		t.Fatalf("missing workspace shadow warning: %q", joined)
+	}
+	if !strings.Contains(joined, `"curl" in `+globalDir+" is shadowed by the interception wrapper") {
+		t.Fatalf("missing wrapper shadow warning: %q", joined)
+	}
+	if !strings.Contains(joined, `workspace shim "jq" in `+workspaceDir+" takes the place of "+filepath.Join(hostDir, "jq")) {
+		t.Fatalf("missing host shadow warning: %q", joined)
+	}
+}
+
+func TestRunShellCommandModePutsShimDirOnPath(t *testing.T) {
+	tuprwreDir := t.TempDir()
+	t.Setenv("TUPRWRE_DIR", tuprwreDir)
+	t.Setenv("SHELL", "/bin/sh")
+
+	_, stdout, stderr, err := runShellWithTestHarness(
+		t,
+		[]string{"tuprwre", "shell", "-c", `printf "%s|%s" "$TUPRWRE_WRAPPER_DIR" "$PATH"`},
+		"",
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("runShell returned error: %v", err)
+	}
+	if stderr != "" {
+		t.Fatalf("unexpected stderr: %q", stderr)
+	}
+
+	parts := strings.SplitN(stdout, "|", 2)
+	if len(parts) != 2 {
+		t.Fatalf("unexpected output format: %q", stdout)
+	}
+	wantPrefix := parts[0] + string(os.PathListSeparator) + filepath.Join(tuprwreDir, "bin") + string(os.PathListSeparator)
+	if !strings.HasPrefix(parts[1], wantPrefix) {
+		t.Fatalf("shim dir is not placed right after wrapper dir: %q", parts[1])
+	}
+}
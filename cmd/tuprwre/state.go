@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var stateLsJSON bool
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage the persistent home directories of shims",
+	Long: `Manage the per-shim home directories that shims installed with --state
+get mounted as $HOME, under ~/.tuprwre/state/<shim>. They hold whatever the
+tool keeps in its home: caches, configs, history and sometimes credentials.`,
+}
+
+var stateLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List shim state directories",
+	Args:  cobra.NoArgs,
+	RunE:  runStateLs,
+}
+
+var stateDuCmd = &cobra.Command{
+	Use:   "du [shim...]",
+	Short: "Show the disk usage of shim state directories",
+	RunE:  runStateDu,
+}
+
+var stateRmCmd = &cobra.Command{
+	Use:   "rm <shim>...",
+	Short: "Delete shim state directories",
+	Long: `Deletes the state directories of the named shims. Idle warm containers that
+mount a removed directory are evicted.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStateRm,
+}
+
+func init() {
+	stateLsCmd.Flags().BoolVar(&stateLsJSON, "json", false, "Emit machine-readable JSON output")
+
+	stateCmd.AddCommand(stateLsCmd)
+	stateCmd.AddCommand(stateDuCmd)
+	stateCmd.AddCommand(stateRmCmd)
+}
+
+type stateListEntry struct {
+	state.Entry
+	Installed bool `json:"installed"`
+}
+
+func runStateLs(cmd *cobra.Command, _ []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	entries, err := state.List(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+
+	list := make([]stateListEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, stateListEntry{Entry: e, Installed: shimInstalled(cfg, e.Shim)})
+	}
+
+	out := cmd.OutOrStdout()
+	if stateLsJSON {
+		payload, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode state list: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(payload))
+		return nil
+	}
+
+	if len(list) == 0 {
+		_, _ = fmt.Fprintln(out, "No shim state.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SHIM\tSTATUS\tMODIFIED\tPATH")
+	for _, e := range list {
+		status := "installed"
+		if !e.Installed {
+			status = "not installed"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Shim, status, formatRelativeAge(e.Modified.Unix()), e.Path)
+	}
+	_ = w.Flush()
+	return nil
+}
+
+func runStateDu(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var entries []state.Entry
+	if len(args) == 0 {
+		if entries, err = state.List(cfg.StateDir); err != nil {
+			return err
+		}
+	}
+	for _, name := range args {
+		e, err := state.Stat(cfg.StateDir, name)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("shim %q has no state", name)
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+
+	writeStateUsage(cmd.OutOrStdout(), entries)
+	return nil
+}
+
+func writeStateUsage(out io.Writer, entries []state.Entry) {
+	if len(entries) == 0 {
+		_, _ = fmt.Fprintln(out, "No shim state.")
+		return
+	}
+	var total int64
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		total += e.Size
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", units.HumanSizeWithPrecision(float64(e.Size), 3), e.Shim)
+	}
+	if len(entries) > 1 {
+		_, _ = fmt.Fprintf(w, "%s\ttotal\n", units.HumanSizeWithPrecision(float64(total), 3))
+	}
+	_ = w.Flush()
+}
+
+func runStateRm(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	for _, name := range args {
+		dir, err := state.Path(cfg.StateDir, name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return fmt.Errorf("shim %q has no state", name)
+		}
+		evictStateContainers(out, cfg, dir)
+		if err := state.Remove(cfg.StateDir, name); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Removed state of %s\n", name)
+	}
+	return nil
+}
+
+// evictStateContainers removes idle warm containers mounting dir, so the
+// next run does not reuse one still bound to the deleted directory.
+func evictStateContainers(out io.Writer, cfg *config.Config, dir string) {
+	if !cfg.WarmPoolEnabled {
+		return
+	}
+	docker := sandbox.New(cfg)
+	defer docker.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if evicted, err := docker.EvictPoolState(ctx, dir, false); err != nil {
+		_, _ = fmt.Fprintf(out, "Warning: failed to evict warm containers for %s: %v\n", dir, err)
+	} else if evicted > 0 {
+		_, _ = fmt.Fprintf(out, "Evicted %d warm container(s) for %s\n", evicted, dir)
+	}
+}
+
+// shimInstalled reports whether a shim called name exists globally or in
+// the current workspace.
+func shimInstalled(cfg *config.Config, name string) bool {
+	_, err := shim.Resolve(cfg, name)
+	return err == nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/discovery"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
+	"github.com/spf13/cobra"
+)
+
+func TestStateCommands(t *testing.T) {
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	t.Setenv("TUPRWRE_WARM_POOL", "0")
+	t.Chdir(t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if err := shim.NewGenerator(cfg).Create(discovery.Binary{Name: "npm", Path: "/usr/bin/npm"}, "tuprwre-npm:1", false); err != nil {
+		t.Fatalf("create shim: %v", err)
+	}
+	for _, name := range []string{"npm", "gh"} {
+		dir, err := state.Ensure(cfg.StateDir, name)
+		if err != nil {
+			t.Fatalf("ensure state: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".history"), []byte("12345"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	prevJSON := stateLsJSON
+	t.Cleanup(func() { stateLsJSON = prevJSON })
+	stateLsJSON = true
+	out := &bytes.Buffer{}
+	cmd := &cobra.Command{}
+	cmd.SetOut(out)
+	if err := runStateLs(cmd, nil); err != nil {
+		t.Fatalf("state ls failed: %v", err)
+	}
+	var list []stateListEntry
+	if err := json.Unmarshal(out.Bytes(), &list); err != nil {
+		t.Fatalf("decode state ls: %v\n%s", err, out.String())
+	}
+	if len(list) != 2 || list[0].Shim != "gh" || list[0].Installed || list[1].Shim != "npm" || !list[1].Installed {
+		t.Fatalf("unexpected state list: %+v", list)
+	}
+
+	out.Reset()
+	if err := runStateDu(cmd, nil); err != nil {
+		t.Fatalf("state du failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 || !strings.HasSuffix(lines[2], "total") {
+		t.Fatalf("unexpected state du output:\n%s", out.String())
+	}
+	if err := runStateDu(cmd, []string{"jq"}); err == nil || !strings.Contains(err.Error(), "has no state") {
+		t.Fatalf("state du of a shim without state = %v", err)
+	}
+
+	out.Reset()
+	if err := runStateRm(cmd, []string{"gh"}); err != nil {
+		t.Fatalf("state rm failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed state of gh") {
+		t.Fatalf("unexpected state rm output: %q", out.String())
+	}
+	if _, err := state.Stat(cfg.StateDir, "gh"); !os.IsNotExist(err) {
+		t.Fatalf("state of gh still present: %v", err)
+	}
+	if err := runStateRm(cmd, []string{"gh"}); err == nil {
+		t.Fatal("expected removing absent state to fail")
+	}
+	if err := runStateRm(cmd, []string{"../npm"}); err == nil {
+		t.Fatal("expected an invalid shim name to be rejected")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
+	"github.com/spf13/cobra"
+)
+
+// tracingFlushTimeout bounds how long a command waits for the collector
+// before exiting.
+const tracingFlushTimeout = 3 * time.Second
+
+// setupTracing enables OpenTelemetry export when configured. The returned
+// flush sends pending spans; it is safe to call more than once and must run
+// before os.Exit.
+func setupTracing(cmd *cobra.Command, cfg *config.Config) func() {
+	shutdown, err := telemetry.Setup(context.Background(), cfg, version)
+	if err != nil {
+		cmd.PrintErrf("Warning: tracing disabled: %v\n", err)
+		return func() {}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+			defer cancel()
+			_ = shutdown(ctx)
+		})
+	}
+}
@@ -3,19 +3,45 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
 	"github.com/c4rb0nx1/tuprwre/internal/shim"
 	"github.com/spf13/cobra"
 )
 
+var (
+	updateNoCache bool
+	updateSecrets []string
+	updateSteps   []string
+)
+
 var updateCmd = &cobra.Command{
-	Use:   "update <shim>",
+	Use:   "update <shim> [-- <command>|<script args>]",
 	Short: "Re-run install for a shim using stored metadata",
 	RunE:  runUpdate,
 }
 
+func init() {
+	updateCmd.Flags().BoolVar(&updateNoCache, "no-cache", false, "Re-run every install step instead of reusing cached layers")
+	updateCmd.Flags().StringArrayVar(&updateSecrets, "secret", nil, "Secret the install needs again, as for install --secret (repeatable)")
+	updateCmd.Flags().StringArrayVar(&updateSteps, "step", nil, "Install steps to run instead of the stored ones, e.g. when they were stored redacted (repeatable)")
+}
+
 func runUpdate(cmd *cobra.Command, args []string) error {
+	var command string
+	var dashArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		dashArgs = args[dash:]
+		command = strings.TrimSpace(strings.Join(dashArgs, " "))
+		if command == "" {
+			return fmt.Errorf("missing install command after --")
+		}
+		args = args[:dash]
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("missing shim name")
 	}
@@ -28,8 +54,16 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	flushTraces := setupTracing(cmd, cfg)
+	defer flushTraces()
+
 	shimName := args[0]
-	shimGen := shim.NewGenerator(cfg)
+	shimGen, err := shim.Resolve(cfg, shimName)
+	if err != nil {
+		// Fall back to the global scope so legacy metadata-only entries still
+		// produce the usual diagnostics below.
+		shimGen = shim.NewGenerator(cfg)
+	}
 	meta, err := shimGen.LoadMetadata(shimName)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -42,6 +76,33 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	req.baseImage = meta.BaseImage
 	req.imageName = meta.OutputImage
 	req.force = true
+	req.scope = shimGen.Scope()
+	req.noCache = updateNoCache
+	req.hardening = meta.Hardening
+	req.forward = meta.Forward
+	req.state = meta.State
+	req.pathPolicy = meta.PathPolicy
+	if meta.InstallPolicy != nil {
+		req.policy = *meta.InstallPolicy
+	}
+	secrets, err := parseInstallSecrets(updateSecrets)
+	if err != nil {
+		return err
+	}
+	if missing := missingSecretIDs(meta.InstallSecrets, secrets); len(missing) > 0 {
+		return fmt.Errorf("shim %q was installed with secret(s) %s; pass them again with --secret", shimName, strings.Join(missing, ", "))
+	}
+	req.policy.Secrets = secrets
+
+	if command != "" {
+		switch meta.InstallMode {
+		case "registry", "dockerfile":
+			return fmt.Errorf("shim %q was installed from a %s; a replacement install command applies to command installs only", shimName, meta.InstallMode)
+		}
+	}
+	if len(updateSteps) > 0 && meta.InstallMode != "steps" {
+		return fmt.Errorf("shim %q was not installed with --step; --step applies to stepped installs only", shimName)
+	}
 
 	switch meta.InstallMode {
 	case "script":
@@ -57,15 +118,69 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		}
 		req.installScriptPath = meta.InstallScriptPath
 		req.installScriptContent = content
-		req.installScriptArgs = meta.InstallScriptArgs
+		req.installScriptArgs, err = updateInstallList(cmd, cfg, "script arguments", meta.InstallScriptArgs, meta.InstallScriptArgsHash, dashArgs,
+			fmt.Sprintf("tuprwre update %s -- <script args>", shimName))
+		if err != nil {
+			return err
+		}
+	case "registry":
+		if meta.SourceRef == "" {
+			return fmt.Errorf("metadata for shim %q is missing the registry reference", shimName)
+		}
+		req.from = meta.SourceRef
+	case "dockerfile":
+		if meta.InstallDockerfile == "" || meta.InstallContext == "" {
+			return fmt.Errorf("metadata for shim %q is missing Dockerfile source", shimName)
+		}
+		base, err := dockerfileDiscoveryBase(meta.InstallDockerfile, meta.BaseImage)
+		if err != nil {
+			return fmt.Errorf("stored Dockerfile for shim %q is unavailable: %w", shimName, err)
+		}
+		req.baseImage = base
+		req.dockerfile = meta.InstallDockerfile
+		req.contextDir = meta.InstallContext
+	case "steps":
+		if len(meta.InstallSteps) == 0 {
+			return fmt.Errorf("metadata for shim %q is missing install steps", shimName)
+		}
+		req.steps, err = updateInstallList(cmd, cfg, "install steps", meta.InstallSteps, meta.InstallStepsHash, updateSteps,
+			fmt.Sprintf("tuprwre update %s --step <command>...", shimName))
+		if err != nil {
+			return err
+		}
+		if meta.InstallScriptPath != "" {
+			// The script runs as the last step; -- passes its arguments.
+			content, err := os.ReadFile(meta.InstallScriptPath)
+			if err != nil {
+				return fmt.Errorf("stored script for shim %q is unavailable: failed to read script %s: %w", shimName, meta.InstallScriptPath, err)
+			}
+			req.installScriptPath = meta.InstallScriptPath
+			req.installScriptContent = content
+			req.installScriptArgs, err = updateInstallList(cmd, cfg, "script arguments", meta.InstallScriptArgs, meta.InstallScriptArgsHash, dashArgs,
+				fmt.Sprintf("tuprwre update %s --step <command>... -- <script args>", shimName))
+			if err != nil {
+				return err
+			}
+			break
+		}
+		req.installCommand, err = updateInstallCommand(cmd, cfg, shimName, meta, command)
+		if err != nil {
+			return err
+		}
 	case "", "command":
-		if meta.InstallCommand == "" {
+		if meta.InstallCommand == "" && command == "" {
 			return fmt.Errorf("metadata for shim %q is missing install command", shimName)
 		}
-		req.installCommand = meta.InstallCommand
+		req.installCommand, err = updateInstallCommand(cmd, cfg, shimName, meta, command)
+		if err != nil {
+			return err
+		}
 	default:
-		if meta.InstallCommand != "" {
-			req.installCommand = meta.InstallCommand
+		if meta.InstallCommand != "" || command != "" {
+			req.installCommand, err = updateInstallCommand(cmd, cfg, shimName, meta, command)
+			if err != nil {
+				return err
+			}
 			break
 		}
 		if meta.InstallScriptPath != "" {
@@ -88,3 +203,90 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 
 	return installFlow(cmd, cfg, req)
 }
+
+// missingSecretIDs returns the IDs in want that secrets does not provide.
+func missingSecretIDs(want []string, secrets []sandbox.Secret) []string {
+	var missing []string
+	for _, id := range want {
+		if !slices.ContainsFunc(secrets, func(s sandbox.Secret) bool { return s.ID == id }) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// updateInstallCommand returns the command to re-run for meta. A command
+// stored redacted cannot be re-run as is, so it must be passed again; the
+// salted hash recorded at install time tells whether it changed.
+func updateInstallCommand(cmd *cobra.Command, cfg *config.Config, shimName string, meta shim.Metadata, command string) (string, error) {
+	if command == "" {
+		if meta.InstallCommandHash != "" {
+			return "", fmt.Errorf("the install command of shim %q was redacted because it contained credentials; pass it again: tuprwre update %s -- <command>", shimName, shimName)
+		}
+		return meta.InstallCommand, nil
+	}
+
+	out := cmd.OutOrStdout()
+	if meta.InstallCommandHash == "" {
+		if command == meta.InstallCommand {
+			_, _ = fmt.Fprintln(out, "Install command unchanged")
+		} else {
+			_, _ = fmt.Fprintln(out, "Install command changed since the last install")
+		}
+		return command, nil
+	}
+	salt, err := redact.LoadOrCreateSalt(cfg.BaseDir)
+	if err != nil {
+		return "", err
+	}
+	switch same, ok := redact.Compare(salt, meta.InstallCommandHash, command); {
+	case !ok:
+		_, _ = fmt.Fprintln(out, "Cannot tell whether the install command changed: it was recorded on another machine")
+	case same:
+		_, _ = fmt.Fprintln(out, "Install command unchanged")
+	default:
+		_, _ = fmt.Fprintln(out, "Install command changed since the last install")
+	}
+	return command, nil
+}
+
+// updateInstallList returns the steps or script arguments to re-run, given
+// the stored ones, their hash and the ones passed again (nil when not
+// passed). Like the install command, a list stored redacted must be passed
+// again; usage says how.
+func updateInstallList(cmd *cobra.Command, cfg *config.Config, what string, stored []string, hash string, given []string, usage string) ([]string, error) {
+	if given == nil {
+		if hash != "" {
+			return nil, fmt.Errorf("the %s were redacted because they contained credentials; pass them again: %s", what, usage)
+		}
+		return stored, nil
+	}
+
+	out := cmd.OutOrStdout()
+	var same, ok bool
+	if hash == "" {
+		same, ok = slices.Equal(given, stored), true
+	} else {
+		salt, err := redact.LoadOrCreateSalt(cfg.BaseDir)
+		if err != nil {
+			return nil, err
+		}
+		same, ok = redact.Compare(salt, hash, joinInstallList(given))
+	}
+	switch {
+	case !ok:
+		_, _ = fmt.Fprintf(out, "Cannot tell whether the %s changed: they were recorded on another machine\n", what)
+	case same:
+		_, _ = fmt.Fprintf(out, "%s unchanged\n", capitalize(what))
+	default:
+		_, _ = fmt.Fprintf(out, "%s changed since the last install\n", capitalize(what))
+	}
+	return given, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
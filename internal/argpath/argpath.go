@@ -0,0 +1,150 @@
+// Package argpath finds command arguments that name host files outside the
+// directories mounted into a run, and translates them to paths inside the
+// container.
+package argpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy says what a run does with an argument naming a host path outside
+// its mounts.
+type Policy string
+
+const (
+	// Ask prompts on the terminal; without one the argument is passed as is.
+	Ask Policy = "ask"
+	// Mount mounts the path's directory read-only and rewrites the argument.
+	Mount Policy = "mount"
+	// Refuse fails the run.
+	Refuse Policy = "refuse"
+	// Off passes arguments through untouched.
+	Off Policy = "off"
+)
+
+// HostRoot is where host directories are mounted inside the container. They
+// are never mounted at their own path, which could shadow the image's /usr
+// or /etc.
+const HostRoot = "/run/tuprwre/host"
+
+// ignored are host trees whose paths name the container's own files.
+var ignored = []string{"/dev", "/proc", "/sys"}
+
+// ParsePolicy validates a policy name. The empty string is returned as is,
+// meaning the next setting in line applies.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(name))); p {
+	case "", Ask, Mount, Refuse, Off:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid path policy %q (supported: ask, mount, refuse, off)", name)
+	}
+}
+
+// Outside is an argument naming a host path outside the run's mounts.
+type Outside struct {
+	// Index is the argument's position in the args passed to Find.
+	Index int
+	// Prefix is the "--flag=" part of the argument, if any.
+	Prefix string
+	// Path is the absolute, symlink-resolved host path.
+	Path string
+	// Dir is what gets mounted: Path itself for a directory, otherwise the
+	// directory containing it.
+	Dir string
+}
+
+// Find returns the arguments of args that name an existing host path that
+// is not inside any of the mounted directories. Relative paths resolve
+// against cwd; a "--flag=value" argument is checked by its value.
+func Find(args []string, cwd string, mounted []string) []Outside {
+	var outside []Outside
+	for i, arg := range args {
+		prefix, value := splitFlag(arg)
+		if !looksLikePath(value) {
+			continue
+		}
+		path := expandHome(value)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(resolved)
+		if err != nil || insideAny(resolved, ignored) || insideAny(resolved, mounted) {
+			continue
+		}
+		dir := resolved
+		if !info.IsDir() {
+			dir = filepath.Dir(resolved)
+		}
+		outside = append(outside, Outside{Index: i, Prefix: prefix, Path: resolved, Dir: dir})
+	}
+	return outside
+}
+
+// Translate returns args with each outside argument pointing below
+// HostRoot, and the read-only binds that make those paths exist.
+func Translate(args []string, outside []Outside) (translated []string, binds []string) {
+	translated = append([]string{}, args...)
+	seen := make(map[string]bool)
+	for _, o := range outside {
+		translated[o.Index] = o.Prefix + ContainerPath(o.Path)
+		if !seen[o.Dir] {
+			seen[o.Dir] = true
+			binds = append(binds, o.Dir+":"+ContainerPath(o.Dir)+":ro")
+		}
+	}
+	return translated, binds
+}
+
+// ContainerPath returns where host path hostPath appears inside the
+// container once its directory is mounted.
+func ContainerPath(hostPath string) string {
+	return filepath.Join(HostRoot, hostPath)
+}
+
+// splitFlag splits "--flag=value" into its "--flag=" prefix and value.
+// Other arguments starting with "-" have no value to check.
+func splitFlag(arg string) (prefix, value string) {
+	if !strings.HasPrefix(arg, "-") {
+		return "", arg
+	}
+	if i := strings.IndexByte(arg, '='); i > 0 {
+		return arg[:i+1], arg[i+1:]
+	}
+	return arg, ""
+}
+
+func looksLikePath(value string) bool {
+	if value == "" || strings.Contains(value, "://") {
+		return false
+	}
+	return value == "." || value == ".." || value == "~" || strings.Contains(value, "/")
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+func insideAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
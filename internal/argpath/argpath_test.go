@@ -0,0 +1,94 @@
+package argpath
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"", "ask", "Mount", " refuse ", "off"} {
+		if _, err := ParsePolicy(name); err != nil {
+			t.Errorf("ParsePolicy(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParsePolicy("always"); err == nil {
+		t.Fatal("expected an unknown policy to be rejected")
+	}
+}
+
+func TestFind(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("resolve tempdir: %v", err)
+	}
+	workspace := filepath.Join(root, "workspace")
+	sibling := filepath.Join(root, "sibling")
+	for _, dir := range []string{workspace, sibling} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	inside := filepath.Join(workspace, "in.json")
+	outside := filepath.Join(sibling, "x.json")
+	for _, path := range []string{inside, outside} {
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	args := []string{
+		".",
+		"in.json",
+		inside,
+		"../sibling/x.json",
+		"--input=" + outside,
+		"-o",
+		sibling,
+		"../sibling/missing.json",
+		"/dev/null",
+		"https://example.com/a/b",
+		"s/a/b/",
+	}
+	got := Find(args, workspace, []string{workspace})
+	want := []Outside{
+		{Index: 3, Path: outside, Dir: sibling},
+		{Index: 4, Prefix: "--input=", Path: outside, Dir: sibling},
+		{Index: 6, Path: sibling, Dir: sibling},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Find() = %+v\nwant %+v", got, want)
+	}
+
+	translated, binds := Translate(args, got)
+	if translated[3] != HostRoot+outside || translated[4] != "--input="+HostRoot+outside || translated[6] != HostRoot+sibling {
+		t.Fatalf("unexpected translated args: %v", translated)
+	}
+	if args[3] != "../sibling/x.json" {
+		t.Fatalf("Translate modified its input: %v", args)
+	}
+	if want := []string{sibling + ":" + HostRoot + sibling + ":ro"}; !reflect.DeepEqual(binds, want) {
+		t.Fatalf("binds = %v, want %v", binds, want)
+	}
+}
+
+func TestFindExpandsHome(t *testing.T) {
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("resolve tempdir: %v", err)
+	}
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, "Downloads", "file.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got := Find([]string{"--file=~/Downloads/file.json"}, t.TempDir(), nil)
+	if len(got) != 1 || got[0].Path != path || got[0].Dir != filepath.Dir(path) {
+		t.Fatalf("Find() = %+v, want %s", got, path)
+	}
+}
@@ -2,6 +2,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -20,6 +22,14 @@ type Config struct {
 	// ContainerDir stores container state information
 	ContainerDir string
 
+	// StateDir holds the per-shim home directories mounted into runs of
+	// shims installed with --state. Directories are created on first use.
+	StateDir string
+
+	// RecordsDir holds the runs recorded with `run --record` and no
+	// directory, one subdirectory per record ID.
+	RecordsDir string
+
 	// DefaultBaseImage is the default Docker image for new containers
 	DefaultBaseImage string
 
@@ -35,6 +45,15 @@ type Config struct {
 	// WorkspaceRoot is the discovered workspace root path
 	WorkspaceRoot string
 
+	// WorkspaceDir holds the workspace's shims and metadata, under BaseDir
+	// rather than in the workspace, so a cloned repository cannot ship
+	// shims of its own. Empty outside a workspace.
+	WorkspaceDir string
+
+	// WorkspaceShimDir is where workspace-scoped shims live
+	// (<WorkspaceDir>/bin). Empty outside a workspace.
+	WorkspaceShimDir string
+
 	// DefaultMemory is the default memory limit for containers.
 	// Supports absolute values ("512m", "1g") or host-relative percentages ("25%").
 	// Empty string means no limit.
@@ -50,6 +69,69 @@ type Config struct {
 	WarmPoolMaxTotal  int
 	WarmPoolTTL       string
 	PoolDir           string
+
+	// WarmPoolMaxExecs is how many execs may share one warm container at once.
+	WarmPoolMaxExecs int
+
+	// Warm container recycling and health probing. A WarmPoolMaxUses or
+	// WarmPoolMaxAge of 0 disables the respective limit.
+	WarmPoolMaxUses    int
+	WarmPoolMaxAge     string
+	WarmPoolProbeAfter string
+
+	// StatsFile accumulates warm pool counters and shim overhead samples.
+	StatsFile string
+
+	// DaemonSocket is the unix socket `tuprwre daemon` listens on and
+	// `tuprwre run` probes before falling back to in-process execution.
+	DaemonSocket string
+
+	// Tracing configures OTLP trace export when the standard
+	// OTEL_EXPORTER_OTLP_* variables are not set.
+	Tracing TracingConfig
+
+	// TrustedKeys are base64 ed25519 public keys whose shim signatures `run`
+	// accepts in addition to the local signing key.
+	TrustedKeys []string
+
+	// Hardening names the container hardening profile runs use: default,
+	// strict or custom. Shims may pin their own.
+	Hardening string
+
+	// HardeningCustom defines the custom profile as overrides of default.
+	HardeningCustom HardeningSettings
+
+	// RedactPatterns are regular expressions masked in stored install
+	// commands and diagnostics, on top of the built-in credential patterns.
+	RedactPatterns []string
+
+	// PathPolicy is what runs do with arguments naming host paths outside
+	// the mounted workspace: ask, mount, refuse or off. Shims may pin their
+	// own. Only read from the global config, so a checked-out repository
+	// cannot have host paths mounted without asking.
+	PathPolicy string
+}
+
+// HardeningSettings is the "hardening_custom" config block. Unset fields
+// keep the default profile's value.
+type HardeningSettings struct {
+	CapDrop         []string `json:"cap_drop,omitempty"`
+	CapAdd          []string `json:"cap_add,omitempty"`
+	NoNewPrivileges *bool    `json:"no_new_privileges,omitempty"`
+	// Seccomp is a path to a seccomp JSON profile, or "unconfined".
+	Seccomp   string `json:"seccomp,omitempty"`
+	PidsLimit *int64 `json:"pids_limit,omitempty"`
+	Nofile    *int64 `json:"nofile,omitempty"`
+	Nproc     *int64 `json:"nproc,omitempty"`
+	TmpfsSize string `json:"tmpfs_size,omitempty"`
+}
+
+// TracingConfig is the "tracing" config block.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP base URL, e.g. http://localhost:4318.
+	// Empty disables tracing.
+	Endpoint string            `json:"endpoint,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
 }
 
 type fileConfig struct {
@@ -63,6 +145,20 @@ type fileConfig struct {
 	WarmPoolMaxPerKey *int     `json:"warm_pool_max_per_key,omitempty"`
 	WarmPoolMaxTotal  *int     `json:"warm_pool_max_total,omitempty"`
 	WarmPoolTTL       string   `json:"warm_pool_ttl,omitempty"`
+	WarmPoolMaxExecs  *int     `json:"warm_pool_max_execs_per_container,omitempty"`
+	WarmPoolMaxUses   *int     `json:"warm_pool_max_uses,omitempty"`
+	WarmPoolMaxAge    string   `json:"warm_pool_max_age,omitempty"`
+	WarmPoolProbe     string   `json:"warm_pool_probe_after,omitempty"`
+
+	Tracing     *TracingConfig `json:"tracing,omitempty"`
+	TrustedKeys []string       `json:"trusted_keys,omitempty"`
+
+	Hardening       string             `json:"hardening,omitempty"`
+	HardeningCustom *HardeningSettings `json:"hardening_custom,omitempty"`
+
+	RedactPatterns []string `json:"redact_patterns,omitempty"`
+
+	PathPolicy string `json:"path_policy,omitempty"`
 }
 
 var defaultBaseImage = "ubuntu:22.04"
@@ -129,6 +225,17 @@ func findWorkspaceRoot(startDir string) string {
 	return ""
 }
 
+// workspaceDir returns the directory under baseDir that holds the shims
+// and metadata of workspaceRoot: its base name plus a hash of its path, so
+// distinct checkouts of one repository stay apart.
+func workspaceDir(baseDir, workspaceRoot string) string {
+	if workspaceRoot == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(workspaceRoot))
+	return filepath.Join(baseDir, "workspaces", filepath.Base(workspaceRoot)+"-"+hex.EncodeToString(sum[:6]))
+}
+
 func getEnvSlice(key string) []string {
 	value, ok := os.LookupEnv(key)
 	if !ok {
@@ -203,12 +310,23 @@ func Load() (*Config, error) {
 		}
 	}
 
+	wsDir, wsShimDir := workspaceDir(baseDir, workspaceRoot), ""
+	if wsDir != "" {
+		wsShimDir = filepath.Join(wsDir, "bin")
+	}
+
 	cfg := &Config{
 		BaseDir:           baseDir,
 		ShimDir:           filepath.Join(baseDir, "bin"),
 		ContainerDir:      filepath.Join(baseDir, "containers"),
 		PoolDir:           filepath.Join(baseDir, "containers", "pool"),
+		StateDir:          filepath.Join(baseDir, "state"),
+		RecordsDir:        filepath.Join(baseDir, "records"),
+		DaemonSocket:      filepath.Join(baseDir, "daemon.sock"),
+		StatsFile:         filepath.Join(baseDir, "stats.json"),
 		WorkspaceRoot:     workspaceRoot,
+		WorkspaceDir:      wsDir,
+		WorkspaceShimDir:  wsShimDir,
 		DefaultBaseImage:  defaultBaseImage,
 		ContainerRuntime:  defaultRuntime,
 		InterceptCommands: copySlice(defaultInterceptCommands),
@@ -216,6 +334,12 @@ func Load() (*Config, error) {
 		WarmPoolMaxPerKey: 1,
 		WarmPoolMaxTotal:  5,
 		WarmPoolTTL:       "10m",
+		WarmPoolMaxExecs:  4,
+
+		WarmPoolMaxAge:     "1h",
+		WarmPoolProbeAfter: "30s",
+		Hardening:          "default",
+		PathPolicy:         "ask",
 	}
 
 	if globalConfig != nil {
@@ -249,6 +373,37 @@ func Load() (*Config, error) {
 		if globalConfig.WarmPoolTTL != "" {
 			cfg.WarmPoolTTL = globalConfig.WarmPoolTTL
 		}
+		if globalConfig.WarmPoolMaxExecs != nil {
+			cfg.WarmPoolMaxExecs = *globalConfig.WarmPoolMaxExecs
+		}
+		if globalConfig.WarmPoolMaxUses != nil {
+			cfg.WarmPoolMaxUses = *globalConfig.WarmPoolMaxUses
+		}
+		if globalConfig.WarmPoolMaxAge != "" {
+			cfg.WarmPoolMaxAge = globalConfig.WarmPoolMaxAge
+		}
+		if globalConfig.WarmPoolProbe != "" {
+			cfg.WarmPoolProbeAfter = globalConfig.WarmPoolProbe
+		}
+		// Only the global config may point traces somewhere; a checked-out
+		// repository should not be able to redirect them.
+		if globalConfig.Tracing != nil {
+			cfg.Tracing = *globalConfig.Tracing
+		}
+		// Likewise, only the global config may extend signing trust.
+		cfg.TrustedKeys = copySlice(globalConfig.TrustedKeys)
+		if globalConfig.Hardening != "" {
+			cfg.Hardening = globalConfig.Hardening
+		}
+		// A workspace may pick the custom profile but not define it, so a
+		// checked-out repository cannot grant itself capabilities.
+		if globalConfig.HardeningCustom != nil {
+			cfg.HardeningCustom = *globalConfig.HardeningCustom
+		}
+		cfg.RedactPatterns = copySlice(globalConfig.RedactPatterns)
+		if globalConfig.PathPolicy != "" {
+			cfg.PathPolicy = globalConfig.PathPolicy
+		}
 	}
 
 	if workspaceConfig != nil {
@@ -282,6 +437,24 @@ func Load() (*Config, error) {
 		if workspaceConfig.WarmPoolTTL != "" {
 			cfg.WarmPoolTTL = workspaceConfig.WarmPoolTTL
 		}
+		if workspaceConfig.WarmPoolMaxExecs != nil {
+			cfg.WarmPoolMaxExecs = *workspaceConfig.WarmPoolMaxExecs
+		}
+		if workspaceConfig.WarmPoolMaxUses != nil {
+			cfg.WarmPoolMaxUses = *workspaceConfig.WarmPoolMaxUses
+		}
+		if workspaceConfig.WarmPoolMaxAge != "" {
+			cfg.WarmPoolMaxAge = workspaceConfig.WarmPoolMaxAge
+		}
+		if workspaceConfig.WarmPoolProbe != "" {
+			cfg.WarmPoolProbeAfter = workspaceConfig.WarmPoolProbe
+		}
+		if workspaceConfig.Hardening != "" {
+			cfg.Hardening = workspaceConfig.Hardening
+		}
+		// Extra patterns only ever mask more, so a workspace adds to the
+		// global ones rather than replacing them.
+		cfg.RedactPatterns = append(cfg.RedactPatterns, workspaceConfig.RedactPatterns...)
 	}
 
 	cfg.DefaultBaseImage = getEnv("TUPRWRE_BASE_IMAGE", cfg.DefaultBaseImage)
@@ -294,6 +467,9 @@ func Load() (*Config, error) {
 	if v := os.Getenv("TUPRWRE_WARM_POOL_TTL"); v != "" {
 		cfg.WarmPoolTTL = v
 	}
+	cfg.DaemonSocket = getEnv("TUPRWRE_DAEMON_SOCKET", cfg.DaemonSocket)
+	cfg.Hardening = getEnv("TUPRWRE_HARDENING", cfg.Hardening)
+	cfg.PathPolicy = getEnv("TUPRWRE_PATH_POLICY", cfg.PathPolicy)
 
 	envIntercept := getEnvSlice("TUPRWRE_INTERCEPT")
 	if envIntercept != nil {
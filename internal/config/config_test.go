@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -50,6 +51,13 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.WarmPoolTTL != "10m" {
 		t.Fatalf("WarmPoolTTL = %q, want %q", cfg.WarmPoolTTL, "10m")
 	}
+	if cfg.WarmPoolMaxExecs != 4 {
+		t.Fatalf("WarmPoolMaxExecs = %d, want %d", cfg.WarmPoolMaxExecs, 4)
+	}
+	if cfg.WarmPoolMaxUses != 0 || cfg.WarmPoolMaxAge != "1h" || cfg.WarmPoolProbeAfter != "30s" {
+		t.Fatalf("warm pool recycle defaults = (%d, %q, %q), want (0, %q, %q)",
+			cfg.WarmPoolMaxUses, cfg.WarmPoolMaxAge, cfg.WarmPoolProbeAfter, "1h", "30s")
+	}
 
 	expectedIntercept := []string{"apt", "apt-get", "pip", "pip3", "curl", "wget"}
 	if !reflect.DeepEqual(cfg.InterceptCommands, expectedIntercept) {
@@ -259,6 +267,11 @@ func TestLoadMerge_WorkspaceOverridesGlobal(t *testing.T) {
 	if cfg.WorkspaceRoot != filepath.Clean(projectRoot) {
 		t.Fatalf("WorkspaceRoot = %q, want %q", cfg.WorkspaceRoot, filepath.Clean(projectRoot))
 	}
+	// Workspace shims live under the tuprwre directory, not in the repo.
+	if !strings.HasPrefix(cfg.WorkspaceShimDir, filepath.Join(tempHome, "runtime", "workspaces")+string(filepath.Separator)) ||
+		filepath.Base(cfg.WorkspaceShimDir) != "bin" {
+		t.Fatalf("WorkspaceShimDir = %q, want a bin directory under the tuprwre workspaces directory", cfg.WorkspaceShimDir)
+	}
 	if !reflect.DeepEqual(cfg.InterceptCommands, []string{"apt", "brew"}) {
 		t.Fatalf("InterceptCommands = %v, want %v", cfg.InterceptCommands, []string{"apt", "brew"})
 	}
@@ -382,3 +395,153 @@ func TestLoad_WarmPoolTTLEnvOverride(t *testing.T) {
 		t.Fatalf("WarmPoolTTL = %q, want %q", cfg.WarmPoolTTL, "30m")
 	}
 }
+
+func TestLoad_TracingAndTrustedKeysOnlyFromGlobalConfig(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	t.Setenv("TUPRWRE_DIR", filepath.Join(tempHome, "runtime"))
+
+	globalDir := filepath.Join(tempHome, ".tuprwre")
+	if err := os.MkdirAll(globalDir, 0755); err != nil {
+		t.Fatalf("failed to create global dir: %v", err)
+	}
+	global := `{"tracing": {"endpoint": "http://collector:4318", "headers": {"authorization": "Bearer x"}}, "trusted_keys": ["team-key"]}`
+	if err := os.WriteFile(filepath.Join(globalDir, "config.json"), []byte(global), 0644); err != nil {
+		t.Fatalf("failed to write global config: %v", err)
+	}
+
+	projectRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectRoot, ".tuprwre"), 0755); err != nil {
+		t.Fatalf("failed to create workspace dir: %v", err)
+	}
+	workspace := `{"tracing": {"endpoint": "http://attacker:4318"}, "trusted_keys": ["attacker-key"]}`
+	if err := os.WriteFile(filepath.Join(projectRoot, ".tuprwre", "config.json"), []byte(workspace), 0644); err != nil {
+		t.Fatalf("failed to write workspace config: %v", err)
+	}
+	t.Chdir(projectRoot)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if cfg.Tracing.Endpoint != "http://collector:4318" {
+		t.Fatalf("Tracing.Endpoint = %q, want global endpoint", cfg.Tracing.Endpoint)
+	}
+	if cfg.Tracing.Headers["authorization"] != "Bearer x" {
+		t.Fatalf("Tracing.Headers = %v", cfg.Tracing.Headers)
+	}
+	if len(cfg.TrustedKeys) != 1 || cfg.TrustedKeys[0] != "team-key" {
+		t.Fatalf("TrustedKeys = %v, want only the global key", cfg.TrustedKeys)
+	}
+}
+
+func TestLoad_HardeningCustomOnlyFromGlobalConfig(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	t.Setenv("TUPRWRE_DIR", filepath.Join(tempHome, "runtime"))
+
+	globalDir := filepath.Join(tempHome, ".tuprwre")
+	if err := os.MkdirAll(globalDir, 0755); err != nil {
+		t.Fatalf("failed to create global dir: %v", err)
+	}
+	global := `{"hardening": "strict", "hardening_custom": {"cap_add": ["NET_BIND_SERVICE"], "pids_limit": 64}}`
+	if err := os.WriteFile(filepath.Join(globalDir, "config.json"), []byte(global), 0644); err != nil {
+		t.Fatalf("failed to write global config: %v", err)
+	}
+
+	projectRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectRoot, ".tuprwre"), 0755); err != nil {
+		t.Fatalf("failed to create workspace dir: %v", err)
+	}
+	workspace := `{"hardening": "custom", "hardening_custom": {"cap_add": ["SYS_ADMIN"]}}`
+	if err := os.WriteFile(filepath.Join(projectRoot, ".tuprwre", "config.json"), []byte(workspace), 0644); err != nil {
+		t.Fatalf("failed to write workspace config: %v", err)
+	}
+	t.Chdir(projectRoot)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Hardening != "custom" {
+		t.Fatalf("Hardening = %q, want the workspace's choice", cfg.Hardening)
+	}
+	if len(cfg.HardeningCustom.CapAdd) != 1 || cfg.HardeningCustom.CapAdd[0] != "NET_BIND_SERVICE" {
+		t.Fatalf("HardeningCustom.CapAdd = %v, want only the global definition", cfg.HardeningCustom.CapAdd)
+	}
+	if cfg.HardeningCustom.PidsLimit == nil || *cfg.HardeningCustom.PidsLimit != 64 {
+		t.Fatalf("HardeningCustom.PidsLimit = %v, want 64", cfg.HardeningCustom.PidsLimit)
+	}
+
+	t.Setenv("TUPRWRE_HARDENING", "default")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Hardening != "default" {
+		t.Fatalf("Hardening = %q, want the env override", cfg.Hardening)
+	}
+}
+
+func TestLoad_PathPolicyOnlyFromGlobalConfig(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	t.Setenv("TUPRWRE_DIR", filepath.Join(tempHome, "runtime"))
+
+	globalDir := filepath.Join(tempHome, ".tuprwre")
+	if err := os.MkdirAll(globalDir, 0755); err != nil {
+		t.Fatalf("failed to create global dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(globalDir, "config.json"), []byte(`{"path_policy": "refuse"}`), 0644); err != nil {
+		t.Fatalf("failed to write global config: %v", err)
+	}
+
+	projectRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectRoot, ".tuprwre"), 0755); err != nil {
+		t.Fatalf("failed to create workspace dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectRoot, ".tuprwre", "config.json"), []byte(`{"path_policy": "mount"}`), 0644); err != nil {
+		t.Fatalf("failed to write workspace config: %v", err)
+	}
+	t.Chdir(projectRoot)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.PathPolicy != "refuse" {
+		t.Fatalf("PathPolicy = %q, want the global policy", cfg.PathPolicy)
+	}
+}
+
+func TestLoad_RedactPatternsMergeGlobalAndWorkspace(t *testing.T) {
+	tempHome := t.TempDir()
+	t.Setenv("HOME", tempHome)
+	t.Setenv("TUPRWRE_DIR", filepath.Join(tempHome, "runtime"))
+
+	globalDir := filepath.Join(tempHome, ".tuprwre")
+	if err := os.MkdirAll(globalDir, 0755); err != nil {
+		t.Fatalf("failed to create global dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(globalDir, "config.json"), []byte(`{"redact_patterns": ["acme_[a-z0-9]+"]}`), 0644); err != nil {
+		t.Fatalf("failed to write global config: %v", err)
+	}
+
+	projectRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectRoot, ".tuprwre"), 0755); err != nil {
+		t.Fatalf("failed to create workspace dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectRoot, ".tuprwre", "config.json"), []byte(`{"redact_patterns": ["internal-key-\\w+"]}`), 0644); err != nil {
+		t.Fatalf("failed to write workspace config: %v", err)
+	}
+	t.Chdir(projectRoot)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(cfg.RedactPatterns) != 2 || cfg.RedactPatterns[0] != "acme_[a-z0-9]+" || cfg.RedactPatterns[1] != `internal-key-\w+` {
+		t.Fatalf("RedactPatterns = %v, want the global then the workspace pattern", cfg.RedactPatterns)
+	}
+}
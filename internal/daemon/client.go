@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+)
+
+// dialTimeout keeps the "is a daemon running?" probe cheap for shims.
+const dialTimeout = 200 * time.Millisecond
+
+// Client is a connection to a running daemon. Each Client serves one run.
+type Client struct {
+	conn *net.UnixConn
+}
+
+// Dial connects to the daemon socket. An error means no daemon is reachable
+// and callers should fall back to running in-process.
+func Dial(socketPath string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socketPath, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		_ = conn.Close()
+		return nil, fmt.Errorf("unexpected connection type %T", conn)
+	}
+	return &Client{conn: unixConn}, nil
+}
+
+// Run hands opts and the given stdio files to the daemon and waits for the
+// run to finish. The stdio fields of opts are ignored.
+func (c *Client) Run(opts sandbox.RunOptions, stdin, stdout, stderr *os.File) (int, error) {
+	if err := sendFiles(c.conn, []*os.File{stdin, stdout, stderr}); err != nil {
+		return 1, err
+	}
+
+	if err := writeJSON(c.conn, Request{Options: opts}); err != nil {
+		return 1, fmt.Errorf("failed to send run request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(c.conn).Decode(&resp); err != nil {
+		return 1, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+	if resp.Error != "" {
+		return resp.ExitCode, fmt.Errorf("%s", resp.Error)
+	}
+	return resp.ExitCode, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,84 @@
+// Package daemon implements the optional long-running `tuprwre daemon` that
+// owns the Docker client and warm pool and serves run requests over a local
+// unix socket.
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+)
+
+// stdioFileCount is the number of descriptors passed with every run request
+// (stdin, stdout, stderr).
+const stdioFileCount = 3
+
+// Request is the JSON payload sent by a client after its stdio descriptors.
+type Request struct {
+	Options sandbox.RunOptions `json:"options"`
+}
+
+// Response is the JSON payload the daemon answers with once the run exits.
+type Response struct {
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// sendFiles passes files to the peer as SCM_RIGHTS ancillary data attached
+// to a single marker byte.
+func sendFiles(conn *net.UnixConn, files []*os.File) error {
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		fds = append(fds, int(f.Fd()))
+	}
+
+	n, oobn, err := conn.WriteMsgUnix([]byte{0}, syscall.UnixRights(fds...), nil)
+	if err != nil {
+		return fmt.Errorf("failed to send stdio descriptors: %w", err)
+	}
+	if n != 1 || oobn == 0 {
+		return fmt.Errorf("failed to send stdio descriptors: short write")
+	}
+	return nil
+}
+
+// receiveFds reads the marker byte and the descriptors sent by sendFiles.
+func receiveFds(conn *net.UnixConn, want int) ([]int, error) {
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(want*4))
+
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdio descriptors: %w", err)
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse control message: %w", err)
+	}
+
+	var fds []int
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+
+	if len(fds) != want {
+		for _, fd := range fds {
+			_ = syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("expected %d stdio descriptors, got %d", want, len(fds))
+	}
+	return fds, nil
+}
+
+func writeJSON(conn net.Conn, v any) error {
+	return json.NewEncoder(conn).Encode(v)
+}
@@ -0,0 +1,276 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+)
+
+// Backend executes runs and maintains the warm pool on behalf of the daemon.
+// *sandbox.DockerRuntime satisfies it.
+type Backend interface {
+	RunContext(ctx context.Context, opts sandbox.RunOptions) (int, error)
+	Prewarm(ctx context.Context, opts sandbox.RunOptions) error
+	PoolGC(ctx context.Context) (int, error)
+}
+
+// ServerConfig configures a daemon Server.
+type ServerConfig struct {
+	SocketPath string
+
+	// GCInterval is how often pool GC and pre-warming run.
+	GCInterval time.Duration
+
+	// PrewarmWindow is how long after its last use a pool key keeps being
+	// pre-warmed. Zero disables pre-warming.
+	PrewarmWindow time.Duration
+
+	// Logf receives operational log lines. Nil discards them.
+	Logf func(format string, args ...any)
+}
+
+// Server accepts run requests on a unix socket and executes them through
+// its Backend.
+type Server struct {
+	backend Backend
+	cfg     ServerConfig
+
+	mu     sync.Mutex
+	recent map[string]recentRun
+
+	wg sync.WaitGroup
+}
+
+type recentRun struct {
+	opts     sandbox.RunOptions
+	lastUsed time.Time
+}
+
+// NewServer creates a Server.
+func NewServer(backend Backend, cfg ServerConfig) *Server {
+	if cfg.GCInterval <= 0 {
+		cfg.GCInterval = time.Minute
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
+	return &Server{
+		backend: backend,
+		cfg:     cfg,
+		recent:  make(map[string]recentRun),
+	}
+}
+
+// Serve listens on the configured socket until ctx is cancelled. It refuses
+// to start when another daemon already answers on the socket and replaces a
+// stale socket file otherwise.
+func (s *Server) Serve(ctx context.Context) error {
+	listener, err := listen(s.cfg.SocketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(s.cfg.SocketPath)
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	maintenanceDone := make(chan struct{})
+	go func() {
+		defer close(maintenanceDone)
+		s.maintenanceLoop(ctx)
+	}()
+
+	s.cfg.Logf("listening on %s", s.cfg.SocketPath)
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+
+	s.wg.Wait()
+	<-maintenanceDone
+	return nil
+}
+
+func listen(socketPath string) (*net.UnixListener, error) {
+	if conn, err := net.DialTimeout("unix", socketPath, dialTimeout); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", socketPath)
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket %s: %w", socketPath, err)
+	}
+
+	// Create the socket owner-only from the start rather than relying on
+	// the chmod below, which leaves a window at the process umask.
+	oldUmask := syscall.Umask(0o177)
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	syscall.Umask(oldUmask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+func (s *Server) handle(ctx context.Context, conn *net.UnixConn) {
+	defer conn.Close()
+
+	// Runs execute with the daemon's Docker access, so only its own user
+	// may submit them, whatever the socket's permissions.
+	uid, err := peerUID(conn)
+	if err != nil {
+		_ = writeJSON(conn, Response{ExitCode: 1, Error: fmt.Sprintf("failed to read peer credentials: %v", err)})
+		return
+	}
+	if int(uid) != os.Getuid() {
+		s.cfg.Logf("rejected connection from uid %d", uid)
+		_ = writeJSON(conn, Response{ExitCode: 1, Error: fmt.Sprintf("uid %d may not use this daemon", uid)})
+		return
+	}
+
+	fds, err := receiveFds(conn, stdioFileCount)
+	if err != nil {
+		_ = writeJSON(conn, Response{ExitCode: 1, Error: err.Error()})
+		return
+	}
+	stdin := newPollingStdin(fds[0])
+	stdout := os.NewFile(uintptr(fds[1]), "client-stdout")
+	stderr := os.NewFile(uintptr(fds[2]), "client-stderr")
+	defer stdin.Close()
+	defer stdout.Close()
+	defer stderr.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		_ = writeJSON(conn, Response{ExitCode: 1, Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	opts := req.Options
+	opts.Stdin = stdin
+	opts.Stdout = stdout
+	opts.Stderr = stderr
+
+	// The client sends nothing after the request, so a read returning means
+	// it went away (Ctrl-C, killed). Cancel its run then, as the in-process
+	// path does on a signal.
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	started := time.Now()
+	exitCode, runErr := s.backend.RunContext(runCtx, opts)
+	s.cfg.Logf("run image=%s binary=%s exit=%d duration=%s", opts.Image, opts.Binary, exitCode, time.Since(started).Round(time.Millisecond))
+
+	resp := Response{ExitCode: exitCode}
+	if runErr != nil {
+		resp.Error = runErr.Error()
+	} else if !opts.NoPool && opts.ContainerID == "" {
+		s.remember(req.Options)
+	}
+	_ = writeJSON(conn, resp)
+}
+
+// remember records the pool-relevant part of a run for pre-warming.
+func (s *Server) remember(opts sandbox.RunOptions) {
+	warm := sandbox.RunOptions{
+		Image:       opts.Image,
+		Volumes:     opts.Volumes,
+		Runtime:     opts.Runtime,
+		NoNetwork:   opts.NoNetwork,
+		MemoryLimit: opts.MemoryLimit,
+		CPULimit:    opts.CPULimit,
+		Hardening:   opts.Hardening,
+		Forward:     forward.Mounts{Binds: opts.Forward.Binds},
+		StateDir:    opts.StateDir,
+	}
+	payload, err := json.Marshal(warm)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.recent[string(payload)] = recentRun{opts: warm, lastUsed: time.Now()}
+	s.mu.Unlock()
+}
+
+func (s *Server) maintenanceLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.GCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.maintain(ctx)
+		}
+	}
+}
+
+// maintain runs pool GC, then re-warms keys used within PrewarmWindow.
+func (s *Server) maintain(ctx context.Context) {
+	if removed, err := s.backend.PoolGC(ctx); err != nil {
+		s.cfg.Logf("pool gc failed: %v", err)
+	} else if removed > 0 {
+		s.cfg.Logf("pool gc removed %d containers", removed)
+	}
+
+	if s.cfg.PrewarmWindow <= 0 {
+		return
+	}
+
+	now := time.Now()
+	var targets []sandbox.RunOptions
+	s.mu.Lock()
+	for key, run := range s.recent {
+		if now.Sub(run.lastUsed) > s.cfg.PrewarmWindow {
+			delete(s.recent, key)
+			continue
+		}
+		targets = append(targets, run.opts)
+	}
+	s.mu.Unlock()
+
+	for _, opts := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.backend.Prewarm(ctx, opts); err != nil {
+			s.cfg.Logf("prewarm %s failed: %v", opts.Image, err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+)
+
+type fakeBackend struct {
+	mu       sync.Mutex
+	runs     []sandbox.RunOptions
+	prewarms []sandbox.RunOptions
+	gcCalls  int
+}
+
+func (f *fakeBackend) RunContext(_ context.Context, opts sandbox.RunOptions) (int, error) {
+	f.mu.Lock()
+	f.runs = append(f.runs, opts)
+	f.mu.Unlock()
+
+	input, err := io.ReadAll(opts.Stdin)
+	if err != nil {
+		return 1, err
+	}
+	_, _ = fmt.Fprintf(opts.Stdout, "%s %v stdin=%s", opts.Binary, opts.Args, input)
+	_, _ = fmt.Fprint(opts.Stderr, "warn")
+	return 7, nil
+}
+
+func (f *fakeBackend) Prewarm(_ context.Context, opts sandbox.RunOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prewarms = append(f.prewarms, opts)
+	return nil
+}
+
+func (f *fakeBackend) PoolGC(context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gcCalls++
+	return 0, nil
+}
+
+// startServer runs a server on a short socket path; unix socket paths are
+// length-limited so t.TempDir() is not always usable.
+func startServer(t *testing.T, backend Backend, cfg ServerConfig) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "tuprwre-daemon")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	cfg.SocketPath = filepath.Join(dir, "d.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	server := NewServer(backend, cfg)
+	go func() { done <- server.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		client, err := Dial(cfg.SocketPath)
+		if err == nil {
+			_ = client.Close()
+			return cfg.SocketPath
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("daemon did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientRun_PassesStdioAndExitCode(t *testing.T) {
+	backend := &fakeBackend{}
+	socketPath := startServer(t, backend, ServerConfig{GCInterval: time.Hour})
+
+	stdinR, stdinW, _ := os.Pipe()
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	_, _ = stdinW.WriteString("input")
+	_ = stdinW.Close()
+
+	client, err := Dial(socketPath)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	exitCode, err := client.Run(sandbox.RunOptions{Image: "img", Binary: "tool", Args: []string{"-v"}}, stdinR, stdoutW, stderrW)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if exitCode != 7 {
+		t.Fatalf("exit code = %d, want 7", exitCode)
+	}
+
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	stdout, _ := io.ReadAll(stdoutR)
+	stderr, _ := io.ReadAll(stderrR)
+	if got, want := string(stdout), "tool [-v] stdin=input"; got != want {
+		t.Fatalf("stdout = %q, want %q", got, want)
+	}
+	if string(stderr) != "warn" {
+		t.Fatalf("stderr = %q, want %q", stderr, "warn")
+	}
+}
+
+// blockingBackend runs until its context is cancelled.
+type blockingBackend struct {
+	fakeBackend
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (b *blockingBackend) RunContext(ctx context.Context, _ sandbox.RunOptions) (int, error) {
+	close(b.started)
+	<-ctx.Done()
+	close(b.cancelled)
+	return 130, ctx.Err()
+}
+
+func TestServe_CancelsRunWhenClientDisconnects(t *testing.T) {
+	backend := &blockingBackend{started: make(chan struct{}), cancelled: make(chan struct{})}
+	socketPath := startServer(t, backend, ServerConfig{GCInterval: time.Hour})
+
+	client, err := Dial(socketPath)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	go func() { _, _ = client.Run(sandbox.RunOptions{Image: "img", Binary: "tool"}, devNull, devNull, devNull) }()
+
+	select {
+	case <-backend.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run did not start")
+	}
+	_ = client.Close()
+
+	select {
+	case <-backend.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run was not cancelled after the client disconnected")
+	}
+}
+
+func TestServe_RefusesSecondDaemon(t *testing.T) {
+	socketPath := startServer(t, &fakeBackend{}, ServerConfig{GCInterval: time.Hour})
+
+	err := NewServer(&fakeBackend{}, ServerConfig{SocketPath: socketPath}).Serve(context.Background())
+	if err == nil {
+		t.Fatal("Serve() error = nil, want already listening error")
+	}
+}
+
+func TestServe_SocketIsOwnerOnly(t *testing.T) {
+	socketPath := startServer(t, &fakeBackend{}, ServerConfig{GCInterval: time.Hour})
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", perm)
+	}
+}
+
+func TestPeerUID_ReportsClientUID(t *testing.T) {
+	dir, err := os.MkdirTemp("", "tuprwre-peer")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "p.sock"), Net: "unix"}
+	listener, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		t.Fatalf("ListenUnix() error = %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.DialUnix("unix", nil, addr)
+	if err != nil {
+		t.Fatalf("DialUnix() error = %v", err)
+	}
+	defer client.Close()
+	conn, err := listener.AcceptUnix()
+	if err != nil {
+		t.Fatalf("AcceptUnix() error = %v", err)
+	}
+	defer conn.Close()
+
+	uid, err := peerUID(conn)
+	if err != nil {
+		t.Fatalf("peerUID() error = %v", err)
+	}
+	if int(uid) != os.Getuid() {
+		t.Fatalf("peerUID() = %d, want %d", uid, os.Getuid())
+	}
+}
+
+func TestDial_NoDaemon(t *testing.T) {
+	if _, err := Dial(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Fatal("Dial() error = nil, want error")
+	}
+}
+
+func TestMaintain_PrewarmsRecentRunsOnly(t *testing.T) {
+	backend := &fakeBackend{}
+	server := NewServer(backend, ServerConfig{PrewarmWindow: time.Minute})
+
+	server.remember(sandbox.RunOptions{Image: "fresh", Binary: "tool"})
+	server.remember(sandbox.RunOptions{Image: "stale", Binary: "tool"})
+	for key, run := range server.recent {
+		if run.opts.Image == "stale" {
+			run.lastUsed = time.Now().Add(-time.Hour)
+			server.recent[key] = run
+		}
+	}
+
+	server.maintain(context.Background())
+
+	if backend.gcCalls != 1 {
+		t.Fatalf("gc calls = %d, want 1", backend.gcCalls)
+	}
+	if len(backend.prewarms) != 1 || backend.prewarms[0].Image != "fresh" {
+		t.Fatalf("prewarms = %+v, want only fresh", backend.prewarms)
+	}
+	if backend.prewarms[0].Binary != "" {
+		t.Fatalf("prewarm options carry binary %q; only pool-relevant fields expected", backend.prewarms[0].Binary)
+	}
+	if len(server.recent) != 1 {
+		t.Fatalf("recent runs = %d, want stale entry dropped", len(server.recent))
+	}
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"io"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// stdinPollInterval bounds how long Close waits for an in-flight read.
+const stdinPollInterval = 50 * time.Millisecond
+
+// pollingStdin reads a client's stdin descriptor without ever blocking in
+// read(2). The descriptor is shared with the client's terminal or pipe, so a
+// read left pending after the run finished would steal input meant for the
+// client's shell. Close stops further reads and releases the descriptor.
+type pollingStdin struct {
+	mu     sync.Mutex
+	fd     int
+	closed bool
+}
+
+func newPollingStdin(fd int) *pollingStdin {
+	return &pollingStdin{fd: fd}
+}
+
+func (s *pollingStdin) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		fds := []unix.PollFd{{Fd: int32(s.fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(stdinPollInterval/time.Millisecond))
+		if err != nil && err != unix.EINTR {
+			s.mu.Unlock()
+			return 0, err
+		}
+		if n == 0 || err == unix.EINTR {
+			s.mu.Unlock()
+			continue
+		}
+
+		read, err := syscall.Read(s.fd, p)
+		s.mu.Unlock()
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if read == 0 {
+			return 0, io.EOF
+		}
+		return read, nil
+	}
+}
+
+// Close marks the reader finished and closes the descriptor.
+func (s *pollingStdin) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return syscall.Close(s.fd)
+}
@@ -4,10 +4,13 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
+	"time"
 
 	"github.com/c4rb0nx1/tuprwre/internal/config"
 	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
 )
 
 // Binary represents a discovered executable binary.
@@ -72,6 +75,35 @@ func (d *Discoverer) DiscoverBinaries(baseImage, newImage string) ([]Binary, err
 	return binaries, nil
 }
 
+// GenerateSBOM inventories the packages installed in image by scanning its
+// exported filesystem.
+func (d *Discoverer) GenerateSBOM(ctx context.Context, image string) (sbom.SBOM, error) {
+	imageID, err := d.sandbox.ImageID(ctx, image)
+	if err != nil {
+		return sbom.SBOM{}, err
+	}
+
+	var packages []sbom.Package
+	var release sbom.OSRelease
+	err = d.sandbox.ExportImageFilesystem(ctx, image, func(r io.Reader) error {
+		var scanErr error
+		packages, release, scanErr = sbom.Scan(r)
+		return scanErr
+	})
+	if err != nil {
+		return sbom.SBOM{}, fmt.Errorf("failed to scan %s for packages: %w", image, err)
+	}
+
+	return sbom.SBOM{
+		Image:         image,
+		ImageID:       imageID,
+		Distro:        release.ID,
+		DistroVersion: release.VersionID,
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Packages:      packages,
+	}, nil
+}
+
 // DiscoverFromFilesystemDiff compares filesystem states to find new binaries.
 // This is an alternative approach that diffs the entire filesystem.
 func (d *Discoverer) DiscoverFromFilesystemDiff(containerID, baseImage string) ([]Binary, error) {
@@ -0,0 +1,253 @@
+// Package forward exposes host credentials to a shim's runs on request: the
+// SSH agent, credential config files such as ~/.gitconfig or a kubeconfig,
+// and credential proxy sockets.
+package forward
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Built-in forwards. Every other forward is "file=PATH[,env=VAR]" or
+// "socket=PATH[,env=VAR]".
+const (
+	SSHAgent   = "ssh-agent"
+	GitConfig  = "gitconfig"
+	KubeConfig = "kubeconfig"
+
+	kindFile   = "file"
+	kindSocket = "socket"
+)
+
+// AgentSocket is where the host SSH agent socket appears in the container.
+const AgentSocket = "/run/tuprwre/ssh-agent.sock"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Forward is one parsed forward spec.
+type Forward struct {
+	Kind string
+	// Path is the host path of a file or socket forward, as written: either
+	// absolute or relative to the home directory ("~/...").
+	Path string
+	// Env names the variable set to Path inside the container.
+	Env string
+}
+
+// Parse parses a --forward spec.
+func Parse(spec string) (Forward, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case SSHAgent, GitConfig, KubeConfig:
+		return Forward{Kind: spec}, nil
+	}
+
+	var f Forward
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return Forward{}, fmt.Errorf("invalid forward %q (want ssh-agent, gitconfig, kubeconfig, file=PATH[,env=VAR] or socket=PATH[,env=VAR])", spec)
+		}
+		switch key {
+		case kindFile, kindSocket:
+			if f.Kind != "" {
+				return Forward{}, fmt.Errorf("invalid forward %q: set one of file= or socket=", spec)
+			}
+			f.Kind, f.Path = key, value
+		case "env":
+			f.Env = value
+		default:
+			return Forward{}, fmt.Errorf("invalid forward %q: unknown field %q", spec, key)
+		}
+	}
+	if f.Kind == "" {
+		return Forward{}, fmt.Errorf("invalid forward %q: missing file= or socket=", spec)
+	}
+	if !strings.HasPrefix(f.Path, "/") && !strings.HasPrefix(f.Path, "~/") {
+		return Forward{}, fmt.Errorf("invalid forward %q: path must be absolute or start with ~/", spec)
+	}
+	f.Path = filepath.Clean(f.Path)
+	if f.Env != "" && !envNamePattern.MatchString(f.Env) {
+		return Forward{}, fmt.Errorf("invalid forward %q: %q is not a valid variable name", spec, f.Env)
+	}
+	return f, nil
+}
+
+// String returns f's canonical spec.
+func (f Forward) String() string {
+	switch f.Kind {
+	case kindFile, kindSocket:
+		spec := f.Kind + "=" + f.Path
+		if f.Env != "" {
+			spec += ",env=" + f.Env
+		}
+		return spec
+	}
+	return f.Kind
+}
+
+// Normalize validates specs and returns them canonical and deduplicated, in
+// the form shim metadata records.
+func Normalize(specs []string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		f, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		if canonical := f.String(); !seen[canonical] {
+			seen[canonical] = true
+			out = append(out, canonical)
+		}
+	}
+	return out, nil
+}
+
+// Mounts is what a set of forwards adds to a run: bind mounts, which are
+// fixed when the container is created, and environment variables.
+type Mounts struct {
+	Binds []string `json:"binds,omitempty"`
+	Env   []string `json:"env,omitempty"`
+}
+
+// Resolve turns specs into mounts against the calling user's environment.
+// Forwards whose source is unavailable (no agent running, no kubeconfig)
+// are skipped and reported in skipped, so a tool that only sometimes needs
+// credentials still runs.
+func Resolve(specs []string) (m Mounts, skipped []error, err error) {
+	for _, spec := range specs {
+		f, err := Parse(spec)
+		if err != nil {
+			return Mounts{}, nil, err
+		}
+		if err := f.resolve(&m); err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w", f, err))
+		}
+	}
+	return m, skipped, nil
+}
+
+func (f Forward) resolve(m *Mounts) error {
+	switch f.Kind {
+	case SSHAgent:
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return fmt.Errorf("SSH_AUTH_SOCK is not set")
+		}
+		if err := checkSocket(sock); err != nil {
+			return err
+		}
+		m.Binds = append(m.Binds, sock+":"+AgentSocket)
+		m.Env = append(m.Env, "SSH_AUTH_SOCK="+AgentSocket)
+	case GitConfig:
+		path, err := firstExisting(gitConfigPaths())
+		if err != nil {
+			return err
+		}
+		m.Binds = append(m.Binds, path+":"+path+":ro")
+		m.Env = append(m.Env, "GIT_CONFIG_GLOBAL="+path)
+	case KubeConfig:
+		var paths []string
+		for _, path := range kubeConfigPaths() {
+			if _, err := os.Stat(path); err == nil {
+				paths = append(paths, path)
+				m.Binds = append(m.Binds, path+":"+path+":ro")
+			}
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no kubeconfig found")
+		}
+		m.Env = append(m.Env, "KUBECONFIG="+strings.Join(paths, ":"))
+	case kindFile, kindSocket:
+		path, err := expandHome(f.Path)
+		if err != nil {
+			return err
+		}
+		if f.Kind == kindSocket {
+			if err := checkSocket(path); err != nil {
+				return err
+			}
+			m.Binds = append(m.Binds, path+":"+path)
+		} else {
+			if _, err := os.Stat(path); err != nil {
+				return err
+			}
+			m.Binds = append(m.Binds, path+":"+path+":ro")
+		}
+		if f.Env != "" {
+			m.Env = append(m.Env, f.Env+"="+path)
+		}
+	default:
+		return fmt.Errorf("unknown forward")
+	}
+	return nil
+}
+
+func checkSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s is not a socket", path)
+	}
+	return nil
+}
+
+func firstExisting(paths []string) (string, error) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s exists", strings.Join(paths, ", "))
+}
+
+// gitConfigPaths lists where git looks for the global config, in order.
+func gitConfigPaths() []string {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".gitconfig"))
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "git", "config"))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "git", "config"))
+	}
+	return paths
+}
+
+// kubeConfigPaths lists the kubeconfig files kubectl would merge.
+func kubeConfigPaths() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		var paths []string
+		for _, path := range filepath.SplitList(env) {
+			if path == "" {
+				continue
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				paths = append(paths, abs)
+			}
+		}
+		return paths
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return []string{filepath.Join(home, ".kube", "config")}
+	}
+	return nil
+}
+
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, path[2:]), nil
+}
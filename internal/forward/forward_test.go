@@ -0,0 +1,94 @@
+package forward
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	got, err := Normalize([]string{"ssh-agent", " gitconfig", "file=~/.npmrc,env=NPM_CONFIG_USERCONFIG", "env=X,socket=/run/gcm//sock", "ssh-agent"})
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	want := []string{"ssh-agent", "gitconfig", "file=~/.npmrc,env=NPM_CONFIG_USERCONFIG", "socket=/run/gcm/sock,env=X"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Normalize() = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"gpg-agent", "file=relative/path", "file=/a,socket=/b", "file=/a,env=1BAD", "env=X", "file=/a,mode=ro"} {
+		if _, err := Normalize([]string{spec}); err == nil {
+			t.Errorf("Normalize(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("KUBECONFIG", "")
+
+	// Unix socket paths are length-limited, so keep it short.
+	sockDir, err := os.MkdirTemp("", "fwd")
+	if err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(sockDir) })
+	sock := filepath.Join(sockDir, "agent")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	gitconfig := filepath.Join(home, ".gitconfig")
+	if err := os.WriteFile(gitconfig, []byte("[user]\n"), 0o600); err != nil {
+		t.Fatalf("write gitconfig: %v", err)
+	}
+
+	m, skipped, err := Resolve([]string{SSHAgent, GitConfig, KubeConfig, "socket=" + sock + ",env=CRED_SOCK", "file=~/.npmrc"})
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	wantBinds := []string{sock + ":" + AgentSocket, gitconfig + ":" + gitconfig + ":ro", sock + ":" + sock}
+	if !reflect.DeepEqual(m.Binds, wantBinds) {
+		t.Fatalf("Binds = %v, want %v", m.Binds, wantBinds)
+	}
+	wantEnv := []string{"SSH_AUTH_SOCK=" + AgentSocket, "GIT_CONFIG_GLOBAL=" + gitconfig, "CRED_SOCK=" + sock}
+	if !reflect.DeepEqual(m.Env, wantEnv) {
+		t.Fatalf("Env = %v, want %v", m.Env, wantEnv)
+	}
+	if len(skipped) != 2 || !strings.HasPrefix(skipped[0].Error(), "kubeconfig:") || !strings.HasPrefix(skipped[1].Error(), "file=~/.npmrc:") {
+		t.Fatalf("skipped = %v, want kubeconfig and ~/.npmrc", skipped)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", gitconfig)
+	if _, skipped, _ := Resolve([]string{SSHAgent}); len(skipped) != 1 {
+		t.Fatalf("expected a non-socket SSH_AUTH_SOCK to be skipped, got %v", skipped)
+	}
+}
+
+func TestResolveKubeconfigList(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("apiVersion: v1\n"), 0o600); err != nil {
+			t.Fatalf("write kubeconfig: %v", err)
+		}
+	}
+	t.Setenv("KUBECONFIG", a+string(os.PathListSeparator)+filepath.Join(dir, "missing")+string(os.PathListSeparator)+b)
+
+	m, skipped, err := Resolve([]string{KubeConfig})
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("Resolve() = %v, %v", skipped, err)
+	}
+	if len(m.Binds) != 2 || !reflect.DeepEqual(m.Env, []string{"KUBECONFIG=" + a + ":" + b}) {
+		t.Fatalf("unexpected mounts: %+v", m)
+	}
+}
@@ -0,0 +1,240 @@
+// Package hardening defines the container hardening profiles sandboxed runs
+// use: capability changes, no-new-privileges, seccomp, PID and rlimit caps
+// and /tmp sizing.
+package hardening
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+)
+
+// Profile names.
+const (
+	Default = "default"
+	Strict  = "strict"
+	Custom  = "custom"
+)
+
+// SeccompUnconfined disables seccomp filtering.
+const SeccompUnconfined = "unconfined"
+
+// defaultTmpfsSize is the /tmp size when a profile does not set one.
+const defaultTmpfsSize = "64m"
+
+// Profile is a resolved hardening profile. The zero value applies no
+// hardening beyond the read-only rootfs and /tmp tmpfs every run gets.
+type Profile struct {
+	Name            string   `json:"name,omitempty"`
+	CapDrop         []string `json:"cap_drop,omitempty"`
+	CapAdd          []string `json:"cap_add,omitempty"`
+	NoNewPrivileges bool     `json:"no_new_privileges,omitempty"`
+	// Seccomp is the seccomp JSON profile itself, or "unconfined". Empty
+	// keeps Docker's default profile.
+	Seccomp   string `json:"seccomp,omitempty"`
+	PidsLimit int64  `json:"pids_limit,omitempty"`
+	Nofile    int64  `json:"nofile,omitempty"`
+	Nproc     int64  `json:"nproc,omitempty"`
+	TmpfsSize string `json:"tmpfs_size,omitempty"`
+}
+
+// builtin returns the built-in profile called name.
+func builtin(name string) (Profile, bool) {
+	switch name {
+	case Default:
+		return Profile{
+			Name:            Default,
+			NoNewPrivileges: true,
+			PidsLimit:       4096,
+			TmpfsSize:       defaultTmpfsSize,
+		}, true
+	case Strict:
+		return Profile{
+			Name:            Strict,
+			CapDrop:         []string{"ALL"},
+			NoNewPrivileges: true,
+			PidsLimit:       256,
+			Nofile:          1024,
+			TmpfsSize:       "16m",
+		}, true
+	}
+	return Profile{}, false
+}
+
+// Names lists the profiles Resolve accepts.
+func Names() []string {
+	return []string{Default, Strict, Custom}
+}
+
+// Resolve returns the profile called name, falling back to the configured
+// profile when name is empty. The custom profile is the default profile
+// with cfg.HardeningCustom applied; its seccomp file is read here.
+func Resolve(cfg *config.Config, name string) (Profile, error) {
+	if name == "" {
+		name = cfg.Hardening
+	}
+	if name == "" {
+		name = Default
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if p, ok := builtin(name); ok {
+		return p, nil
+	}
+	if name != Custom {
+		return Profile{}, fmt.Errorf("unknown hardening profile %q (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+
+	p, _ := builtin(Default)
+	p.Name = Custom
+	c := cfg.HardeningCustom
+	if c.CapDrop != nil {
+		p.CapDrop = NormalizeCaps(c.CapDrop)
+	}
+	if c.CapAdd != nil {
+		p.CapAdd = NormalizeCaps(c.CapAdd)
+	}
+	if c.NoNewPrivileges != nil {
+		p.NoNewPrivileges = *c.NoNewPrivileges
+	}
+	if c.PidsLimit != nil {
+		p.PidsLimit = *c.PidsLimit
+	}
+	if c.Nofile != nil {
+		p.Nofile = *c.Nofile
+	}
+	if c.Nproc != nil {
+		p.Nproc = *c.Nproc
+	}
+	if c.TmpfsSize != "" {
+		if _, err := units.RAMInBytes(c.TmpfsSize); err != nil {
+			return Profile{}, fmt.Errorf("invalid hardening_custom.tmpfs_size %q: %w", c.TmpfsSize, err)
+		}
+		p.TmpfsSize = c.TmpfsSize
+	}
+	if c.Seccomp != "" {
+		seccomp, err := loadSeccomp(c.Seccomp)
+		if err != nil {
+			return Profile{}, err
+		}
+		p.Seccomp = seccomp
+	}
+	for _, limit := range []int64{p.PidsLimit, p.Nofile, p.Nproc} {
+		if limit < 0 {
+			return Profile{}, fmt.Errorf("hardening_custom limits must not be negative")
+		}
+	}
+	return p, nil
+}
+
+// NormalizeCaps upper-cases capability names and drops the CAP_ prefix, as
+// the docker CLI does.
+func NormalizeCaps(caps []string) []string {
+	out := make([]string, 0, len(caps))
+	for _, c := range caps {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if c != "ALL" {
+			c = strings.TrimPrefix(c, "CAP_")
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// loadSeccomp reads a seccomp profile file. The Engine API takes the
+// profile's content, not its path.
+func loadSeccomp(path string) (string, error) {
+	if path == SeccompUnconfined {
+		return SeccompUnconfined, nil
+	}
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+	if !json.Valid(content) {
+		return "", fmt.Errorf("seccomp profile %s is not valid JSON", path)
+	}
+	return string(content), nil
+}
+
+// Apply sets p's restrictions on hc. /tmp is always mounted as a noexec
+// tmpfs; a zero profile keeps its historical 64m size.
+func (p Profile) Apply(hc *container.HostConfig) {
+	size := p.TmpfsSize
+	if size == "" {
+		size = defaultTmpfsSize
+	}
+	if hc.Tmpfs == nil {
+		hc.Tmpfs = map[string]string{}
+	}
+	hc.Tmpfs["/tmp"] = "size=" + size + ",noexec"
+
+	hc.CapDrop = append(hc.CapDrop, p.CapDrop...)
+	hc.CapAdd = append(hc.CapAdd, p.CapAdd...)
+	if p.NoNewPrivileges {
+		hc.SecurityOpt = append(hc.SecurityOpt, "no-new-privileges:true")
+	}
+	if p.Seccomp != "" {
+		hc.SecurityOpt = append(hc.SecurityOpt, "seccomp="+p.Seccomp)
+	}
+	if p.PidsLimit > 0 {
+		limit := p.PidsLimit
+		hc.Resources.PidsLimit = &limit
+	}
+	if p.Nofile > 0 {
+		hc.Resources.Ulimits = append(hc.Resources.Ulimits, &container.Ulimit{Name: "nofile", Soft: p.Nofile, Hard: p.Nofile})
+	}
+	if p.Nproc > 0 {
+		hc.Resources.Ulimits = append(hc.Resources.Ulimits, &container.Ulimit{Name: "nproc", Soft: p.Nproc, Hard: p.Nproc})
+	}
+}
+
+// Fingerprint identifies what p enforces, so warm containers created under
+// one profile are never reused under another. It is "" for the zero
+// profile.
+func (p Profile) Fingerprint() string {
+	if p.isZero() {
+		return ""
+	}
+	canonical := p
+	canonical.Name = ""
+	canonical.CapDrop = sortedCopy(p.CapDrop)
+	canonical.CapAdd = sortedCopy(p.CapAdd)
+	payload, err := json.Marshal(canonical)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (p Profile) isZero() bool {
+	return p.Name == "" && len(p.CapDrop) == 0 && len(p.CapAdd) == 0 && !p.NoNewPrivileges &&
+		p.Seccomp == "" && p.PidsLimit == 0 && p.Nofile == 0 && p.Nproc == 0 && p.TmpfsSize == ""
+}
+
+func sortedCopy(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := append([]string(nil), values...)
+	sort.Strings(out)
+	return out
+}
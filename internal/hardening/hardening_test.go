@@ -0,0 +1,135 @@
+package hardening
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestResolveBuiltinProfiles(t *testing.T) {
+	cfg := &config.Config{Hardening: Strict}
+
+	p, err := Resolve(cfg, "")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if p.Name != Strict || len(p.CapDrop) != 1 || p.CapDrop[0] != "ALL" || !p.NoNewPrivileges {
+		t.Fatalf("configured profile = %+v, want strict", p)
+	}
+
+	p, err = Resolve(cfg, " Default ")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if p.Name != Default || len(p.CapDrop) != 0 || p.PidsLimit != 4096 {
+		t.Fatalf("explicit profile = %+v, want default", p)
+	}
+
+	if _, err := Resolve(cfg, "paranoid"); err == nil || !strings.Contains(err.Error(), "unknown hardening profile") {
+		t.Fatalf("expected an unknown-profile error, got %v", err)
+	}
+}
+
+func TestResolveCustomProfile(t *testing.T) {
+	seccomp := filepath.Join(t.TempDir(), "seccomp.json")
+	if err := os.WriteFile(seccomp, []byte(`{"defaultAction": "SCMP_ACT_ERRNO"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	noNewPrivs := false
+	pids, nproc := int64(64), int64(128)
+	cfg := &config.Config{
+		Hardening: Custom,
+		HardeningCustom: config.HardeningSettings{
+			CapDrop:         []string{"all"},
+			CapAdd:          []string{"cap_net_bind_service"},
+			NoNewPrivileges: &noNewPrivs,
+			Seccomp:         seccomp,
+			PidsLimit:       &pids,
+			Nproc:           &nproc,
+			TmpfsSize:       "8m",
+		},
+	}
+
+	p, err := Resolve(cfg, "")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if p.Name != Custom || p.CapDrop[0] != "ALL" || p.CapAdd[0] != "NET_BIND_SERVICE" || p.NoNewPrivileges {
+		t.Fatalf("custom profile = %+v", p)
+	}
+	if p.PidsLimit != 64 || p.Nproc != 128 || p.Nofile != 0 || p.TmpfsSize != "8m" {
+		t.Fatalf("custom limits = %+v", p)
+	}
+	if !strings.Contains(p.Seccomp, "SCMP_ACT_ERRNO") {
+		t.Fatalf("seccomp = %q, want the file's content", p.Seccomp)
+	}
+
+	cfg.HardeningCustom.Seccomp = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := Resolve(cfg, ""); err == nil {
+		t.Fatal("expected a missing seccomp profile to fail")
+	}
+	cfg.HardeningCustom.Seccomp = SeccompUnconfined
+	cfg.HardeningCustom.TmpfsSize = "lots"
+	if _, err := Resolve(cfg, ""); err == nil {
+		t.Fatal("expected an invalid tmpfs size to fail")
+	}
+}
+
+func TestApply(t *testing.T) {
+	hc := &container.HostConfig{ReadonlyRootfs: true}
+	Profile{}.Apply(hc)
+	if hc.Tmpfs["/tmp"] != "size=64m,noexec" || len(hc.SecurityOpt) != 0 || hc.Resources.PidsLimit != nil {
+		t.Fatalf("zero profile changed more than /tmp: %+v", hc)
+	}
+
+	hc = &container.HostConfig{ReadonlyRootfs: true}
+	Profile{
+		CapDrop:         []string{"ALL"},
+		CapAdd:          []string{"CHOWN"},
+		NoNewPrivileges: true,
+		Seccomp:         `{"defaultAction":"SCMP_ACT_ALLOW"}`,
+		PidsLimit:       256,
+		Nofile:          1024,
+		Nproc:           512,
+		TmpfsSize:       "16m",
+	}.Apply(hc)
+
+	if hc.Tmpfs["/tmp"] != "size=16m,noexec" {
+		t.Fatalf("Tmpfs = %v", hc.Tmpfs)
+	}
+	if len(hc.CapDrop) != 1 || hc.CapDrop[0] != "ALL" || len(hc.CapAdd) != 1 || hc.CapAdd[0] != "CHOWN" {
+		t.Fatalf("CapDrop = %v, CapAdd = %v", hc.CapDrop, hc.CapAdd)
+	}
+	want := []string{"no-new-privileges:true", `seccomp={"defaultAction":"SCMP_ACT_ALLOW"}`}
+	if strings.Join([]string(hc.SecurityOpt), "\n") != strings.Join(want, "\n") {
+		t.Fatalf("SecurityOpt = %v, want %v", hc.SecurityOpt, want)
+	}
+	if hc.Resources.PidsLimit == nil || *hc.Resources.PidsLimit != 256 {
+		t.Fatalf("PidsLimit = %v, want 256", hc.Resources.PidsLimit)
+	}
+	if len(hc.Resources.Ulimits) != 2 || hc.Resources.Ulimits[0].Name != "nofile" || hc.Resources.Ulimits[0].Hard != 1024 || hc.Resources.Ulimits[1].Name != "nproc" {
+		t.Fatalf("Ulimits = %v", hc.Resources.Ulimits)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if (Profile{}).Fingerprint() != "" {
+		t.Fatal("zero profile has a fingerprint")
+	}
+	cfg := &config.Config{}
+	def, _ := Resolve(cfg, Default)
+	strict, _ := Resolve(cfg, Strict)
+	if def.Fingerprint() == "" || def.Fingerprint() == strict.Fingerprint() {
+		t.Fatalf("fingerprints default=%q strict=%q", def.Fingerprint(), strict.Fingerprint())
+	}
+
+	a := Profile{CapDrop: []string{"NET_RAW", "MKNOD"}}
+	b := Profile{Name: Custom, CapDrop: []string{"MKNOD", "NET_RAW"}}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Fatal("fingerprint depends on name or capability order")
+	}
+}
@@ -0,0 +1,194 @@
+// Package provenance signs and verifies the link between a shim and the
+// image it runs, so a re-tagged local image is not executed in its place.
+package provenance
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// KeyFile is the signing key's path relative to the tuprwre base directory.
+const KeyFile = "keys/signing.key"
+
+// ErrUntrustedKey is returned by Verify when a signature was made with a key
+// that is neither the local key nor a trusted key.
+var ErrUntrustedKey = errors.New("signed by an untrusted key")
+
+// Statement is what a signature covers: a binary and the image ID it runs
+// in; how that image was produced (base image, install command or steps, or
+// the salted hashes standing in for them when redacted, and the build
+// context and registry source digests); and the settings it runs with
+// (hardening profile, credential forwards, persistent state and path
+// policy). Only fields that stay the same when a toolset moves between
+// machines are included.
+type Statement struct {
+	Binary         string   `json:"binary"`
+	ImageID        string   `json:"image_id"`
+	BaseImage      string   `json:"base_image,omitempty"`
+	InstallCommand string   `json:"install_command,omitempty"`
+	CommandHash    string   `json:"install_command_hash,omitempty"`
+	InstallSteps   []string `json:"install_steps,omitempty"`
+	StepsHash      string   `json:"install_steps_hash,omitempty"`
+	ContextDigest  string   `json:"context_digest,omitempty"`
+	SourceDigest   string   `json:"source_digest,omitempty"`
+	Hardening      string   `json:"hardening,omitempty"`
+	Forward        []string `json:"forward,omitempty"`
+	State          bool     `json:"state,omitempty"`
+	PathPolicy     string   `json:"path_policy,omitempty"`
+}
+
+// Signature is an ed25519 signature over a Statement.
+type Signature struct {
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// Key is an ed25519 signing key.
+type Key struct {
+	private ed25519.PrivateKey
+}
+
+// LoadOrCreateKey reads the signing key under baseDir, generating one on
+// first use.
+func LoadOrCreateKey(baseDir string) (*Key, error) {
+	path := filepath.Join(baseDir, KeyFile)
+	key, err := readKey(path)
+	if os.IsNotExist(err) {
+		return createKey(path)
+	}
+	return key, err
+}
+
+// LoadKey reads the signing key under baseDir. The error wraps
+// os.ErrNotExist when nothing has been signed yet.
+func LoadKey(baseDir string) (*Key, error) {
+	return readKey(filepath.Join(baseDir, KeyFile))
+}
+
+func readKey(path string) (*Key, error) {
+	payload, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(payload)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("signing key %s is not a PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", path)
+	}
+	return &Key{private: private}, nil
+}
+
+func createKey(path string) (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	// O_EXCL so two concurrent first installs do not overwrite each other's
+	// key; the loser reads the winner's.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		return readKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return &Key{private: private}, nil
+}
+
+// PublicKey returns the base64 public key, the form used in trusted_keys.
+func (k *Key) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey))
+}
+
+// KeyID returns a short fingerprint of the public key.
+func (k *Key) KeyID() string {
+	return keyID(k.private.Public().(ed25519.PublicKey))
+}
+
+func keyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign signs s.
+func (k *Key) Sign(s Statement) (*Signature, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode provenance statement: %w", err)
+	}
+	return &Signature{
+		KeyID:     k.KeyID(),
+		PublicKey: k.PublicKey(),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, payload)),
+	}, nil
+}
+
+// Verify checks that sig is a valid signature over s by one of trusted
+// (base64 public keys).
+func Verify(s Statement, sig *Signature, trusted []string) error {
+	if sig == nil {
+		return fmt.Errorf("not signed")
+	}
+	public, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return fmt.Errorf("malformed signature public key")
+	}
+
+	isTrusted := false
+	for _, key := range trusted {
+		if key == sig.PublicKey {
+			isTrusted = true
+			break
+		}
+	}
+	if !isTrusted {
+		return fmt.Errorf("%w %s", ErrUntrustedKey, keyID(public))
+	}
+
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("malformed signature value")
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance statement: %w", err)
+	}
+	if !ed25519.Verify(public, payload, value) {
+		return fmt.Errorf("signature does not match metadata")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package provenance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	baseDir := t.TempDir()
+	key, err := LoadOrCreateKey(baseDir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(baseDir, KeyFile))
+	if err != nil {
+		t.Fatalf("key not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("key mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	again, err := LoadOrCreateKey(baseDir)
+	if err != nil {
+		t.Fatalf("reload key: %v", err)
+	}
+	if again.PublicKey() != key.PublicKey() {
+		t.Fatal("reloading returned a different key")
+	}
+
+	s := Statement{Binary: "jq", ImageID: "sha256:aaa", BaseImage: "ubuntu:22.04", InstallCommand: "apt-get install -y jq"}
+	sig, err := key.Sign(s)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	trusted := []string{key.PublicKey()}
+
+	if err := Verify(s, sig, trusted); err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	tampered := s
+	tampered.ImageID = "sha256:bbb"
+	if err := Verify(tampered, sig, trusted); err == nil {
+		t.Fatal("expected a changed image ID to fail verification")
+	}
+
+	other, err := LoadOrCreateKey(t.TempDir())
+	if err != nil {
+		t.Fatalf("create second key: %v", err)
+	}
+	if err := Verify(s, sig, []string{other.PublicKey()}); !errors.Is(err, ErrUntrustedKey) {
+		t.Fatalf("Verify() with untrusted key = %v, want ErrUntrustedKey", err)
+	}
+	if err := Verify(s, nil, trusted); err == nil {
+		t.Fatal("expected a missing signature to fail verification")
+	}
+}
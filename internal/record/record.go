@@ -0,0 +1,293 @@
+// Package record defines the on-disk form of a recorded run: the input and
+// output streams, an optional workspace snapshot and a JSON record
+// describing how the tool was invoked and how the run went.
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Files inside a record directory.
+const (
+	File       = "record.json"
+	StdinFile  = "stdin"
+	StdoutFile = "stdout"
+	StderrFile = "stderr"
+	// SnapshotDir holds the workspace files, at their path relative to
+	// Snapshot.Root.
+	SnapshotDir = "snapshot"
+)
+
+// Version is the record format version.
+const Version = 1
+
+// maxSkippedPaths caps Snapshot.SkippedPaths; Skipped still counts every
+// file left out.
+const maxSkippedPaths = 1000
+
+// Record describes one run.
+type Record struct {
+	Version int      `json:"version"`
+	Argv    []string `json:"argv"`
+	// EnvKeys names every variable set in the container, including the
+	// ones tuprwre adds (HOME, forwarded credentials).
+	EnvKeys []string `json:"env_keys,omitempty"`
+	// Env is the explicitly passed environment (--env), with credential
+	// values masked.
+	Env     []string `json:"env,omitempty"`
+	Image   string   `json:"image"`
+	ImageID string   `json:"image_id,omitempty"`
+	WorkDir string   `json:"workdir,omitempty"`
+	// Mounts lists every bind of the run. Volumes are the workspace and
+	// --volume binds among them, and PathMounts the read-only binds of
+	// host directories that arguments named.
+	Mounts     []string `json:"mounts,omitempty"`
+	Volumes    []string `json:"volumes,omitempty"`
+	PathMounts []string `json:"path_mounts,omitempty"`
+	// State and Forwarded tell whether the shim's persistent home and
+	// forwarded credential files were mounted.
+	State     bool `json:"state,omitempty"`
+	Forwarded bool `json:"forwarded,omitempty"`
+
+	NoNetwork bool   `json:"no_network,omitempty"`
+	Hardening string `json:"hardening,omitempty"`
+
+	// Snapshot describes the workspace copy taken before the run, if any.
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+
+	// Path is how the run executed: pool, cold or exec.
+	Path         string `json:"path,omitempty"`
+	PoolHit      bool   `json:"pool_hit,omitempty"`
+	PoolFallback bool   `json:"pool_fallback,omitempty"`
+	ContainerID  string `json:"container_id,omitempty"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Timings    Timings   `json:"timings"`
+}
+
+// Snapshot describes a record's copy of the workspace.
+type Snapshot struct {
+	// Root is the host directory that was copied.
+	Root  string `json:"root"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	// Skipped counts the files left out to stay under the size cap, and
+	// SkippedPaths names them, relative to Root, up to maxSkippedPaths.
+	Skipped      int      `json:"skipped,omitempty"`
+	SkippedPaths []string `json:"skipped_paths,omitempty"`
+}
+
+// Timings are measured from `tuprwre run` starting.
+type Timings struct {
+	TotalMs     int64 `json:"total_ms"`
+	OverheadMs  int64 `json:"overhead_ms,omitempty"`
+	FirstByteMs int64 `json:"first_byte_ms,omitempty"`
+}
+
+// NewID returns a new record ID: the UTC time plus a random suffix, so IDs
+// sort by age.
+func NewID() string {
+	return time.Now().UTC().Format("20060102-150405") + "-" + uuid.NewString()[:6]
+}
+
+// Streams are the stream files of a record being written.
+type Streams struct {
+	Stdin, Stdout, Stderr *os.File
+}
+
+// Close closes every stream file.
+func (s Streams) Close() {
+	for _, f := range []*os.File{s.Stdin, s.Stdout, s.Stderr} {
+		_ = f.Close()
+	}
+}
+
+// Create makes the record directory dir and opens its stream files. The
+// directory and files are private to the user: tool input and output can
+// contain anything.
+func Create(dir string) (Streams, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Streams{}, fmt.Errorf("failed to create record directory: %w", err)
+	}
+	var s Streams
+	for _, f := range []struct {
+		name string
+		file **os.File
+	}{{StdinFile, &s.Stdin}, {StdoutFile, &s.Stdout}, {StderrFile, &s.Stderr}} {
+		file, err := createFile(filepath.Join(dir, f.name))
+		if err != nil {
+			s.Close()
+			return Streams{}, err
+		}
+		*f.file = file
+	}
+	return s, nil
+}
+
+// TakeSnapshot copies the regular files and symlinks under root into dir's
+// snapshot directory. tuprwre cannot tell which files a tool will read, so
+// the whole tree is copied, in lexical walk order; a file that would take
+// the copy over maxBytes is left out and listed in SkippedPaths, and
+// smaller files after it are still copied. The record directory itself is
+// skipped when it lies under root.
+func TakeSnapshot(dir, root string, maxBytes int64) (*Snapshot, error) {
+	snap := &Snapshot{Root: root}
+	dest := filepath.Join(dir, SnapshotDir)
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, fmt.Errorf("failed to clear snapshot: %w", err)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve record directory: %w", err)
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable corners are left out rather than failing the run.
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path == absDir {
+			return fs.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return nil
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if snap.Bytes+info.Size() > maxBytes {
+				snap.Skipped++
+				if len(snap.SkippedPaths) < maxSkippedPaths {
+					snap.SkippedPaths = append(snap.SkippedPaths, rel)
+				}
+				return nil
+			}
+			if err := copyFile(path, target, info.Mode().Perm()); err != nil {
+				return nil
+			}
+			snap.Files++
+			snap.Bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %w", root, err)
+	}
+	return snap, nil
+}
+
+// Restore copies dir's snapshot into scratch, which stands in for
+// Snapshot.Root.
+func Restore(dir, scratch string) error {
+	src := filepath.Join(dir, SnapshotDir)
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(scratch, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// Write stores r as dir's record.json.
+func Write(dir string, r Record) error {
+	r.Version = Version
+	payload, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run record: %w", err)
+	}
+	tmp := filepath.Join(dir, File+".tmp")
+	if err := os.WriteFile(tmp, append(payload, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write run record: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, File)); err != nil {
+		return fmt.Errorf("failed to write run record: %w", err)
+	}
+	return nil
+}
+
+// Load reads the record in dir.
+func Load(dir string) (Record, error) {
+	payload, err := os.ReadFile(filepath.Join(dir, File))
+	if err != nil {
+		return Record{}, err
+	}
+	var r Record
+	if err := json.Unmarshal(payload, &r); err != nil {
+		return Record{}, fmt.Errorf("failed to parse run record: %w", err)
+	}
+	return r, nil
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func createFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	return f, nil
+}
@@ -0,0 +1,105 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreateWriteLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	streams, err := Create(dir)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	streams.Close()
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Fatalf("record dir mode = %o, want 700", perm)
+	}
+	for _, name := range []string{StdinFile, StdoutFile, StderrFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("%s: %v, %v; want mode 600", name, info, err)
+		}
+	}
+
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := Record{
+		Argv:       []string{"jq", "."},
+		EnvKeys:    []string{"HOME"},
+		Image:      "tuprwre-jq:1",
+		ImageID:    "sha256:abc",
+		ExitCode:   2,
+		Path:       "pool",
+		PoolHit:    true,
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Second),
+		Timings:    Timings{TotalMs: 1000, OverheadMs: 40},
+	}
+	if err := Write(dir, want); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	want.Version = Version
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Load() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	root := t.TempDir()
+	recordDir := filepath.Join(root, ".runs", "1")
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for name, content := range map[string]string{"src/main.go": "package main\n", "big.bin": "0123456789", "go.mod": "module x\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := os.Symlink("src/main.go", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	streams, err := Create(recordDir)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	streams.Close()
+
+	// big.bin comes first and fills the cap; the record directory itself
+	// is never copied.
+	snap, err := TakeSnapshot(recordDir, root, 12)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() failed: %v", err)
+	}
+	if snap.Files != 1 || snap.Bytes != 10 || snap.Skipped != 2 {
+		t.Fatalf("snapshot = %+v, want 1 file of 10 bytes and 2 skipped", snap)
+	}
+	if want := []string{"go.mod", filepath.Join("src", "main.go")}; !reflect.DeepEqual(snap.SkippedPaths, want) {
+		t.Fatalf("SkippedPaths = %q, want %q", snap.SkippedPaths, want)
+	}
+	if _, err := os.Stat(filepath.Join(recordDir, SnapshotDir, ".runs", "1")); !os.IsNotExist(err) {
+		t.Fatalf("record directory was snapshotted: %v", err)
+	}
+
+	if snap, err = TakeSnapshot(recordDir, root, 1<<20); err != nil || snap.Files != 3 || snap.Skipped != 0 {
+		t.Fatalf("TakeSnapshot() = %+v, %v", snap, err)
+	}
+	scratch := t.TempDir()
+	if err := Restore(recordDir, scratch); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(scratch, "link")); err != nil || string(got) != "package main\n" {
+		t.Fatalf("restored link = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,191 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/record"
+	"github.com/c4rb0nx1/tuprwre/internal/redact"
+)
+
+// openCaptures tees the run's output into the capture files: CaptureFile
+// gets both streams interleaved, CaptureStdout and CaptureStderr one each.
+// closeAll closes whatever was opened.
+func openCaptures(opts RunOptions) (stdout, stderr io.Writer, closeAll func(), err error) {
+	stdout, stderr = opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	var files []*os.File
+	closeAll = func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+	open := func(path, what string) (*os.File, error) {
+		f, err := os.Create(path)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to create %s: %w", what, err)
+		}
+		files = append(files, f)
+		return f, nil
+	}
+
+	if opts.CaptureFile != "" {
+		f, err := open(opts.CaptureFile, "capture file")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stdout = io.MultiWriter(stdout, f)
+		stderr = io.MultiWriter(stderr, f)
+	}
+	if opts.CaptureStdout != "" {
+		f, err := open(opts.CaptureStdout, "stdout capture file")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stdout = io.MultiWriter(stdout, f)
+	}
+	if opts.CaptureStderr != "" {
+		f, err := open(opts.CaptureStderr, "stderr capture file")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stderr = io.MultiWriter(stderr, f)
+	}
+	return stdout, stderr, closeAll, nil
+}
+
+// runRecording writes a run's record directory (see internal/record).
+type runRecording struct {
+	dir      string
+	streams  record.Streams
+	snapshot *record.Snapshot
+}
+
+// startRecording creates the record directory for opts, snapshotting the
+// workspace first when asked to.
+func startRecording(opts RunOptions) (*runRecording, error) {
+	streams, err := record.Create(opts.RecordDir)
+	if err != nil {
+		return nil, err
+	}
+	r := &runRecording{dir: opts.RecordDir, streams: streams}
+	if opts.RecordSnapshot != "" {
+		if r.snapshot, err = record.TakeSnapshot(opts.RecordDir, opts.RecordSnapshot, opts.RecordSnapshotMax); err != nil {
+			streams.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// wrap tees the run's streams into the record's stream files.
+func (r *runRecording) wrap(stdin io.Reader, stdout, stderr io.Writer) (io.Reader, io.Writer, io.Writer) {
+	if stdin != nil {
+		stdin = io.TeeReader(stdin, r.streams.Stdin)
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	return stdin, io.MultiWriter(stdout, r.streams.Stdout), io.MultiWriter(stderr, r.streams.Stderr)
+}
+
+// finish closes the stream files and writes record.json for the run.
+func (r *runRecording) finish(d *DockerRuntime, opts RunOptions, diag runIODiagnostics, exitCode int, runErr error) error {
+	r.streams.Close()
+
+	var extra []string
+	if d.config != nil {
+		extra = d.config.RedactPatterns
+	}
+	redactor, err := redact.New(extra)
+	if err != nil {
+		return err
+	}
+
+	finishedAt := time.Now()
+	rec := record.Record{
+		Argv:       append([]string{opts.Binary}, opts.Args...),
+		EnvKeys:    envKeys(opts.env()),
+		Env:        redactor.Strings(opts.Env),
+		Image:      opts.Image,
+		ImageID:    d.recordImageID(opts),
+		WorkDir:    opts.WorkDir,
+		Mounts:     opts.binds(),
+		Volumes:    opts.Volumes,
+		PathMounts: opts.PathMounts,
+		State:      opts.StateDir != "",
+		Forwarded:  len(opts.Forward.Binds) > 0,
+		NoNetwork:  opts.NoNetwork,
+		Hardening:  opts.Hardening.Name,
+		Snapshot:   r.snapshot,
+		ExitCode:   exitCode,
+		StartedAt:  diag.start,
+		FinishedAt: finishedAt,
+	}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+
+	t := diag.trace
+	t.mu.Lock()
+	rec.Path = t.path
+	rec.PoolHit = t.path == runPathPool && t.poolHit
+	rec.PoolFallback = t.fallback
+	rec.ContainerID = t.containerID
+	rec.Timings.TotalMs = finishedAt.Sub(diag.start).Milliseconds()
+	if !t.commandAt.IsZero() {
+		rec.Timings.OverheadMs = t.commandAt.Sub(diag.start).Milliseconds()
+	}
+	if !t.firstByteAt.IsZero() {
+		rec.Timings.FirstByteMs = t.firstByteAt.Sub(diag.start).Milliseconds()
+	}
+	t.mu.Unlock()
+
+	return record.Write(r.dir, rec)
+}
+
+// recordImageID returns the image ID the run used, as far as it is known.
+func (d *DockerRuntime) recordImageID(opts RunOptions) string {
+	if opts.ExpectedImageID != "" {
+		return opts.ExpectedImageID
+	}
+	if d.client == nil || opts.Image == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	img, err := d.client.ImageInspect(ctx, opts.Image)
+	if err != nil {
+		return ""
+	}
+	return img.ID
+}
+
+// envKeys returns the variable names of env, in order and without
+// duplicates.
+func envKeys(env []string) []string {
+	seen := make(map[string]bool, len(env))
+	var keys []string
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
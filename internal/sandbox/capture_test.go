@@ -0,0 +1,96 @@
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/record"
+)
+
+func TestOpenCapturesSeparatesStreams(t *testing.T) {
+	dir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	opts := RunOptions{
+		Stdout:        &stdout,
+		Stderr:        &stderr,
+		CaptureFile:   filepath.Join(dir, "combined"),
+		CaptureStdout: filepath.Join(dir, "out"),
+		CaptureStderr: filepath.Join(dir, "err"),
+	}
+	out, errOut, closeAll, err := openCaptures(opts)
+	if err != nil {
+		t.Fatalf("openCaptures() failed: %v", err)
+	}
+	_, _ = out.Write([]byte("OUT"))
+	_, _ = errOut.Write([]byte("ERR"))
+	closeAll()
+
+	for path, want := range map[string]string{opts.CaptureFile: "OUTERR", opts.CaptureStdout: "OUT", opts.CaptureStderr: "ERR"} {
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", filepath.Base(path), got, err, want)
+		}
+	}
+	if stdout.String() != "OUT" || stderr.String() != "ERR" {
+		t.Fatalf("terminal streams = %q, %q", stdout.String(), stderr.String())
+	}
+
+	opts.CaptureStderr = filepath.Join(dir, "missing", "err")
+	if _, _, _, err := openCaptures(opts); err == nil {
+		t.Fatal("expected an uncreatable capture file to fail the run")
+	}
+}
+
+func TestEnvKeys(t *testing.T) {
+	got := envKeys([]string{"HOME=/home/tuprwre", "TOKEN=secret", "HOME=/root", "EMPTY="})
+	if want := []string{"HOME", "TOKEN", "EMPTY"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("envKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestDockerRuntimeRun_RecordDir(t *testing.T) {
+	rt := requireDockerRuntime(t)
+
+	recordDir := filepath.Join(t.TempDir(), "record")
+	var stdout, stderr bytes.Buffer
+	exitCode, err := runWithTimeout(t, rt, RunOptions{
+		Image:     "ubuntu:22.04",
+		Binary:    "sh",
+		Args:      []string{"-c", "printf OUT; printf ERR >&2; exit 3"},
+		Env:       []string{"TOKEN=secret"},
+		Stdout:    &stdout,
+		Stderr:    &stderr,
+		RecordDir: recordDir,
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if exitCode != 3 {
+		t.Fatalf("unexpected exit code: %d", exitCode)
+	}
+
+	for name, want := range map[string]string{record.StdoutFile: "OUT", record.StderrFile: "ERR"} {
+		got, err := os.ReadFile(filepath.Join(recordDir, name))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	rec, err := record.Load(recordDir)
+	if err != nil {
+		t.Fatalf("load record: %v", err)
+	}
+	if rec.ExitCode != 3 || rec.Path != runPathCold || rec.ImageID == "" || rec.Argv[0] != "sh" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if !reflect.DeepEqual(rec.EnvKeys, []string{"TOKEN"}) {
+		t.Fatalf("env keys = %v, want [TOKEN]", rec.EnvKeys)
+	}
+	payload, _ := os.ReadFile(filepath.Join(recordDir, record.File))
+	if strings.Contains(string(payload), "secret") {
+		t.Fatalf("record leaks an env value:\n%s", payload)
+	}
+}
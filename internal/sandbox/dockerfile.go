@@ -0,0 +1,306 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// dockerfileContextName is where a Dockerfile from outside the build
+// context is placed inside the uploaded context.
+const dockerfileContextName = ".tuprwre.Dockerfile"
+
+// DockerfileBuild describes an install from a Dockerfile.
+type DockerfileBuild struct {
+	Dockerfile string
+	ContextDir string
+	Tag        string
+	Resources  ResourcePolicy
+	// NoNetwork runs the build's RUN instructions without network access.
+	NoNetwork bool
+}
+
+// contextEntry is one file or directory of a build context.
+type contextEntry struct {
+	name string // slash-separated path inside the context
+	path string // host path
+	info fs.FileInfo
+}
+
+// BuildDockerfile builds b.Dockerfile with b.ContextDir as build context
+// through the Engine build API, tags the result as b.Tag and returns the
+// image ID. Build output is streamed to stdout.
+func (d *DockerRuntime) BuildDockerfile(ctx context.Context, b DockerfileBuild) (imageID string, err error) {
+	if err := d.initClient(); err != nil {
+		return "", err
+	}
+
+	ctx, span := telemetry.Start(ctx, "install.build", telemetry.AttrImage.String(b.Tag))
+	defer func() { telemetry.End(span, err) }()
+
+	entries, dockerfileName, err := buildContextEntries(b.ContextDir, b.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeBuildContext(pw, entries))
+	}()
+	defer pr.Close()
+
+	options := build.ImageBuildOptions{
+		Tags:        []string{b.Tag},
+		Dockerfile:  dockerfileName,
+		Remove:      true,
+		ForceRemove: true,
+		Memory:      b.Resources.Memory,
+		Version:     build.BuilderV1,
+	}
+	if b.NoNetwork {
+		options.NetworkMode = "none"
+	}
+	if b.Resources.CPUs > 0 {
+		options.CPUPeriod = 100000
+		options.CPUQuota = int64(b.Resources.CPUs * 100000)
+	}
+
+	resp, err := d.client.ImageBuild(ctx, pr, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to start image build: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, os.Stdout, 0, false, nil); err != nil {
+		return "", fmt.Errorf("image build failed: %w", err)
+	}
+
+	img, err := d.client.ImageInspect(ctx, b.Tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect built image %s: %w", b.Tag, err)
+	}
+	return img.ID, nil
+}
+
+// BuildContextDigest hashes the paths, modes and contents of everything a
+// build of dockerfile in contextDir would upload, honoring .dockerignore.
+func BuildContextDigest(contextDir, dockerfile string) (string, error) {
+	entries, _, err := buildContextEntries(contextDir, dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(h, "%s %o\n", entry.name, entry.info.Mode())
+		switch {
+		case entry.info.Mode().IsRegular():
+			f, err := os.Open(entry.path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", entry.path, err)
+			}
+			_, err = io.Copy(h, f)
+			_ = f.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", entry.path, err)
+			}
+		case entry.info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(entry.path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read link %s: %w", entry.path, err)
+			}
+			_, _ = io.WriteString(h, target)
+		}
+		_, _ = io.WriteString(h, "\n")
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildContextEntries lists the build context in lexical order and returns
+// the name the Dockerfile has inside it.
+func buildContextEntries(contextDir, dockerfile string) ([]contextEntry, string, error) {
+	contextDir, err := filepath.Abs(contextDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve build context: %w", err)
+	}
+	dockerfile, err = filepath.Abs(dockerfile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve Dockerfile: %w", err)
+	}
+	if info, err := os.Stat(contextDir); err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("build context %s is not a directory", contextDir)
+	}
+	dockerfileInfo, err := os.Stat(dockerfile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	matcher, err := dockerignoreMatcher(contextDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dockerfileName := ""
+	if rel, err := filepath.Rel(contextDir, dockerfile); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		dockerfileName = filepath.ToSlash(rel)
+	}
+
+	var entries []contextEntry
+	err = filepath.WalkDir(contextDir, func(path string, dirEntry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if path == contextDir {
+			return nil
+		}
+		rel, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		// Docker always sends the Dockerfile and .dockerignore.
+		keep := name == dockerfileName || name == ".dockerignore"
+		if !keep && matcher != nil {
+			ignored, err := matcher.MatchesOrParentMatches(name)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate .dockerignore for %s: %w", name, err)
+			}
+			if ignored {
+				if dirEntry.IsDir() && !matcher.Exclusions() && !strings.HasPrefix(dockerfileName, name+"/") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, contextEntry{name: name, path: path, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read build context: %w", err)
+	}
+
+	if dockerfileName == "" {
+		dockerfileName = dockerfileContextName
+		entries = append(entries, contextEntry{name: dockerfileName, path: dockerfile, info: dockerfileInfo})
+	}
+	return entries, dockerfileName, nil
+}
+
+func dockerignoreMatcher(contextDir string) (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	defer f.Close()
+
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .dockerignore: %w", err)
+	}
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .dockerignore: %w", err)
+	}
+	return matcher, nil
+}
+
+func writeBuildContext(w io.Writer, entries []contextEntry) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		link := ""
+		if entry.info.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(entry.path)
+			if err != nil {
+				return fmt.Errorf("failed to read link %s: %w", entry.path, err)
+			}
+			link = target
+		}
+		header, err := tar.FileInfoHeader(entry.info, link)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to build context: %w", entry.name, err)
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		}
+		header.Uname, header.Gname = "", ""
+		header.Uid, header.Gid = 0, 0
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to add %s to build context: %w", entry.name, err)
+		}
+		if !entry.info.Mode().IsRegular() {
+			continue
+		}
+		f, err := os.Open(entry.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.path, err)
+		}
+		_, err = io.Copy(tw, f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to add %s to build context: %w", entry.name, err)
+		}
+	}
+	return tw.Close()
+}
+
+// DockerfileBaseImage returns the image the final stage of a Dockerfile
+// builds on, following references to earlier stages. It returns "" when the
+// base cannot be determined statically (build args) or is scratch.
+func DockerfileBaseImage(content []byte) string {
+	stages := map[string]string{}
+	base := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		image := args[0]
+		if resolved, ok := stages[strings.ToLower(image)]; ok {
+			image = resolved
+		}
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = image
+		}
+		base = image
+	}
+
+	if base == "" || strings.Contains(base, "$") || strings.EqualFold(base, "scratch") {
+		return ""
+	}
+	return base
+}
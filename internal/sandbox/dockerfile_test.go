@@ -0,0 +1,120 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestDockerfileBaseImage(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "single", in: "FROM ubuntu:22.04\nRUN true\n", want: "ubuntu:22.04"},
+		{name: "platform flag", in: "from --platform=linux/amd64 alpine:3.20\n", want: "alpine:3.20"},
+		{name: "last stage wins", in: "FROM golang:1.25 AS build\nRUN go build\nFROM debian:12\nCOPY --from=build /x /x\n", want: "debian:12"},
+		{name: "stage reference", in: "FROM node:22 AS base\nFROM base AS tools\nRUN npm i -g x\nFROM tools\n", want: "node:22"},
+		{name: "build arg", in: "ARG BASE=ubuntu\nFROM $BASE\n", want: ""},
+		{name: "scratch", in: "FROM scratch\nCOPY x /x\n", want: ""},
+		{name: "none", in: "RUN true\n", want: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DockerfileBaseImage([]byte(tc.in)); got != tc.want {
+				t.Fatalf("DockerfileBaseImage() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestBuildContextDigestHonorsDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":        "FROM alpine:3.20\nCOPY tool.sh /usr/local/bin/tool\n",
+		"tool.sh":           "echo v1\n",
+		".dockerignore":     "node_modules\n*.log\n",
+		"node_modules/a.js": "ignored",
+		"build.log":         "ignored",
+	})
+	dockerfile := filepath.Join(dir, "Dockerfile")
+
+	digest, err := BuildContextDigest(dir, dockerfile)
+	if err != nil {
+		t.Fatalf("BuildContextDigest() failed: %v", err)
+	}
+
+	writeFiles(t, dir, map[string]string{"build.log": "changed", "node_modules/b.js": "new"})
+	if again, _ := BuildContextDigest(dir, dockerfile); again != digest {
+		t.Fatal("ignored files must not change the digest")
+	}
+
+	writeFiles(t, dir, map[string]string{"tool.sh": "echo v2\n"})
+	if changed, _ := BuildContextDigest(dir, dockerfile); changed == digest {
+		t.Fatal("editing a context file must change the digest")
+	}
+}
+
+func TestWriteBuildContextIncludesOutsideDockerfile(t *testing.T) {
+	root := t.TempDir()
+	contextDir := filepath.Join(root, "ctx")
+	writeFiles(t, root, map[string]string{
+		"tools.Dockerfile": "FROM alpine:3.20\n",
+		"ctx/bin/tool":     "#!/bin/sh\n",
+	})
+
+	entries, name, err := buildContextEntries(contextDir, filepath.Join(root, "tools.Dockerfile"))
+	if err != nil {
+		t.Fatalf("buildContextEntries() failed: %v", err)
+	}
+	if name != dockerfileContextName {
+		t.Fatalf("dockerfile name = %q, want %q", name, dockerfileContextName)
+	}
+
+	var buf bytes.Buffer
+	if err := writeBuildContext(&buf, entries); err != nil {
+		t.Fatalf("writeBuildContext() failed: %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	want := []string{dockerfileContextName, "bin/", "bin/tool"}
+	sort.Strings(want)
+	if len(names) != len(want) {
+		t.Fatalf("tar entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("tar entries = %v, want %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/google/uuid"
+)
+
+// hostAllowed reports whether host matches an allowlist entry. "*.suffix"
+// entries match any subdomain of suffix.
+func hostAllowed(host string, allow []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+	for _, entry := range allow {
+		if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// hopHeaders are dropped when a request or response passes the proxy.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// egressProxy is the HTTP(S) proxy an install container with an egress
+// allowlist talks to. HTTPS is tunnelled with CONNECT, so the allowlist
+// applies to the host name the client asks for.
+type egressProxy struct {
+	allow     []string
+	transport http.RoundTripper
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	logf      func(format string, args ...any)
+}
+
+func newEgressProxy(allow []string) *egressProxy {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return &egressProxy{
+		allow: allow,
+		transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 30 * time.Second,
+		},
+		dial: dialer.DialContext,
+		logf: func(format string, args ...any) {
+			_, _ = fmt.Fprintf(os.Stderr, format, args...)
+		},
+	}
+}
+
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+	}
+	if !hostAllowed(host, p.allow) {
+		p.logf("egress: blocked %s %s\n", r.Method, host)
+		http.Error(w, fmt.Sprintf("egress to %s is not allowed by the install policy", host), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "only absolute http:// requests and CONNECT are proxied", http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("egress to %s failed: %v", host, err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *egressProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	addr := r.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+	upstream, err := p.dial(r.Context(), "tcp", addr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("egress to %s failed: %v", r.Host, err), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "proxy does not support CONNECT", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+
+	// Bytes the client sent right after CONNECT may already be buffered.
+	if n := buf.Reader.Buffered(); n > 0 {
+		pending, _ := buf.Reader.Peek(n)
+		if _, err := upstream.Write(pending); err != nil {
+			conn.Close()
+			upstream.Close()
+			return
+		}
+	}
+
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			conn.Close()
+			upstream.Close()
+		})
+	}
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		closeBoth()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	closeBoth()
+}
+
+// egressGateway is a running allowlist proxy and the internal network its
+// container is attached to.
+type egressGateway struct {
+	networkID string
+	proxyURL  string
+	server    *http.Server
+}
+
+// env returns the proxy variables the install container needs.
+func (g *egressGateway) env() []string {
+	return []string{
+		"HTTP_PROXY=" + g.proxyURL,
+		"HTTPS_PROXY=" + g.proxyURL,
+		"http_proxy=" + g.proxyURL,
+		"https_proxy=" + g.proxyURL,
+	}
+}
+
+// startEgressGateway creates an internal network, whose only way out is a
+// proxy listening on the network's gateway address, and starts that proxy.
+// The gateway address is a host interface, so this needs Docker Engine on
+// Linux.
+func (d *DockerRuntime) startEgressGateway(ctx context.Context, allow []string) (*egressGateway, error) {
+	name := fmt.Sprintf("tuprwre-egress-%s", uuid.New().String()[:8])
+	created, err := d.client.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: true,
+		Labels:   map[string]string{"tuprwre.egress": "true"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create egress network: %w", err)
+	}
+	g := &egressGateway{networkID: created.ID}
+
+	inspect, err := d.client.NetworkInspect(ctx, created.ID, network.InspectOptions{})
+	if err != nil {
+		d.stopEgressGateway(g)
+		return nil, fmt.Errorf("failed to inspect egress network: %w", err)
+	}
+	gateway := ""
+	for _, ipam := range inspect.IPAM.Config {
+		if ip := net.ParseIP(ipam.Gateway); ip != nil && ip.To4() != nil {
+			gateway = ipam.Gateway
+			break
+		}
+	}
+	if gateway == "" {
+		d.stopEgressGateway(g)
+		return nil, fmt.Errorf("egress network %s has no IPv4 gateway", name)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(gateway, "0"))
+	if err != nil {
+		d.stopEgressGateway(g)
+		return nil, fmt.Errorf("failed to start egress proxy on %s (egress allowlists need Docker Engine on Linux): %w", gateway, err)
+	}
+	g.proxyURL = "http://" + listener.Addr().String()
+	g.server = &http.Server{Handler: newEgressProxy(allow), ReadHeaderTimeout: 30 * time.Second}
+	go func() { _ = g.server.Serve(listener) }()
+	return g, nil
+}
+
+// stopEgressGateway stops the proxy and removes the network.
+func (d *DockerRuntime) stopEgressGateway(g *egressGateway) {
+	if g.server != nil {
+		_ = g.server.Close()
+	}
+	_ = d.client.NetworkRemove(context.Background(), g.networkID)
+}
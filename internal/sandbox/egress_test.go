@@ -0,0 +1,106 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHostAllowed(t *testing.T) {
+	allow := []string{"example.com", "*.github.com"}
+	tests := map[string]bool{
+		"example.com":              true,
+		"EXAMPLE.com.":             true,
+		"www.example.com":          false,
+		"github.com":               false,
+		"api.github.com":           true,
+		"objects.api.github.com":   true,
+		"github.com.attacker.test": false,
+		"notgithub.com":            false,
+		"":                         false,
+	}
+	for host, want := range tests {
+		if got := hostAllowed(host, allow); got != want {
+			t.Errorf("hostAllowed(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestEgressProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "hello from %s%s", r.Host, r.URL.Path)
+	}))
+	defer backend.Close()
+	backendAddr := backend.Listener.Addr().String()
+
+	// Every allowed name resolves to the test backend.
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, backendAddr)
+	}
+	proxy := newEgressProxy([]string{"allowed.test"})
+	proxy.transport = &http.Transport{DialContext: dial}
+	proxy.dial = dial
+	var blocked []string
+	proxy.logf = func(format string, args ...any) { blocked = append(blocked, fmt.Sprintf(format, args...)) }
+
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://allowed.test/pkg")
+	if err != nil {
+		t.Fatalf("allowed request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from allowed.test/pkg" {
+		t.Fatalf("allowed request = %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = client.Get("http://denied.test/pkg")
+	if err != nil {
+		t.Fatalf("denied request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("denied request status = %d, want 403", resp.StatusCode)
+	}
+	if len(blocked) != 1 || !strings.Contains(blocked[0], "denied.test") {
+		t.Fatalf("blocked log = %q", blocked)
+	}
+
+	// CONNECT tunnels raw bytes once the host is allowed.
+	for host, wantStatus := range map[string]string{"allowed.test:443": "200", "denied.test:443": "403"} {
+		conn, err := net.Dial("tcp", proxyURL.Host)
+		if err != nil {
+			t.Fatalf("dial proxy: %v", err)
+		}
+		_, _ = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", host, host)
+		reader := bufio.NewReader(conn)
+		status, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read CONNECT status: %v", err)
+		}
+		if !strings.Contains(status, " "+wantStatus+" ") {
+			t.Fatalf("CONNECT %s status = %q, want %s", host, status, wantStatus)
+		}
+		if wantStatus == "200" {
+			_, _ = reader.ReadString('\n')
+			_, _ = fmt.Fprintf(conn, "GET /tunnel HTTP/1.1\r\nHost: allowed.test\r\nConnection: close\r\n\r\n")
+			tunnelled, _ := io.ReadAll(reader)
+			if !strings.Contains(string(tunnelled), "hello from allowed.test/tunnel") {
+				t.Fatalf("tunnelled response = %q", tunnelled)
+			}
+		}
+		conn.Close()
+	}
+}
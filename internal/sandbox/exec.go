@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/pkg/stdcopy"
 )
@@ -19,6 +20,8 @@ type ExecOptions struct {
 	Stdin       io.Reader
 	Stdout      io.Writer
 	Stderr      io.Writer
+
+	diag runIODiagnostics
 }
 
 // ExecWithExitCode runs a command inside an existing running container and returns
@@ -43,12 +46,15 @@ func (d *DockerRuntime) ExecWithExitCode(ctx context.Context, opts ExecOptions)
 	if err != nil {
 		return 1, fmt.Errorf("failed to create exec: %w", err)
 	}
+	opts.diag.event("exec-create")
 
 	attachResp, err := d.client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
 	if err != nil {
 		return 1, fmt.Errorf("failed to attach to exec: %w", err)
 	}
 	defer attachResp.Close()
+	// Attaching starts the exec.
+	opts.diag.trace.commandStarted()
 
 	stdout := opts.Stdout
 	stderr := opts.Stderr
@@ -66,7 +72,9 @@ func (d *DockerRuntime) ExecWithExitCode(ctx context.Context, opts ExecOptions)
 		}()
 	}
 
+	_, span := telemetry.Start(ctx, "run.io_drain")
 	_, _ = stdcopy.StdCopy(stdout, stderr, attachResp.Reader)
+	span.End()
 
 	inspectResp, err := d.client.ContainerExecInspect(ctx, execResp.ID)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
 	"github.com/docker/docker/api/types/container"
 )
 
@@ -214,3 +215,17 @@ func TestRunViaExec_ContainerIDShortcut(t *testing.T) {
 	}
 }
 
+
+func TestRunViaExec_RefusesRunsNeedingMounts(t *testing.T) {
+	rt := &DockerRuntime{}
+	for _, opts := range []RunOptions{
+		{ContainerID: "abc", StateDir: "/tmp/state"},
+		{ContainerID: "abc", Forward: forward.Mounts{Binds: []string{"/run/agent.sock:/run/tuprwre/ssh-agent.sock"}}},
+		{ContainerID: "abc", PathMounts: []string{"/etc:/run/tuprwre/host/etc:ro"}},
+	} {
+		exitCode, err := rt.runViaExec(context.Background(), opts, runIODiagnostics{})
+		if err == nil || exitCode != 1 || !strings.Contains(err.Error(), "--container-id") {
+			t.Fatalf("runViaExec(%+v) = %d, %v; want a refusal", opts, exitCode, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/uuid"
+)
+
+// ErrImageMismatch is returned when an image no longer resolves to the ID
+// its shim was signed with.
+var ErrImageMismatch = errors.New("image does not match its signed image ID")
+
+// SaveImage writes imageName as a docker-archive tarball to w.
+func (d *DockerRuntime) SaveImage(ctx context.Context, imageName string, w io.Writer) error {
+	if err := d.initClient(); err != nil {
+		return err
+	}
+
+	reader, err := d.client.ImageSave(ctx, []string{imageName})
+	if err != nil {
+		return fmt.Errorf("failed to save image %s: %w", imageName, err)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to save image %s: %w", imageName, err)
+	}
+	return nil
+}
+
+// LoadImage loads a docker-archive or OCI tarball into the local image
+// store. Progress is streamed to stdout.
+func (d *DockerRuntime) LoadImage(ctx context.Context, r io.Reader) error {
+	if err := d.initClient(); err != nil {
+		return err
+	}
+
+	resp, err := d.client.ImageLoad(ctx, r)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if !resp.JSON {
+		_, err = io.Copy(os.Stdout, resp.Body)
+	} else {
+		err = jsonmessage.DisplayJSONMessagesStream(resp.Body, os.Stdout, 0, false, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+	return nil
+}
+
+// ImageID returns the ID of the local image imageName refers to.
+func (d *DockerRuntime) ImageID(ctx context.Context, imageName string) (string, error) {
+	if err := d.initClient(); err != nil {
+		return "", err
+	}
+
+	img, err := d.client.ImageInspect(ctx, imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+	return img.ID, nil
+}
+
+// verifyImageID checks that imageName is present locally and resolves to
+// want.
+func (d *DockerRuntime) verifyImageID(ctx context.Context, imageName, want string) error {
+	got, err := d.ImageID(ctx, imageName)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s resolves to %s, signed %s", ErrImageMismatch, imageName, shortImageID(got), shortImageID(want))
+	}
+	return nil
+}
+
+// ExportImageFilesystem streams the flattened filesystem of imageName as a
+// tar archive to fn. The image is not run; a container is only created to
+// export it.
+func (d *DockerRuntime) ExportImageFilesystem(ctx context.Context, imageName string, fn func(io.Reader) error) error {
+	if err := d.initClient(); err != nil {
+		return err
+	}
+
+	resp, err := d.client.ContainerCreate(ctx, &container.Config{
+		Image:      imageName,
+		Entrypoint: []string{"/tuprwre-export"}, // never started
+	}, &container.HostConfig{}, nil, nil, fmt.Sprintf("tuprwre-export-%s", uuid.New().String()[:8]))
+	if err != nil {
+		return fmt.Errorf("failed to create export container: %w", err)
+	}
+	defer d.CleanupContainer(context.Background(), resp.ID)
+
+	reader, err := d.client.ContainerExport(ctx, resp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to export image %s: %w", imageName, err)
+	}
+	defer reader.Close()
+	return fn(reader)
+}
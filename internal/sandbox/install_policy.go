@@ -0,0 +1,237 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Egress policies an install container can run under. An allowlist is
+// written as "allow=host[,host...]".
+const (
+	EgressOpen = "open"
+	EgressNone = "none"
+
+	egressAllowPrefix = "allow="
+)
+
+// InstallPolicy hardens the container an install command runs in. The zero
+// value keeps the historical behaviour: root with full capabilities, open
+// network and no PID or time limit.
+type InstallPolicy struct {
+	CapDrop         []string `json:"cap_drop,omitempty"`
+	NoNewPrivileges bool     `json:"no_new_privileges,omitempty"`
+	PidsLimit       int64    `json:"pids_limit,omitempty"`
+
+	// Egress is "open", "none" or "allow=host[,host...]". Allowlisted
+	// hosts are reached through an HTTP(S) proxy on an internal network;
+	// "*.example.com" matches any subdomain of example.com.
+	Egress string `json:"egress,omitempty"`
+
+	// ReadOnlyMounts are host:container path pairs bind-mounted read-only,
+	// e.g. a CA bundle the install needs.
+	ReadOnlyMounts []string `json:"ro_mounts,omitempty"`
+
+	// User runs the install as this user instead of root. With Fakeroot
+	// the command runs under fakeroot(1) when the image has it, so scripts
+	// that insist on root can still proceed.
+	User     string `json:"user,omitempty"`
+	Fakeroot bool   `json:"fakeroot,omitempty"`
+
+	// Timeout is a Go duration after which the install is killed.
+	Timeout string `json:"timeout,omitempty"`
+
+	// Secrets are exposed to the install command only; they are never
+	// serialized with the policy.
+	Secrets []Secret `json:"-"`
+}
+
+// IsZero reports whether p applies no hardening.
+func (p InstallPolicy) IsZero() bool {
+	return len(p.CapDrop) == 0 && !p.NoNewPrivileges && p.PidsLimit == 0 &&
+		(p.Egress == "" || p.Egress == EgressOpen) && len(p.ReadOnlyMounts) == 0 &&
+		p.User == "" && !p.Fakeroot && p.Timeout == ""
+}
+
+// Normalize validates p and returns it in the form that is applied and
+// recorded: capability names as Docker spells them, absolute mount
+// sources, a canonical egress policy and a canonical timeout.
+func (p InstallPolicy) Normalize() (InstallPolicy, error) {
+	out := p
+	out.CapDrop = nil
+	if len(p.CapDrop) > 0 {
+		out.CapDrop = hardening.NormalizeCaps(p.CapDrop)
+	}
+
+	if p.PidsLimit < 0 {
+		return InstallPolicy{}, fmt.Errorf("pids limit must not be negative")
+	}
+
+	egress, err := normalizeEgress(p.Egress)
+	if err != nil {
+		return InstallPolicy{}, err
+	}
+	out.Egress = egress
+
+	out.ReadOnlyMounts = nil
+	for _, spec := range p.ReadOnlyMounts {
+		mount, err := resolveReadOnlyMount(spec)
+		if err != nil {
+			return InstallPolicy{}, err
+		}
+		out.ReadOnlyMounts = append(out.ReadOnlyMounts, mount)
+	}
+
+	out.User = strings.TrimSpace(p.User)
+	if p.Fakeroot && out.User == "" {
+		return InstallPolicy{}, fmt.Errorf("fakeroot requires a non-root user")
+	}
+
+	if p.Timeout != "" {
+		timeout, err := time.ParseDuration(p.Timeout)
+		if err != nil {
+			return InstallPolicy{}, fmt.Errorf("invalid install timeout %q: %w", p.Timeout, err)
+		}
+		if timeout <= 0 {
+			return InstallPolicy{}, fmt.Errorf("install timeout must be positive")
+		}
+		out.Timeout = timeout.String()
+	}
+	return out, nil
+}
+
+// EgressAllowlist returns the hosts an "allow=" policy permits. ok is false
+// for any other policy.
+func (p InstallPolicy) EgressAllowlist() (hosts []string, ok bool) {
+	if !strings.HasPrefix(p.Egress, egressAllowPrefix) {
+		return nil, false
+	}
+	return strings.Split(strings.TrimPrefix(p.Egress, egressAllowPrefix), ","), true
+}
+
+// timeout returns the parsed Timeout, 0 when unset.
+func (p InstallPolicy) timeout() time.Duration {
+	timeout, _ := time.ParseDuration(p.Timeout)
+	return timeout
+}
+
+func normalizeEgress(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	switch strings.ToLower(spec) {
+	case "", EgressOpen:
+		return EgressOpen, nil
+	case EgressNone:
+		return EgressNone, nil
+	}
+	if !strings.HasPrefix(spec, egressAllowPrefix) {
+		return "", fmt.Errorf("invalid egress policy %q (want open, none or allow=host[,host...])", spec)
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(strings.TrimPrefix(spec, egressAllowPrefix), ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		name := strings.TrimPrefix(host, "*.")
+		if name == "" || strings.ContainsAny(name, "*/:@ ") {
+			return "", fmt.Errorf("invalid egress host %q", host)
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return "", fmt.Errorf("egress allowlist is empty; use --egress none to block the network")
+	}
+	return egressAllowPrefix + strings.Join(hosts, ","), nil
+}
+
+// resolveReadOnlyMount turns "host[:container]" into "abs-host:container".
+// The container path defaults to the host path.
+func resolveReadOnlyMount(spec string) (string, error) {
+	hostPath, containerPath, _ := strings.Cut(spec, ":")
+	if hostPath == "" {
+		return "", fmt.Errorf("invalid read-only mount %q (want host-path[:container-path])", spec)
+	}
+	if strings.HasPrefix(hostPath, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			hostPath = filepath.Join(home, hostPath[2:])
+		}
+	}
+	hostPath, err := filepath.Abs(hostPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve read-only mount %s: %w", spec, err)
+	}
+	if _, err := os.Stat(hostPath); err != nil {
+		return "", fmt.Errorf("read-only mount source %s: %w", hostPath, err)
+	}
+	if containerPath == "" {
+		containerPath = hostPath
+	}
+	if !strings.HasPrefix(containerPath, "/") {
+		return "", fmt.Errorf("read-only mount target %q must be an absolute path", containerPath)
+	}
+	return hostPath + ":" + filepath.Clean(containerPath), nil
+}
+
+// apply sets p's restrictions on the install container. An egress
+// allowlist is wired up separately, since it needs a proxy.
+func (p InstallPolicy) apply(cfg *container.Config, hc *container.HostConfig) {
+	hc.CapDrop = append(hc.CapDrop, p.CapDrop...)
+	if p.NoNewPrivileges {
+		hc.SecurityOpt = append(hc.SecurityOpt, "no-new-privileges:true")
+	}
+	if p.PidsLimit > 0 {
+		limit := p.PidsLimit
+		hc.Resources.PidsLimit = &limit
+	}
+	if p.Egress == EgressNone {
+		cfg.NetworkDisabled = true
+		hc.NetworkMode = "none"
+	}
+	for _, mount := range p.ReadOnlyMounts {
+		hc.Binds = append(hc.Binds, mount+":ro")
+	}
+	cfg.User = p.User
+	if len(p.Secrets) > 0 {
+		applySecrets(cfg, hc, p.User)
+	}
+}
+
+// fakerootWrapper runs the command passed as $0 under fakeroot when the
+// image provides it.
+const fakerootWrapper = `if command -v fakeroot >/dev/null 2>&1; then exec fakeroot -- sh -c "$0"; fi
+echo "tuprwre: fakeroot not found in the image; running as $(id -un 2>/dev/null || id -u)" >&2
+exec sh -c "$0"`
+
+// command returns the container command that runs an install command
+// under p.
+func (p InstallPolicy) command(command string) []string {
+	if !p.Fakeroot && len(p.Secrets) == 0 {
+		return []string{"sh", "-c", command}
+	}
+	script := ""
+	if len(p.Secrets) > 0 {
+		script = secretsPrelude
+	}
+	if p.Fakeroot {
+		script += fakerootWrapper
+	} else {
+		script += `exec sh -c "$0"`
+	}
+	return []string{"sh", "-c", script, command}
+}
+
+// cacheSalt is the part of p that changes what a step leaves behind, and so
+// belongs in its cache key. It is "" for root installs, which keeps keys
+// from before install policies stable.
+func (p InstallPolicy) cacheSalt() string {
+	if p.User == "" {
+		return ""
+	}
+	return fmt.Sprintf("user %s fakeroot %t\n", p.User, p.Fakeroot)
+}
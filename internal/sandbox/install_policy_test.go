@@ -0,0 +1,117 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestInstallPolicyNormalize(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(bundle, []byte("cert\n"), 0o644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+
+	got, err := InstallPolicy{
+		CapDrop:        []string{"cap_net_raw", " all "},
+		Egress:         "allow=Example.com, *.github.com",
+		ReadOnlyMounts: []string{bundle + ":/etc/ssl/certs/ca-certificates.crt", bundle},
+		User:           " 1000:1000 ",
+		Fakeroot:       true,
+		Timeout:        "90s",
+	}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	want := InstallPolicy{
+		CapDrop:        []string{"NET_RAW", "ALL"},
+		Egress:         "allow=example.com,*.github.com",
+		ReadOnlyMounts: []string{bundle + ":/etc/ssl/certs/ca-certificates.crt", bundle + ":" + bundle},
+		User:           "1000:1000",
+		Fakeroot:       true,
+		Timeout:        "1m30s",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Normalize() = %+v, want %+v", got, want)
+	}
+	if hosts, ok := got.EgressAllowlist(); !ok || !reflect.DeepEqual(hosts, []string{"example.com", "*.github.com"}) {
+		t.Fatalf("EgressAllowlist() = %v, %t", hosts, ok)
+	}
+
+	zero, err := InstallPolicy{}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	if zero.Egress != EgressOpen || !zero.IsZero() {
+		t.Fatalf("zero policy normalized to %+v", zero)
+	}
+
+	for name, bad := range map[string]InstallPolicy{
+		"unknown egress":     {Egress: "proxy"},
+		"empty allowlist":    {Egress: "allow=,"},
+		"host with port":     {Egress: "allow=example.com:443"},
+		"missing mount":      {ReadOnlyMounts: []string{filepath.Join(dir, "missing")}},
+		"relative target":    {ReadOnlyMounts: []string{bundle + ":certs.pem"}},
+		"fakeroot as root":   {Fakeroot: true},
+		"negative pids":      {PidsLimit: -1},
+		"bad timeout":        {Timeout: "soon"},
+		"non-positive limit": {Timeout: "0s"},
+	} {
+		if _, err := bad.Normalize(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestInstallPolicyApply(t *testing.T) {
+	policy := InstallPolicy{
+		CapDrop:         []string{"ALL"},
+		NoNewPrivileges: true,
+		PidsLimit:       128,
+		Egress:          EgressNone,
+		ReadOnlyMounts:  []string{"/host/ca.pem:/etc/ca.pem"},
+		User:            "nobody",
+	}
+	cfg := &container.Config{}
+	hc := &container.HostConfig{}
+	policy.apply(cfg, hc)
+
+	if !reflect.DeepEqual([]string(hc.CapDrop), []string{"ALL"}) {
+		t.Fatalf("CapDrop = %v", hc.CapDrop)
+	}
+	if !reflect.DeepEqual(hc.SecurityOpt, []string{"no-new-privileges:true"}) {
+		t.Fatalf("SecurityOpt = %v", hc.SecurityOpt)
+	}
+	if hc.Resources.PidsLimit == nil || *hc.Resources.PidsLimit != 128 {
+		t.Fatalf("PidsLimit = %v", hc.Resources.PidsLimit)
+	}
+	if !cfg.NetworkDisabled || hc.NetworkMode != "none" {
+		t.Fatalf("network not disabled: %v %q", cfg.NetworkDisabled, hc.NetworkMode)
+	}
+	if !reflect.DeepEqual(hc.Binds, []string{"/host/ca.pem:/etc/ca.pem:ro"}) {
+		t.Fatalf("Binds = %v", hc.Binds)
+	}
+	if cfg.User != "nobody" {
+		t.Fatalf("User = %q", cfg.User)
+	}
+
+	open := &container.HostConfig{}
+	InstallPolicy{}.apply(&container.Config{}, open)
+	if open.NetworkMode != "" || open.Resources.PidsLimit != nil || len(open.SecurityOpt) != 0 {
+		t.Fatalf("zero policy changed the host config: %+v", open)
+	}
+}
+
+func TestInstallPolicyCommand(t *testing.T) {
+	if got := (InstallPolicy{}).command("make install"); !reflect.DeepEqual(got, []string{"sh", "-c", "make install"}) {
+		t.Fatalf("command() = %v", got)
+	}
+	got := InstallPolicy{User: "1000", Fakeroot: true}.command("make install")
+	if len(got) != 4 || got[2] != fakerootWrapper || got[3] != "make install" {
+		t.Fatalf("fakeroot command() = %v", got)
+	}
+}
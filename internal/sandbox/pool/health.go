@@ -0,0 +1,174 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// HealthTarget is what a HealthCheck sees about a warm container it is
+// about to lease.
+type HealthTarget struct {
+	Client  *client.Client
+	Key     PoolKey
+	Inspect container.InspectResponse
+	Meta    ContainerMeta
+}
+
+// HealthCheck decides whether a warm container may be reused. A non-nil
+// error marks the container unhealthy; Acquire then replaces it.
+type HealthCheck interface {
+	Name() string
+	Check(ctx context.Context, target HealthTarget) error
+}
+
+// DefaultHealthChecks returns the checks used when PoolConfig.HealthChecks
+// is nil.
+func DefaultHealthChecks(cfg PoolConfig) []HealthCheck {
+	return []HealthCheck{
+		RecycleCheck{MaxUses: cfg.MaxUses, MaxAge: cfg.MaxAge},
+		BindSourcesCheck{},
+		ImageIDCheck{},
+		LivenessCheck{IdleThreshold: cfg.ProbeAfter},
+	}
+}
+
+// RecycleCheck retires containers after MaxUses leases or once they are
+// older than MaxAge. Zero disables the respective limit.
+type RecycleCheck struct {
+	MaxUses int
+	MaxAge  time.Duration
+}
+
+// Name implements HealthCheck.
+func (RecycleCheck) Name() string { return "recycle" }
+
+// Check implements HealthCheck.
+func (c RecycleCheck) Check(_ context.Context, target HealthTarget) error {
+	if c.MaxUses > 0 && target.Meta.Uses >= c.MaxUses {
+		return fmt.Errorf("served %d leases (max %d)", target.Meta.Uses, c.MaxUses)
+	}
+	if c.MaxAge > 0 && !target.Meta.CreatedAt.IsZero() {
+		if age := time.Since(target.Meta.CreatedAt); age > c.MaxAge {
+			return fmt.Errorf("age %s exceeds %s", age.Round(time.Second), c.MaxAge)
+		}
+	}
+	return nil
+}
+
+// BindSourcesCheck fails when a bind-mounted host path no longer exists,
+// e.g. a deleted workspace or the socket of an ended SSH agent session.
+type BindSourcesCheck struct{}
+
+// Name implements HealthCheck.
+func (BindSourcesCheck) Name() string { return "bind-sources" }
+
+// Check implements HealthCheck.
+func (BindSourcesCheck) Check(_ context.Context, target HealthTarget) error {
+	for _, bind := range target.Key.allBinds() {
+		source, _, _ := strings.Cut(bind, ":")
+		if !strings.HasPrefix(source, "/") {
+			// Named volume.
+			continue
+		}
+		if _, err := os.Stat(source); err != nil {
+			return fmt.Errorf("bind source %s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// ImageIDCheck fails when the key's image tag now points at a different
+// image than the container was created from (e.g. after tuprwre update).
+type ImageIDCheck struct{}
+
+// Name implements HealthCheck.
+func (ImageIDCheck) Name() string { return "image-id" }
+
+// Check implements HealthCheck.
+func (ImageIDCheck) Check(ctx context.Context, target HealthTarget) error {
+	if target.Key.ImageID != "" {
+		// The key was resolved for this acquire; no need to ask Docker again.
+		if target.Inspect.Image != target.Key.ImageID {
+			return fmt.Errorf("image %s now resolves to %s", target.Key.Image, shortID(target.Key.ImageID))
+		}
+		return nil
+	}
+
+	img, err := target.Client.ImageInspect(ctx, target.Key.Image)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image %s: %w", target.Key.Image, err)
+	}
+	if target.Inspect.Image != img.ID {
+		return fmt.Errorf("image %s now resolves to %s", target.Key.Image, shortID(img.ID))
+	}
+	return nil
+}
+
+// livenessScript checks that exec works and /tmp is still writable.
+const livenessScript = `p=/tmp/.tuprwre-probe.$$; : > "$p" && rm -f "$p"`
+
+// LivenessCheck runs a cheap exec in containers idle for longer than
+// IdleThreshold. Images without /bin/sh are treated as healthy.
+type LivenessCheck struct {
+	IdleThreshold time.Duration
+	Timeout       time.Duration
+}
+
+// Name implements HealthCheck.
+func (LivenessCheck) Name() string { return "liveness" }
+
+// Check implements HealthCheck.
+func (c LivenessCheck) Check(ctx context.Context, target HealthTarget) error {
+	if !target.Meta.LastUsed.IsZero() && time.Since(target.Meta.LastUsed) < c.IdleThreshold {
+		return nil
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	execResp, err := target.Client.ContainerExecCreate(ctx, target.Inspect.ID, container.ExecOptions{
+		Cmd:          []string{"/bin/sh", "-c", livenessScript},
+		User:         target.Key.User,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create probe exec: %w", err)
+	}
+	attachResp, err := target.Client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach probe exec: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, attachResp.Reader)
+	attachResp.Close()
+
+	inspect, err := target.Client.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect probe exec: %w", err)
+	}
+	switch inspect.ExitCode {
+	case 0, 126, 127:
+		return nil
+	default:
+		return fmt.Errorf("probe exited %d", inspect.ExitCode)
+	}
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,61 @@
+package pool
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecycleCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   RecycleCheck
+		meta    ContainerMeta
+		wantErr bool
+	}{
+		{name: "no limits", check: RecycleCheck{}, meta: ContainerMeta{Uses: 1000, CreatedAt: time.Now().Add(-48 * time.Hour)}},
+		{name: "under max uses", check: RecycleCheck{MaxUses: 3}, meta: ContainerMeta{Uses: 2}},
+		{name: "max uses reached", check: RecycleCheck{MaxUses: 3}, meta: ContainerMeta{Uses: 3}, wantErr: true},
+		{name: "young", check: RecycleCheck{MaxAge: time.Hour}, meta: ContainerMeta{CreatedAt: time.Now().Add(-time.Minute)}},
+		{name: "too old", check: RecycleCheck{MaxAge: time.Hour}, meta: ContainerMeta{CreatedAt: time.Now().Add(-2 * time.Hour)}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.check.Check(context.Background(), HealthTarget{Meta: tc.meta})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBindSourcesCheck(t *testing.T) {
+	existing := t.TempDir()
+	missing := filepath.Join(existing, "deleted-workspace")
+
+	ok := HealthTarget{Key: PoolKey{Binds: []string{existing + ":" + existing, "cache-volume:/cache"}}}
+	if err := (BindSourcesCheck{}).Check(context.Background(), ok); err != nil {
+		t.Fatalf("Check() with existing sources error = %v", err)
+	}
+
+	bad := HealthTarget{Key: PoolKey{Binds: []string{missing + ":" + missing + ":ro"}}}
+	if err := (BindSourcesCheck{}).Check(context.Background(), bad); err == nil {
+		t.Fatal("Check() with missing source error = nil, want error")
+	}
+
+	ended := HealthTarget{Key: PoolKey{Binds: []string{existing + ":" + existing}, Forward: []string{missing + ":/run/tuprwre/ssh-agent.sock"}}}
+	if err := (BindSourcesCheck{}).Check(context.Background(), ended); err == nil {
+		t.Fatal("Check() with missing forwarded source error = nil, want error")
+	}
+}
+
+func TestLivenessCheck_SkipsRecentlyUsed(t *testing.T) {
+	// A nil client would panic if the probe ran.
+	check := LivenessCheck{IdleThreshold: time.Minute}
+	target := HealthTarget{Meta: ContainerMeta{LastUsed: time.Now()}}
+	if err := check.Check(context.Background(), target); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+}
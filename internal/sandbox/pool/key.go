@@ -7,17 +7,31 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
 )
 
 // PoolKey identifies when a warm container can be reused.
 type PoolKey struct {
-	Image     string
+	Image string
+	// ImageID is the image ID Image resolved to, so re-committing a tag
+	// (tuprwre update) yields a new key instead of reusing stale containers.
+	ImageID   string
 	NoNetwork bool
 	Memory    int64
 	CPUs      float64
 	User      string
 	Binds     []string
-	Runtime   string
+	// Forward are the binds of forwarded host credentials (SSH agent
+	// socket, config files), kept apart so they never mix with workspace
+	// mounts.
+	Forward []string
+	// State is the host directory mounted as the run's home, if any.
+	State   string
+	Runtime string
+	// Hardening is applied to the warm container when it is created.
+	Hardening hardening.Profile
 }
 
 // CanonicalizePath resolves symlinks (when possible) and normalizes the path.
@@ -35,25 +49,38 @@ func (k PoolKey) Hash() string {
 		binds = append(binds, canonicalizeBind(bind))
 	}
 	sort.Strings(binds)
+	forward := make([]string, 0, len(k.Forward))
+	for _, bind := range k.Forward {
+		forward = append(forward, canonicalizeBind(bind))
+	}
+	sort.Strings(forward)
 
 	type canonicalKey struct {
 		Image     string   `json:"image"`
+		ImageID   string   `json:"image_id,omitempty"`
 		NoNetwork bool     `json:"no_network"`
 		Memory    int64    `json:"memory"`
 		CPUs      float64  `json:"cpus"`
 		User      string   `json:"user"`
 		Binds     []string `json:"binds"`
+		Forward   []string `json:"forward,omitempty"`
+		State     string   `json:"state,omitempty"`
 		Runtime   string   `json:"runtime"`
+		Hardening string   `json:"hardening,omitempty"`
 	}
 
 	payload, err := json.Marshal(canonicalKey{
 		Image:     k.Image,
+		ImageID:   k.ImageID,
 		NoNetwork: k.NoNetwork,
 		Memory:    k.Memory,
 		CPUs:      k.CPUs,
 		User:      k.User,
 		Binds:     binds,
+		Forward:   forward,
+		State:     k.State,
 		Runtime:   k.Runtime,
+		Hardening: k.Hardening.Fingerprint(),
 	})
 	if err != nil {
 		payload = []byte("{}")
@@ -73,10 +100,26 @@ func canonicalizeBind(bind string) string {
 
 // Labels returns Docker labels used to identify pooled warm containers.
 func (k PoolKey) Labels() map[string]string {
-	return map[string]string{
+	labels := map[string]string{
 		"tuprwre.pool":         "true",
 		"tuprwre.pool.key":     k.Hash(),
 		"tuprwre.pool.image":   k.Image,
 		"tuprwre.pool.version": "1",
 	}
+	if k.ImageID != "" {
+		labels["tuprwre.pool.image_id"] = k.ImageID
+	}
+	if k.State != "" {
+		labels["tuprwre.pool.state"] = k.State
+	}
+	return labels
+}
+
+// allBinds returns every bind mount a container for k gets.
+func (k PoolKey) allBinds() []string {
+	binds := append([]string{}, k.Binds...)
+	if k.State != "" {
+		binds = append(binds, k.State+":"+state.Home)
+	}
+	return append(binds, k.Forward...)
 }
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
 )
 
 func TestPoolKeyHash_Deterministic(t *testing.T) {
@@ -134,3 +136,62 @@ func TestCanonicalizePath_FallsBackToCleanForBrokenSymlink(t *testing.T) {
 		t.Fatalf("CanonicalizePath(%q) = %q, want %q", link, got, want)
 	}
 }
+
+func TestPoolKeyHash_DifferentImageIDDifferentHash(t *testing.T) {
+	before := PoolKey{Image: "tuprwre-tool:latest", ImageID: "sha256:aaa", Runtime: "docker"}
+	after := PoolKey{Image: "tuprwre-tool:latest", ImageID: "sha256:bbb", Runtime: "docker"}
+
+	if before.Hash() == after.Hash() {
+		t.Fatalf("expected different hash after the image tag is re-committed")
+	}
+}
+
+func TestPoolKeyLabels_IncludeImageID(t *testing.T) {
+	labels := PoolKey{Image: "tuprwre-tool:latest", ImageID: "sha256:aaa"}.Labels()
+	if labels["tuprwre.pool.image_id"] != "sha256:aaa" {
+		t.Fatalf("tuprwre.pool.image_id = %q, want %q", labels["tuprwre.pool.image_id"], "sha256:aaa")
+	}
+
+	if _, ok := (PoolKey{Image: "alpine:3.19"}).Labels()["tuprwre.pool.image_id"]; ok {
+		t.Fatalf("expected no image_id label when the image ID is unknown")
+	}
+}
+
+func TestPoolKeyHash_DifferentHardeningDifferentHash(t *testing.T) {
+	base := PoolKey{Image: "alpine:3.19", Runtime: "docker"}
+	hardened := PoolKey{Image: "alpine:3.19", Runtime: "docker", Hardening: hardening.Profile{Name: "strict", CapDrop: []string{"ALL"}}}
+
+	if base.Hash() == hardened.Hash() {
+		t.Fatalf("expected different hash for different hardening profiles")
+	}
+}
+
+func TestPoolKeyHash_DifferentForwardDifferentHash(t *testing.T) {
+	base := PoolKey{Image: "alpine:3.19", Runtime: "docker", Binds: []string{"/work:/work"}}
+	forwarded := base
+	forwarded.Forward = []string{"/tmp/ssh-abc/agent.1:/run/tuprwre/ssh-agent.sock"}
+	mixed := PoolKey{Image: "alpine:3.19", Runtime: "docker", Binds: []string{"/work:/work", "/tmp/ssh-abc/agent.1:/run/tuprwre/ssh-agent.sock"}}
+
+	if base.Hash() == forwarded.Hash() {
+		t.Fatalf("expected different hash when credentials are forwarded")
+	}
+	if forwarded.Hash() == mixed.Hash() {
+		t.Fatalf("expected forwarded binds to hash apart from workspace binds")
+	}
+}
+
+func TestPoolKeyHash_DifferentStateDifferentHash(t *testing.T) {
+	base := PoolKey{Image: "alpine:3.19", Runtime: "docker"}
+	withState := base
+	withState.State = "/home/u/.tuprwre/state/npm"
+
+	if base.Hash() == withState.Hash() {
+		t.Fatalf("expected different hash when a state directory is mounted")
+	}
+	if got := withState.Labels()["tuprwre.pool.state"]; got != withState.State {
+		t.Fatalf("state label = %q, want %q", got, withState.State)
+	}
+	if _, ok := base.Labels()["tuprwre.pool.state"]; ok {
+		t.Fatalf("unexpected state label without a state directory")
+	}
+}
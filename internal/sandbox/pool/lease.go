@@ -10,14 +10,29 @@ import (
 	"time"
 )
 
-// Lease is an exclusive lock on a warm container.
+// Lease is one exec slot on a warm container. It holds a shared lock on the
+// container (so eviction and GC, which need the exclusive lock, leave it
+// alone) plus an exclusive lock on one of the container's exec slots.
 type Lease struct {
 	ContainerID string
+	Info        AcquireInfo
 	lockFile    *os.File
+	slotFile    *os.File
 	metaPath    string
 	unhealthy   bool
 }
 
+// AcquireInfo describes how Acquire obtained a lease.
+type AcquireInfo struct {
+	// Reused is true when an existing warm container was leased rather
+	// than a new one created.
+	Reused bool
+	// Evicted counts containers evicted or replaced along the way.
+	Evicted int
+	// LookedUpAt is when the containers matching the key were listed.
+	LookedUpAt time.Time
+}
+
 // ContainerMeta stores per-container pool metadata.
 type ContainerMeta struct {
 	KeyHash       string    `json:"key_hash"`
@@ -25,6 +40,7 @@ type ContainerMeta struct {
 	CreatedAt     time.Time `json:"created_at"`
 	LastUsed      time.Time `json:"last_used"`
 	WorkspaceRoot string    `json:"workspace_root"`
+	Uses          int       `json:"uses,omitempty"`
 }
 
 // Release updates metadata last_used and releases the container flock.
@@ -34,12 +50,19 @@ func (l *Lease) Release() {
 	}
 
 	if l.metaPath != "" {
-		if meta, err := readContainerMeta(l.metaPath); err == nil {
+		_ = updateContainerMeta(l.metaPath, func(meta *ContainerMeta) {
 			meta.LastUsed = time.Now().UTC()
-			_ = writeContainerMeta(l.metaPath, meta)
-		}
+		})
 	}
 
+	l.unlock()
+}
+
+func (l *Lease) unlock() {
+	if l.slotFile != nil {
+		_ = l.slotFile.Close()
+		l.slotFile = nil
+	}
 	if l.lockFile != nil {
 		_ = l.lockFile.Close()
 		l.lockFile = nil
@@ -84,6 +107,28 @@ func writeContainerMeta(path string, meta ContainerMeta) error {
 	return nil
 }
 
+// updateContainerMeta applies update to the metadata at path under an
+// exclusive lock of its own, so concurrent leases of one container do not
+// lose each other's changes. The container lock cannot serve: leases hold
+// it shared.
+func updateContainerMeta(path string, update func(*ContainerMeta)) error {
+	lock, err := os.OpenFile(metaLockPath(path), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open container metadata lock: %w", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock container metadata: %w", err)
+	}
+
+	meta, err := readContainerMeta(path)
+	if err != nil {
+		return err
+	}
+	update(&meta)
+	return writeContainerMeta(path, meta)
+}
+
 func lockPath(poolDir, containerID string) string {
 	return filepath.Join(poolDir, containerID+".lock")
 }
@@ -92,18 +137,78 @@ func metaPath(poolDir, containerID string) string {
 	return filepath.Join(poolDir, containerID+".json")
 }
 
+func metaLockPath(metaPath string) string {
+	return metaPath + ".lock"
+}
+
+func slotLockPath(poolDir, containerID string, slot int) string {
+	return filepath.Join(poolDir, fmt.Sprintf("%s.slot.%d.lock", containerID, slot))
+}
+
+// tryLock takes the exclusive container lock used by eviction, GC and drain.
+// It fails with ErrLocked while any exec holds a lease.
 func tryLock(poolDir, containerID string) (*os.File, error) {
+	return flockFile(poolDir, lockPath(poolDir, containerID), syscall.LOCK_EX)
+}
+
+// tryLease takes a shared container lock plus a free exec slot out of
+// maxExecs. It fails with ErrLocked when the container is being evicted or
+// all slots are busy.
+func tryLease(poolDir, containerID string, maxExecs int) (*Lease, error) {
+	lockFile, err := flockFile(poolDir, lockPath(poolDir, containerID), syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+
+	for slot := 0; slot < maxExecs; slot++ {
+		slotFile, err := flockFile(poolDir, slotLockPath(poolDir, containerID, slot), syscall.LOCK_EX)
+		if err == nil {
+			return &Lease{
+				ContainerID: containerID,
+				lockFile:    lockFile,
+				slotFile:    slotFile,
+				metaPath:    metaPath(poolDir, containerID),
+			}, nil
+		}
+		if !errors.Is(err, ErrLocked) {
+			_ = lockFile.Close()
+			return nil, err
+		}
+	}
+
+	_ = lockFile.Close()
+	return nil, ErrLocked
+}
+
+// activeExecs counts the busy exec slots of a container.
+func activeExecs(poolDir, containerID string, maxExecs int) int {
+	active := 0
+	for slot := 0; slot < maxExecs; slot++ {
+		path := slotLockPath(poolDir, containerID, slot)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		f, err := flockFile(poolDir, path, syscall.LOCK_EX)
+		if errors.Is(err, ErrLocked) {
+			active++
+		} else if err == nil {
+			_ = f.Close()
+		}
+	}
+	return active
+}
+
+func flockFile(poolDir, path string, how int) (*os.File, error) {
 	if err := os.MkdirAll(poolDir, 0o755); err != nil {
 		return nil, fmt.Errorf("failed to create pool directory: %w", err)
 	}
 
-	path := lockPath(poolDir, containerID)
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open lock file: %w", err)
 	}
 
-	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
 	if err == nil {
 		return f, nil
 	}
@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestTryLease_SharesContainerUpToMaxExecs(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := tryLease(dir, "c1", 2)
+	if err != nil {
+		t.Fatalf("first tryLease() error = %v", err)
+	}
+	second, err := tryLease(dir, "c1", 2)
+	if err != nil {
+		t.Fatalf("second tryLease() error = %v", err)
+	}
+	if _, err := tryLease(dir, "c1", 2); !errors.Is(err, ErrLocked) {
+		t.Fatalf("third tryLease() error = %v, want ErrLocked", err)
+	}
+	if got := activeExecs(dir, "c1", 2); got != 2 {
+		t.Fatalf("activeExecs() = %d, want 2", got)
+	}
+
+	first.Release()
+	if got := activeExecs(dir, "c1", 2); got != 1 {
+		t.Fatalf("activeExecs() after release = %d, want 1", got)
+	}
+	third, err := tryLease(dir, "c1", 2)
+	if err != nil {
+		t.Fatalf("tryLease() after release error = %v", err)
+	}
+
+	second.Release()
+	third.Release()
+	if got := activeExecs(dir, "c1", 2); got != 0 {
+		t.Fatalf("activeExecs() after all releases = %d, want 0", got)
+	}
+}
+
+func TestTryLock_ExclusiveExcludesLeases(t *testing.T) {
+	dir := t.TempDir()
+
+	lease, err := tryLease(dir, "c1", 4)
+	if err != nil {
+		t.Fatalf("tryLease() error = %v", err)
+	}
+	if _, err := tryLock(dir, "c1"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("tryLock() with active lease error = %v, want ErrLocked", err)
+	}
+	lease.Release()
+
+	lockFile, err := tryLock(dir, "c1")
+	if err != nil {
+		t.Fatalf("tryLock() after release error = %v", err)
+	}
+	defer lockFile.Close()
+	if _, err := tryLease(dir, "c1", 4); !errors.Is(err, ErrLocked) {
+		t.Fatalf("tryLease() during exclusive lock error = %v, want ErrLocked", err)
+	}
+}
+
+func TestUpdateContainerMeta_ConcurrentUpdatesAreKept(t *testing.T) {
+	path := metaPath(t.TempDir(), "c1")
+	if err := writeContainerMeta(path, ContainerMeta{Image: "img"}); err != nil {
+		t.Fatalf("writeContainerMeta() error = %v", err)
+	}
+
+	const leases = 20
+	var wg sync.WaitGroup
+	for i := 0; i < leases; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := updateContainerMeta(path, func(meta *ContainerMeta) { meta.Uses++ }); err != nil {
+				t.Errorf("updateContainerMeta() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	meta, err := readContainerMeta(path)
+	if err != nil {
+		t.Fatalf("readContainerMeta() error = %v", err)
+	}
+	if meta.Uses != leases {
+		t.Fatalf("Uses = %d, want %d", meta.Uses, leases)
+	}
+}
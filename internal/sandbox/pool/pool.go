@@ -20,6 +20,25 @@ type PoolConfig struct {
 	MaxPerKey int
 	MaxTotal  int
 	TTL       time.Duration
+
+	// MaxExecsPerContainer is how many leases one container serves at once.
+	MaxExecsPerContainer int
+
+	// MaxUses and MaxAge recycle containers after that many leases or that
+	// much time since creation. Zero means no limit.
+	MaxUses int
+	MaxAge  time.Duration
+
+	// ProbeAfter is how long a container must be idle before the liveness
+	// probe runs on acquire.
+	ProbeAfter time.Duration
+
+	// HealthChecks run before a container is leased. Nil means
+	// DefaultHealthChecks.
+	HealthChecks []HealthCheck
+
+	// Logf receives health check failures. Nil discards them.
+	Logf func(format string, args ...any)
 }
 
 // WarmPool manages warm sandbox containers.
@@ -31,7 +50,8 @@ type WarmPool struct {
 // ErrPoolExhausted indicates no warm container can be leased or created.
 var ErrPoolExhausted = errors.New("pool exhausted")
 
-// ErrLocked indicates a warm container is already leased.
+// ErrLocked indicates a warm container has no free exec slot or is being
+// evicted.
 var ErrLocked = errors.New("container is locked")
 
 // ContainerStatus describes one pooled container for status reporting.
@@ -41,6 +61,7 @@ type ContainerStatus struct {
 	KeyHash       string
 	State         string
 	Locked        bool
+	ActiveExecs   int
 	CreatedAt     time.Time
 	LastUsed      time.Time
 	WorkspaceRoot string
@@ -61,6 +82,15 @@ func NewWarmPool(cli *client.Client, cfg PoolConfig) *WarmPool {
 	if cfg.TTL <= 0 {
 		cfg.TTL = 10 * time.Minute
 	}
+	if cfg.MaxExecsPerContainer <= 0 {
+		cfg.MaxExecsPerContainer = 1
+	}
+	if cfg.HealthChecks == nil {
+		cfg.HealthChecks = DefaultHealthChecks(cfg)
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
 	if cfg.PoolDir == "" {
 		cfg.PoolDir = filepath.Join(os.TempDir(), "tuprwre", "containers", "pool")
 	}
@@ -73,20 +103,24 @@ func NewWarmPool(cli *client.Client, cfg PoolConfig) *WarmPool {
 	}
 }
 
-// Acquire acquires an exclusive lease for a warm container matching key.
+// Acquire leases an exec slot on a warm container matching key, creating a
+// container when every existing one is busy.
 func (p *WarmPool) Acquire(ctx context.Context, key PoolKey) (*Lease, error) {
 	if p == nil || p.client == nil {
 		return nil, fmt.Errorf("warm pool is not initialized")
 	}
 
 	keyHash := key.Hash()
+	var info AcquireInfo
 
-	if lease, containers, err := p.findAvailableLease(ctx, keyHash); err != nil {
+	if lease, containers, err := p.findAvailableLease(ctx, key, &info); err != nil {
 		return nil, err
 	} else if lease != nil {
+		info.Reused = true
+		lease.Info = info
 		return lease, nil
 	} else if len(containers) < p.cfg.MaxPerKey {
-		return p.createAndLease(ctx, key, keyHash)
+		return p.createAndLease(ctx, key, keyHash, info)
 	}
 
 	total, err := p.totalPoolContainers(ctx)
@@ -108,6 +142,7 @@ func (p *WarmPool) Acquire(ctx context.Context, key PoolKey) (*Lease, error) {
 			if total >= before {
 				break
 			}
+			info.Evicted += before - total
 		}
 	}
 
@@ -123,7 +158,18 @@ func (p *WarmPool) Acquire(ctx context.Context, key PoolKey) (*Lease, error) {
 		return nil, ErrPoolExhausted
 	}
 
-	return p.createAndLease(ctx, key, keyHash)
+	return p.createAndLease(ctx, key, keyHash, info)
+}
+
+// Warm ensures an idle warm container exists for key by acquiring and
+// immediately releasing a lease.
+func (p *WarmPool) Warm(ctx context.Context, key PoolKey) error {
+	lease, err := p.Acquire(ctx, key)
+	if err != nil {
+		return err
+	}
+	p.Release(ctx, lease)
+	return nil
 }
 
 // Release releases a previously acquired lease.
@@ -134,10 +180,12 @@ func (p *WarmPool) Release(ctx context.Context, lease *Lease) {
 	}
 
 	if lease.unhealthy {
-		_ = p.removeContainerAndArtifacts(ctx, lease.ContainerID)
-		if lease.lockFile != nil {
-			_ = lease.lockFile.Close()
-			lease.lockFile = nil
+		// Other execs may still be running in the container; only remove it
+		// once nobody else holds a lease. GC catches it otherwise.
+		lease.unlock()
+		if lockFile, err := tryLock(p.cfg.PoolDir, lease.ContainerID); err == nil {
+			_ = p.removeContainerAndArtifacts(ctx, lease.ContainerID)
+			_ = lockFile.Close()
 		}
 		return
 	}
@@ -145,18 +193,30 @@ func (p *WarmPool) Release(ctx context.Context, lease *Lease) {
 	lease.Release()
 }
 
-func (p *WarmPool) findAvailableLease(ctx context.Context, keyHash string) (*Lease, []container.Summary, error) {
-	containers, err := p.containersByKey(ctx, keyHash)
+// checkHealth runs the configured health checks, returning the first failure.
+func (p *WarmPool) checkHealth(ctx context.Context, key PoolKey, inspect container.InspectResponse, meta ContainerMeta) error {
+	target := HealthTarget{Client: p.client, Key: key, Inspect: inspect, Meta: meta}
+	for _, check := range p.cfg.HealthChecks {
+		if err := check.Check(ctx, target); err != nil {
+			return fmt.Errorf("%s check failed: %w", check.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (p *WarmPool) findAvailableLease(ctx context.Context, key PoolKey, info *AcquireInfo) (*Lease, []container.Summary, error) {
+	containers, err := p.containersByKey(ctx, key.Hash())
 	if err != nil {
 		return nil, nil, err
 	}
+	info.LookedUpAt = time.Now()
 
 	for _, c := range containers {
 		if c.State != "running" {
 			continue
 		}
 
-		lockFile, err := tryLock(p.cfg.PoolDir, c.ID)
+		lease, err := tryLease(p.cfg.PoolDir, c.ID, p.cfg.MaxExecsPerContainer)
 		if err != nil {
 			if errors.Is(err, ErrLocked) {
 				continue
@@ -166,28 +226,39 @@ func (p *WarmPool) findAvailableLease(ctx context.Context, keyHash string) (*Lea
 
 		inspect, err := p.client.ContainerInspect(ctx, c.ID)
 		if err != nil || inspect.State == nil || !inspect.State.Running {
-			_ = lockFile.Close()
-			_ = p.removeContainerAndArtifacts(context.Background(), c.ID)
+			lease.MarkUnhealthy()
+			p.Release(context.Background(), lease)
 			continue
 		}
 
-		return &Lease{
-			ContainerID: c.ID,
-			lockFile:    lockFile,
-			metaPath:    metaPath(p.cfg.PoolDir, c.ID),
-		}, containers, nil
+		meta, err := readContainerMeta(lease.metaPath)
+		if err != nil {
+			lease.MarkUnhealthy()
+			p.Release(context.Background(), lease)
+			continue
+		}
+		if err := p.checkHealth(ctx, key, inspect, meta); err != nil {
+			p.cfg.Logf("replacing warm container %s: %v", shortID(c.ID), err)
+			lease.MarkUnhealthy()
+			p.Release(context.Background(), lease)
+			info.Evicted++
+			continue
+		}
+
+		_ = updateContainerMeta(lease.metaPath, func(meta *ContainerMeta) { meta.Uses++ })
+		return lease, containers, nil
 	}
 
 	return nil, containers, nil
 }
 
-func (p *WarmPool) createAndLease(ctx context.Context, key PoolKey, keyHash string) (*Lease, error) {
+func (p *WarmPool) createAndLease(ctx context.Context, key PoolKey, keyHash string, info AcquireInfo) (*Lease, error) {
 	id, err := p.createWarmContainer(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
-	lockFile, err := tryLock(p.cfg.PoolDir, id)
+	lease, err := tryLease(p.cfg.PoolDir, id, p.cfg.MaxExecsPerContainer)
 	if err != nil {
 		_ = p.removeContainerAndArtifacts(context.Background(), id)
 		if errors.Is(err, ErrLocked) {
@@ -203,24 +274,28 @@ func (p *WarmPool) createAndLease(ctx context.Context, key PoolKey, keyHash stri
 		CreatedAt:     now,
 		LastUsed:      now,
 		WorkspaceRoot: workspaceRootFromBinds(key.Binds),
+		Uses:          1,
 	}
 	if err := writeContainerMeta(metaPath(p.cfg.PoolDir, id), meta); err != nil {
-		_ = lockFile.Close()
+		lease.unlock()
 		_ = p.removeContainerAndArtifacts(context.Background(), id)
 		return nil, err
 	}
 
-	return &Lease{
-		ContainerID: id,
-		lockFile:    lockFile,
-		metaPath:    metaPath(p.cfg.PoolDir, id),
-	}, nil
+	lease.Info = info
+	return lease, nil
 }
 
-// createWarmContainer creates and starts one warm container for key.
+// createWarmContainer creates and starts one warm container for key. The
+// container is created from key.ImageID when known so it matches the image
+// the key was resolved against even if the tag moves.
 func (p *WarmPool) createWarmContainer(ctx context.Context, key PoolKey) (string, error) {
+	image := key.Image
+	if key.ImageID != "" {
+		image = key.ImageID
+	}
 	containerConfig := &container.Config{
-		Image:           key.Image,
+		Image:           image,
 		Cmd:             []string{"sleep", "infinity"},
 		Tty:             false,
 		NetworkDisabled: key.NoNetwork,
@@ -230,11 +305,9 @@ func (p *WarmPool) createWarmContainer(ctx context.Context, key PoolKey) (string
 
 	hostConfig := &container.HostConfig{
 		ReadonlyRootfs: true,
-		Tmpfs: map[string]string{
-			"/tmp": "size=64m,noexec",
-		},
-		Binds: key.Binds,
+		Binds:          key.allBinds(),
 	}
+	key.Hardening.Apply(hostConfig)
 
 	if key.Memory > 0 {
 		hostConfig.Resources.Memory = key.Memory
@@ -356,6 +429,95 @@ func (p *WarmPool) GC(ctx context.Context) (int, error) {
 	return removed, nil
 }
 
+// Drain removes pooled containers. Leased containers are skipped unless force
+// is set, in which case they are removed from under their current run.
+func (p *WarmPool) Drain(ctx context.Context, force bool) (int, error) {
+	all, err := p.allPoolContainers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, c := range all {
+		lockFile, err := tryLock(p.cfg.PoolDir, c.ID)
+		if err != nil && !force {
+			continue
+		}
+		if err := p.removeContainerAndArtifacts(ctx, c.ID); err == nil {
+			removed++
+		}
+		if lockFile != nil {
+			_ = lockFile.Close()
+		}
+	}
+
+	return removed, nil
+}
+
+// EvictImages removes pooled containers created from any of refs, matched
+// against the image name in the pool labels and the container's image ID.
+// Leased containers are skipped unless force is set.
+func (p *WarmPool) EvictImages(ctx context.Context, refs []string, force bool) (int, error) {
+	if len(refs) == 0 {
+		return 0, nil
+	}
+	match := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		match[ref] = true
+	}
+
+	all, err := p.allPoolContainers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, c := range all {
+		if !match[c.Labels["tuprwre.pool.image"]] && !match[c.Labels["tuprwre.pool.image_id"]] && !match[c.ImageID] {
+			continue
+		}
+		lockFile, err := tryLock(p.cfg.PoolDir, c.ID)
+		if err != nil && !force {
+			continue
+		}
+		if err := p.removeContainerAndArtifacts(ctx, c.ID); err == nil {
+			removed++
+		}
+		if lockFile != nil {
+			_ = lockFile.Close()
+		}
+	}
+
+	return removed, nil
+}
+
+// EvictState removes pooled containers that mount the state directory dir.
+// Leased containers are skipped unless force is set.
+func (p *WarmPool) EvictState(ctx context.Context, dir string, force bool) (int, error) {
+	all, err := p.allPoolContainers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, c := range all {
+		if c.Labels["tuprwre.pool.state"] != dir {
+			continue
+		}
+		lockFile, err := tryLock(p.cfg.PoolDir, c.ID)
+		if err != nil && !force {
+			continue
+		}
+		if err := p.removeContainerAndArtifacts(ctx, c.ID); err == nil {
+			removed++
+		}
+		if lockFile != nil {
+			_ = lockFile.Close()
+		}
+	}
+	return removed, nil
+}
+
 // Status returns warm pool container status entries.
 func (p *WarmPool) Status(ctx context.Context) ([]ContainerStatus, error) {
 	all, err := p.allPoolContainers(ctx)
@@ -384,15 +546,8 @@ func (p *WarmPool) Status(ctx context.Context) ([]ContainerStatus, error) {
 			}
 		}
 
-		lockFile, err := tryLock(p.cfg.PoolDir, c.ID)
-		if err != nil {
-			if errors.Is(err, ErrLocked) {
-				st.Locked = true
-			}
-		} else {
-			st.Locked = false
-			_ = lockFile.Close()
-		}
+		st.ActiveExecs = activeExecs(p.cfg.PoolDir, c.ID, p.cfg.MaxExecsPerContainer)
+		st.Locked = st.ActiveExecs > 0
 
 		out = append(out, st)
 	}
@@ -434,6 +589,12 @@ func (p *WarmPool) removeContainerAndArtifacts(ctx context.Context, containerID
 	_ = p.client.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true, RemoveVolumes: true})
 	_ = os.Remove(lockPath(p.cfg.PoolDir, containerID))
 	_ = os.Remove(metaPath(p.cfg.PoolDir, containerID))
+	_ = os.Remove(metaLockPath(metaPath(p.cfg.PoolDir, containerID)))
+	if slots, err := filepath.Glob(filepath.Join(p.cfg.PoolDir, containerID+".slot.*.lock")); err == nil {
+		for _, slot := range slots {
+			_ = os.Remove(slot)
+		}
+	}
 	return nil
 }
 
@@ -304,3 +304,90 @@ func TestWarmPool_LeaseReleaseCleansUp(t *testing.T) {
 		t.Fatalf("expected unhealthy lease release to remove container %s", id)
 	}
 }
+
+func TestWarmPool_DrainSkipsLeasedUnlessForced(t *testing.T) {
+	cli := requireDockerClient(t)
+	requireImageAvailable(t, cli, testImage)
+	requireImageAvailable(t, cli, testImageAlt)
+	cleanupPoolContainers(t, cli)
+	t.Cleanup(func() { cleanupPoolContainers(t, cli) })
+
+	wp := NewWarmPool(cli, PoolConfig{PoolDir: t.TempDir(), MaxTotal: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	if err := wp.Warm(ctx, testKey(testImage)); err != nil {
+		t.Fatalf("Warm() failed: %v", err)
+	}
+	leased, err := wp.Acquire(ctx, testKey(testImageAlt))
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	defer leased.Release()
+
+	removed, err := wp.Drain(ctx, false)
+	if err != nil {
+		t.Fatalf("Drain() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Drain() removed = %d, want 1", removed)
+	}
+	if !containerExists(ctx, cli, leased.ContainerID) {
+		t.Fatalf("expected leased container %s to survive non-forced drain", leased.ContainerID)
+	}
+
+	removed, err = wp.Drain(ctx, true)
+	if err != nil {
+		t.Fatalf("Drain(force) failed: %v", err)
+	}
+	if removed != 1 || containerExists(ctx, cli, leased.ContainerID) {
+		t.Fatalf("Drain(force) removed = %d, want leased container removed", removed)
+	}
+}
+
+type rejectOnce struct{ rejected bool }
+
+func (r *rejectOnce) Name() string { return "reject-once" }
+
+func (r *rejectOnce) Check(context.Context, HealthTarget) error {
+	if r.rejected {
+		return nil
+	}
+	r.rejected = true
+	return errors.New("rejected")
+}
+
+func TestWarmPool_ReplacesUnhealthyContainer(t *testing.T) {
+	cli := requireDockerClient(t)
+	requireImageAvailable(t, cli, testImage)
+	cleanupPoolContainers(t, cli)
+	t.Cleanup(func() { cleanupPoolContainers(t, cli) })
+
+	check := &rejectOnce{}
+	wp := NewWarmPool(cli, PoolConfig{
+		PoolDir:      t.TempDir(),
+		HealthChecks: append(DefaultHealthChecks(PoolConfig{}), check),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	first, err := wp.Acquire(ctx, testKey(testImage))
+	if err != nil {
+		t.Fatalf("first Acquire() failed: %v", err)
+	}
+	staleID := first.ContainerID
+	first.Release()
+
+	second, err := wp.Acquire(ctx, testKey(testImage))
+	if err != nil {
+		t.Fatalf("second Acquire() failed: %v", err)
+	}
+	defer second.Release()
+
+	if second.ContainerID == staleID {
+		t.Fatalf("expected unhealthy container %s to be replaced", staleID)
+	}
+	if containerExists(ctx, cli, staleID) {
+		t.Fatalf("expected unhealthy container %s to be removed", staleID)
+	}
+}
@@ -0,0 +1,181 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// LabelToolset carries the JSON toolset manifest on published images.
+const LabelToolset = "tuprwre.toolset"
+
+// RegistryImage is an image pulled by reference from a registry.
+type RegistryImage struct {
+	ID     string
+	Digest string // manifest digest the reference resolved to
+	Labels map[string]string
+}
+
+// registryAuth builds the X-Registry-Auth header from
+// TUPRWRE_REGISTRY_USERNAME and TUPRWRE_REGISTRY_PASSWORD. It returns ""
+// (anonymous) when they are unset.
+func registryAuth() (string, error) {
+	username := os.Getenv("TUPRWRE_REGISTRY_USERNAME")
+	if username == "" {
+		return "", nil
+	}
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username: username,
+		Password: os.Getenv("TUPRWRE_REGISTRY_PASSWORD"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode registry credentials: %w", err)
+	}
+	return auth, nil
+}
+
+// RelabelImage builds an image FROM source with labels added to its config
+// and tags it target. No filesystem layer is added.
+func (d *DockerRuntime) RelabelImage(ctx context.Context, source, target string, labels map[string]string) error {
+	if err := d.initClient(); err != nil {
+		return err
+	}
+
+	dockerfile := fmt.Sprintf("FROM %s\n", source)
+	var buildContext bytes.Buffer
+	tw := tar.NewWriter(&buildContext)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0o644, Size: int64(len(dockerfile))}); err != nil {
+		return fmt.Errorf("failed to prepare build context: %w", err)
+	}
+	if _, err := io.WriteString(tw, dockerfile); err != nil {
+		return fmt.Errorf("failed to prepare build context: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to prepare build context: %w", err)
+	}
+
+	resp, err := d.client.ImageBuild(ctx, &buildContext, build.ImageBuildOptions{
+		Tags:        []string{target},
+		Labels:      labels,
+		Remove:      true,
+		ForceRemove: true,
+		Version:     build.BuilderV1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to label image %s: %w", source, err)
+	}
+	defer resp.Body.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("failed to label image %s: %w", source, err)
+	}
+	return nil
+}
+
+// PushImage pushes ref to its registry and returns the manifest digest.
+// Progress is streamed to stdout.
+func (d *DockerRuntime) PushImage(ctx context.Context, ref string) (string, error) {
+	if err := d.initClient(); err != nil {
+		return "", err
+	}
+	auth, err := registryAuth()
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := d.client.ImagePush(ctx, ref, image.PushOptions{RegistryAuth: auth})
+	if err != nil {
+		return "", fmt.Errorf("failed to push %s: %w", ref, err)
+	}
+	defer reader.Close()
+
+	digest := ""
+	err = jsonmessage.DisplayJSONMessagesStream(reader, os.Stdout, 0, false, func(msg jsonmessage.JSONMessage) {
+		var result struct{ Digest string }
+		if msg.Aux != nil && json.Unmarshal(*msg.Aux, &result) == nil && result.Digest != "" {
+			digest = result.Digest
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to push %s: %w", ref, err)
+	}
+	if digest == "" {
+		return "", fmt.Errorf("registry did not report a digest for %s", ref)
+	}
+	return digest, nil
+}
+
+// PullRegistryImage pulls ref, even when a local copy exists, and returns
+// the pulled image with the digest ref resolved to. A digest-pinned ref must
+// resolve to exactly that digest.
+func (d *DockerRuntime) PullRegistryImage(ctx context.Context, ref string) (RegistryImage, error) {
+	if err := d.initClient(); err != nil {
+		return RegistryImage{}, err
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return RegistryImage{}, fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	auth, err := registryAuth()
+	if err != nil {
+		return RegistryImage{}, err
+	}
+
+	reader, err := d.client.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return RegistryImage{}, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	defer reader.Close()
+	if err := jsonmessage.DisplayJSONMessagesStream(reader, os.Stdout, 0, false, nil); err != nil {
+		return RegistryImage{}, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+
+	img, err := d.client.ImageInspect(ctx, ref)
+	if err != nil {
+		return RegistryImage{}, fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+
+	digest := repoDigest(named.Name(), img.RepoDigests)
+	if digest == "" {
+		return RegistryImage{}, fmt.Errorf("no registry digest recorded for %s", ref)
+	}
+	if canonical, ok := named.(reference.Canonical); ok && canonical.Digest().String() != digest {
+		return RegistryImage{}, fmt.Errorf("digest mismatch for %s: pulled %s", ref, digest)
+	}
+
+	var labels map[string]string
+	if img.Config != nil {
+		labels = img.Config.Labels
+	}
+	return RegistryImage{ID: img.ID, Digest: digest, Labels: labels}, nil
+}
+
+// repoDigest returns the digest recorded for repository name among
+// repoDigests ("repo@sha256:...").
+func repoDigest(name string, repoDigests []string) string {
+	for _, entry := range repoDigests {
+		repo, digest, ok := strings.Cut(entry, "@")
+		if !ok {
+			continue
+		}
+		named, err := reference.ParseNormalizedNamed(repo)
+		if err != nil {
+			continue
+		}
+		if named.Name() == name {
+			return digest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package sandbox
+
+import "testing"
+
+func TestRepoDigest(t *testing.T) {
+	repoDigests := []string{
+		"ubuntu@sha256:aaa",
+		"localhost:5000/tools/jq@sha256:bbb",
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "docker.io/library/ubuntu", want: "sha256:aaa"},
+		{name: "localhost:5000/tools/jq", want: "sha256:bbb"},
+		{name: "localhost:5000/tools/yq", want: ""},
+	}
+	for _, tt := range tests {
+		if got := repoDigest(tt.name, repoDigests); got != tt.want {
+			t.Errorf("repoDigest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
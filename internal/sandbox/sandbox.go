@@ -2,6 +2,7 @@
 package sandbox
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -16,7 +17,12 @@ import (
 	"time"
 
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/forward"
+	"github.com/c4rb0nx1/tuprwre/internal/hardening"
 	"github.com/c4rb0nx1/tuprwre/internal/sandbox/pool"
+	"github.com/c4rb0nx1/tuprwre/internal/state"
+	"github.com/c4rb0nx1/tuprwre/internal/stats"
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
@@ -29,6 +35,7 @@ type DockerRuntime struct {
 	config *config.Config
 	client *client.Client
 	pool   *pool.WarmPool
+	initMu sync.Mutex
 }
 
 type TuprwreImage struct {
@@ -47,26 +54,95 @@ type TuprwreContainer struct {
 }
 
 // RunOptions contains parameters for running a sandboxed command.
+// The JSON form (stdio excluded) is what `tuprwre run` sends to the daemon.
 type RunOptions struct {
-	Image       string
-	ContainerID string
-	Binary      string
-	Args        []string
-	WorkDir     string
-	Env         []string
-	Volumes     []string
-	Runtime     string
-	Stdin       io.Reader
-	Stdout      io.Writer
-	Stderr      io.Writer
-	DebugIO     bool
-	DebugIOJSON bool
-	CaptureFile string
-	ReadOnlyCwd bool
-	NoNetwork   bool
-	MemoryLimit int64   // bytes; 0 means no limit
-	CPULimit    float64 // number of CPUs; 0 means no limit
-	NoPool      bool
+	Image       string    `json:"image"`
+	ContainerID string    `json:"container_id,omitempty"`
+	Binary      string    `json:"binary"`
+	Args        []string  `json:"args,omitempty"`
+	WorkDir     string    `json:"workdir,omitempty"`
+	Env         []string  `json:"env,omitempty"`
+	Volumes     []string  `json:"volumes,omitempty"`
+	Runtime     string    `json:"runtime,omitempty"`
+	Stdin       io.Reader `json:"-"`
+	Stdout      io.Writer `json:"-"`
+	Stderr      io.Writer `json:"-"`
+	DebugIO     bool      `json:"debug_io,omitempty"`
+	DebugIOJSON bool      `json:"debug_io_json,omitempty"`
+	CaptureFile string    `json:"capture_file,omitempty"`
+	ReadOnlyCwd bool      `json:"read_only_cwd,omitempty"`
+	NoNetwork   bool      `json:"no_network,omitempty"`
+	MemoryLimit int64     `json:"memory_limit,omitempty"` // bytes; 0 means no limit
+	CPULimit    float64   `json:"cpu_limit,omitempty"`    // number of CPUs; 0 means no limit
+	NoPool      bool      `json:"no_pool,omitempty"`
+
+	// CaptureStdout and CaptureStderr receive one stream each, unlike
+	// CaptureFile which interleaves both.
+	CaptureStdout string `json:"capture_stdout,omitempty"`
+	CaptureStderr string `json:"capture_stderr,omitempty"`
+
+	// RecordDir, if set, receives the run's streams and a JSON record of
+	// it (see internal/record). RecordSnapshot names a host directory
+	// copied into the record before the run, up to RecordSnapshotMax bytes.
+	RecordDir         string `json:"record_dir,omitempty"`
+	RecordSnapshot    string `json:"record_snapshot,omitempty"`
+	RecordSnapshotMax int64  `json:"record_snapshot_max,omitempty"`
+
+	// InvokedAt and ConfigLoadedAt let diagnostics and stats account for
+	// time spent in `tuprwre run` before the runtime was reached.
+	InvokedAt      time.Time `json:"invoked_at,omitempty"`
+	ConfigLoadedAt time.Time `json:"config_loaded_at,omitempty"`
+
+	// TraceParent is the W3C trace context the run span is parented to,
+	// so runs executed by the daemon join the caller's trace.
+	TraceParent string `json:"traceparent,omitempty"`
+
+	// ExpectedImageID is the image ID Image must resolve to, taken from the
+	// shim's signed metadata. The run is refused when the tag has moved.
+	ExpectedImageID string `json:"expected_image_id,omitempty"`
+
+	// Hardening is the resolved profile applied to new containers, on both
+	// the cold path and the warm pool. Exec runs inherit their container's.
+	Hardening hardening.Profile `json:"hardening"`
+
+	// Forward holds the host credentials forwarded into the run, resolved
+	// by the caller: its binds are part of the pool key, its env is added
+	// to Env.
+	Forward forward.Mounts `json:"forward,omitempty"`
+
+	// StateDir is the shim's persistent home directory on the host, mounted
+	// at state.Home with HOME pointing there. Empty runs without one.
+	StateDir string `json:"state_dir,omitempty"`
+
+	// PathMounts are read-only binds of host directories that arguments
+	// name outside the workspace (see internal/argpath). Runs with any take
+	// the cold path, so one-off mounts never leave warm containers behind.
+	PathMounts []string `json:"path_mounts,omitempty"`
+}
+
+// env returns the run's environment including forwarded credentials and
+// HOME. HOME comes first so an explicit --env HOME wins.
+func (o RunOptions) env() []string {
+	if len(o.Forward.Env) == 0 && o.StateDir == "" {
+		return o.Env
+	}
+	var env []string
+	if o.StateDir != "" {
+		env = append(env, "HOME="+state.Home)
+	}
+	env = append(env, o.Env...)
+	return append(env, o.Forward.Env...)
+}
+
+// binds returns the run's bind mounts: the volumes, the state directory,
+// forwarded credentials and argument paths.
+func (o RunOptions) binds() []string {
+	binds := append([]string{}, o.Volumes...)
+	if o.StateDir != "" {
+		binds = append(binds, o.StateDir+":"+state.Home)
+	}
+	binds = append(binds, o.Forward.Binds...)
+	return append(binds, o.PathMounts...)
 }
 
 type runIODiagnostics struct {
@@ -76,6 +152,74 @@ type runIODiagnostics struct {
 	writer      io.Writer
 	runID       string
 	containerID string
+	// trace is shared by all copies of the diagnostics for one run.
+	trace *runTrace
+}
+
+// runTrace records how a run went for the closing "exit" event and stats.
+type runTrace struct {
+	mu          sync.Mutex
+	path        string
+	containerID string
+	poolHit     bool
+	poolMiss    bool
+	evicted     int
+	fallback    bool
+	commandAt   time.Time
+	firstByteAt time.Time
+}
+
+func (t *runTrace) commandStarted() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.commandAt.IsZero() {
+		t.commandAt = time.Now()
+	}
+}
+
+func (t *runTrace) wrapOutput(w io.Writer) io.Writer {
+	if t == nil || w == nil {
+		return w
+	}
+	return firstByteWriter{w: w, trace: t}
+}
+
+// firstByteWriter notes when the command first produced output.
+type firstByteWriter struct {
+	w     io.Writer
+	trace *runTrace
+}
+
+func (f firstByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		f.trace.mu.Lock()
+		if f.trace.firstByteAt.IsZero() {
+			f.trace.firstByteAt = time.Now()
+		}
+		f.trace.mu.Unlock()
+	}
+	return f.w.Write(p)
+}
+
+func newRunIODiagnostics(opts RunOptions) runIODiagnostics {
+	diag := runIODiagnostics{
+		textEnabled: opts.DebugIO,
+		jsonEnabled: opts.DebugIOJSON,
+		start:       opts.InvokedAt,
+		writer:      opts.Stderr,
+		runID:       uuid.NewString(),
+		trace:       &runTrace{},
+	}
+	if diag.start.IsZero() {
+		diag.start = time.Now()
+	}
+	if (diag.textEnabled || diag.jsonEnabled) && diag.writer == nil {
+		diag.writer = os.Stderr
+	}
+	return diag
 }
 
 type runIODiagnosticEvent struct {
@@ -92,14 +236,20 @@ func (d runIODiagnostics) event(name string) {
 }
 
 func (d runIODiagnostics) eventWithDetails(name string, details map[string]any) {
+	d.eventAt(name, time.Now(), details)
+}
+
+// eventAt emits an event that happened at a given time, e.g. before the
+// runtime was reached.
+func (d runIODiagnostics) eventAt(name string, at time.Time, details map[string]any) {
 	if (!d.textEnabled && !d.jsonEnabled) || d.writer == nil {
 		return
 	}
 
-	elapsedMs := time.Since(d.start).Milliseconds()
+	elapsedMs := at.Sub(d.start).Milliseconds()
 
 	if d.textEnabled {
-		_, _ = fmt.Fprintf(d.writer, "[tuprwre][debug-io] +%dms %s\n", elapsedMs, name)
+		_, _ = fmt.Fprintf(d.writer, "[tuprwre][debug-io] +%dms %s%s\n", elapsedMs, name, formatDiagnosticDetails(details))
 	}
 
 	if d.jsonEnabled {
@@ -108,7 +258,7 @@ func (d runIODiagnostics) eventWithDetails(name string, details map[string]any)
 			RunID:       d.runID,
 			Event:       name,
 			ElapsedMs:   elapsedMs,
-			ContainerID: d.containerID,
+			ContainerID: d.eventContainerID(),
 			Details:     details,
 		}
 
@@ -119,6 +269,57 @@ func (d runIODiagnostics) eventWithDetails(name string, details map[string]any)
 	}
 }
 
+func (d runIODiagnostics) eventContainerID() string {
+	if d.containerID != "" || d.trace == nil {
+		return d.containerID
+	}
+	d.trace.mu.Lock()
+	defer d.trace.mu.Unlock()
+	return d.trace.containerID
+}
+
+func formatDiagnosticDetails(details map[string]any) string {
+	if len(details) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(&b, " %s=%v", k, details[k])
+	}
+	return b.String()
+}
+
+// exitDetails summarizes the run for the closing "exit" event.
+func (d runIODiagnostics) exitDetails(exitCode int) map[string]any {
+	t := d.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	details := map[string]any{
+		"path":      t.path,
+		"exit_code": exitCode,
+	}
+	if !t.commandAt.IsZero() {
+		details["overhead_ms"] = t.commandAt.Sub(d.start).Milliseconds()
+	}
+	if !t.firstByteAt.IsZero() {
+		details["first_byte_ms"] = t.firstByteAt.Sub(d.start).Milliseconds()
+	}
+	if t.path == runPathPool {
+		details["pool_hit"] = t.poolHit
+	}
+	if t.fallback {
+		details["pool_fallback"] = true
+	}
+	return details
+}
+
 // New creates a new DockerRuntime instance.
 func New(cfg *config.Config) *DockerRuntime {
 	return &DockerRuntime{
@@ -128,6 +329,8 @@ func New(cfg *config.Config) *DockerRuntime {
 
 // initClient initializes the Docker client (lazy initialization).
 func (d *DockerRuntime) initClient() error {
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
 	if d.client != nil {
 		return nil
 	}
@@ -153,24 +356,39 @@ func (d *DockerRuntime) initClient() error {
 }
 
 func (d *DockerRuntime) initPool() error {
-	if d.pool != nil {
-		return nil
-	}
 	if !d.config.WarmPoolEnabled {
 		return nil
 	}
 	if err := d.initClient(); err != nil {
 		return err
 	}
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
+	if d.pool != nil {
+		return nil
+	}
 	ttl, err := time.ParseDuration(d.config.WarmPoolTTL)
 	if err != nil {
 		ttl = 10 * time.Minute
 	}
+	maxAge, err := time.ParseDuration(d.config.WarmPoolMaxAge)
+	if err != nil {
+		maxAge = 0
+	}
+	probeAfter, err := time.ParseDuration(d.config.WarmPoolProbeAfter)
+	if err != nil {
+		probeAfter = 30 * time.Second
+	}
 	d.pool = pool.NewWarmPool(d.client, pool.PoolConfig{
 		PoolDir:   d.config.PoolDir,
 		MaxPerKey: d.config.WarmPoolMaxPerKey,
 		MaxTotal:  d.config.WarmPoolMaxTotal,
 		TTL:       ttl,
+
+		MaxExecsPerContainer: d.config.WarmPoolMaxExecs,
+		MaxUses:              d.config.WarmPoolMaxUses,
+		MaxAge:               maxAge,
+		ProbeAfter:           probeAfter,
 	})
 	return nil
 }
@@ -198,6 +416,10 @@ func (d *DockerRuntime) PullImage(ctx context.Context, imageName string) error {
 	}
 
 	// Image doesn't exist, pull it
+	return d.pullImage(ctx, imageName)
+}
+
+func (d *DockerRuntime) pullImage(ctx context.Context, imageName string) error {
 	fmt.Printf("Pulling image %s...\n", imageName)
 	reader, err := d.client.ImagePull(ctx, imageName, image.PullOptions{})
 	if err != nil {
@@ -344,14 +566,17 @@ func (d *DockerRuntime) ListTuprwreImages(ctx context.Context) ([]TuprwreImage,
 
 // CreateAndRunContainer creates a container, runs the command, and returns the container ID.
 // It streams stdout/stderr to the terminal in real-time.
-// Resource limits from the policy are applied to the container's HostConfig.
-func (d *DockerRuntime) CreateAndRunContainer(ctx context.Context, baseImage, command string, resources ResourcePolicy) (string, error) {
+// Resource limits and the install policy are applied to the container's HostConfig.
+func (d *DockerRuntime) CreateAndRunContainer(ctx context.Context, baseImage, command string, resources ResourcePolicy, policy InstallPolicy) (string, error) {
 	if err := d.initClient(); err != nil {
 		return "", err
 	}
 
 	// Ensure image is available
-	if err := d.PullImage(ctx, baseImage); err != nil {
+	pullCtx, span := telemetry.Start(ctx, "install.pull", telemetry.AttrImage.String(baseImage))
+	err := d.PullImage(pullCtx, baseImage)
+	telemetry.End(span, err)
+	if err != nil {
 		return "", err
 	}
 
@@ -361,7 +586,7 @@ func (d *DockerRuntime) CreateAndRunContainer(ctx context.Context, baseImage, co
 	// Create container configuration
 	config := &container.Config{
 		Image:        baseImage,
-		Cmd:          []string{"sh", "-c", command},
+		Cmd:          policy.command(command),
 		Tty:          false,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -370,8 +595,20 @@ func (d *DockerRuntime) CreateAndRunContainer(ctx context.Context, baseImage, co
 
 	hostConfig := &container.HostConfig{}
 	applyResourceLimits(hostConfig, resources)
+	policy.apply(config, hostConfig)
+
+	if allow, ok := policy.EgressAllowlist(); ok {
+		gateway, err := d.startEgressGateway(ctx, allow)
+		if err != nil {
+			return "", err
+		}
+		defer d.stopEgressGateway(gateway)
+		config.Env = append(config.Env, gateway.env()...)
+		hostConfig.NetworkMode = container.NetworkMode(gateway.networkID)
+	}
 
 	// Create the container
+	_, span = telemetry.Start(ctx, "install.create", telemetry.AttrImage.String(baseImage))
 	resp, err := d.client.ContainerCreate(
 		ctx,
 		config,
@@ -381,18 +618,48 @@ func (d *DockerRuntime) CreateAndRunContainer(ctx context.Context, baseImage, co
 		containerName,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to create container: %w", err)
+		err = fmt.Errorf("failed to create container: %w", err)
+		telemetry.End(span, err)
+		return "", err
 	}
+	span.SetAttributes(telemetry.AttrContainerID.String(resp.ID))
+	telemetry.End(span, nil)
 
 	containerID := resp.ID
 
-	exitCode, err := d.runAttachedAndDrain(ctx, resp.ID, nil, os.Stdout, os.Stderr, runIODiagnostics{})
+	// Secrets travel over the attach stream, so they never appear in the
+	// container config that Commit copies into the image.
+	var stdin io.Reader
+	if len(policy.Secrets) > 0 {
+		archive, err := secretsArchive(policy.Secrets)
+		if err != nil {
+			return containerID, fmt.Errorf("failed to pack install secrets: %w", err)
+		}
+		stdin = bytes.NewReader(archive)
+	}
+
+	runCtx, span := telemetry.Start(ctx, "install.run", telemetry.AttrContainerID.String(containerID))
+	if timeout := policy.timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, timeout)
+		defer cancel()
+	}
+	exitCode, err := d.runAttachedAndDrain(runCtx, resp.ID, stdin, os.Stdout, os.Stderr, runIODiagnostics{})
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		_ = d.client.ContainerKill(context.Background(), containerID, "KILL")
+		err = fmt.Errorf("install command timed out after %s", policy.timeout())
+	}
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("container exited with code %d", exitCode)
+	}
+	if err == nil && len(policy.Secrets) > 0 {
+		err = d.checkSecretLeaks(ctx, containerID, policy.Secrets)
+	}
+	span.SetAttributes(telemetry.AttrExitCode.Int(exitCode))
+	telemetry.End(span, err)
 	if err != nil {
 		return containerID, err
 	}
-	if exitCode != 0 {
-		return containerID, fmt.Errorf("container exited with code %d", exitCode)
-	}
 
 	return containerID, nil
 }
@@ -436,7 +703,9 @@ func (d *DockerRuntime) runAttachedAndDrain(ctx context.Context, containerID str
 	var drainOnce sync.Once
 	waitForDrain := func() {
 		drainOnce.Do(func() {
+			_, span := telemetry.Start(ctx, "run.io_drain")
 			<-outputDone
+			span.End()
 			diag.event("stream-eof")
 		})
 	}
@@ -455,6 +724,7 @@ func (d *DockerRuntime) runAttachedAndDrain(ctx context.Context, containerID str
 		forceCloseAndDrain()
 		return 1, fmt.Errorf("failed to start container: %w", err)
 	}
+	diag.trace.commandStarted()
 	diag.event("start")
 
 	if stdin != nil {
@@ -510,6 +780,9 @@ func (d *DockerRuntime) Commit(ctx context.Context, containerID, imageName strin
 	commitOptions := container.CommitOptions{
 		Comment: "tuprwre installation commit",
 		Author:  "tuprwre",
+		// An install on top of a step image must not answer for that
+		// step's cache key.
+		Changes: []string{fmt.Sprintf("LABEL %s=", LabelStepKey)},
 	}
 
 	resp, err := d.client.ContainerCommit(ctx, containerID, commitOptions)
@@ -640,14 +913,177 @@ func (d *DockerRuntime) Run(opts RunOptions) (int, error) {
 	return d.runWithContext(context.Background(), opts)
 }
 
+// RunContext is Run with a caller-supplied context (used by the daemon).
+func (d *DockerRuntime) RunContext(ctx context.Context, opts RunOptions) (int, error) {
+	return d.runWithContext(ctx, opts)
+}
+
+// Prewarm makes sure an idle warm container exists for the pool key that
+// opts would use, so the next matching run skips container creation.
+func (d *DockerRuntime) Prewarm(ctx context.Context, opts RunOptions) error {
+	if err := d.initPool(); err != nil {
+		return err
+	}
+	if d.pool == nil {
+		return fmt.Errorf("warm pool is disabled")
+	}
+	key, err := d.poolKey(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return d.pool.Warm(ctx, key)
+}
+
+// PoolGC removes dead and TTL-expired warm containers.
+func (d *DockerRuntime) PoolGC(ctx context.Context) (int, error) {
+	if err := d.initPool(); err != nil {
+		return 0, err
+	}
+	if d.pool == nil {
+		return 0, nil
+	}
+	return d.pool.GC(ctx)
+}
+
+// PoolStatus reports the containers currently in the warm pool.
+func (d *DockerRuntime) PoolStatus(ctx context.Context) ([]pool.ContainerStatus, error) {
+	if err := d.initPool(); err != nil {
+		return nil, err
+	}
+	if d.pool == nil {
+		return nil, nil
+	}
+	return d.pool.Status(ctx)
+}
+
+// PoolDrain removes warm containers; see pool.WarmPool.Drain.
+func (d *DockerRuntime) PoolDrain(ctx context.Context, force bool) (int, error) {
+	if err := d.initPool(); err != nil {
+		return 0, err
+	}
+	if d.pool == nil {
+		return 0, nil
+	}
+	return d.pool.Drain(ctx, force)
+}
+
+// EvictPoolImages removes warm containers created from any of refs (image
+// names or IDs). Leased containers are only removed when force is set, which
+// is needed before the image itself can be deleted.
+func (d *DockerRuntime) EvictPoolImages(ctx context.Context, refs []string, force bool) (int, error) {
+	if err := d.initClient(); err != nil {
+		return 0, err
+	}
+	if err := d.initPool(); err != nil {
+		return 0, err
+	}
+	wp := d.pool
+	if wp == nil {
+		// Containers from before the pool was disabled still hold images.
+		wp = pool.NewWarmPool(d.client, pool.PoolConfig{PoolDir: d.config.PoolDir})
+	}
+	return wp.EvictImages(ctx, refs, force)
+}
+
+// EvictPoolState removes warm containers that mount the state directory
+// dir, so a removed directory is not kept alive by an idle container.
+func (d *DockerRuntime) EvictPoolState(ctx context.Context, dir string, force bool) (int, error) {
+	if err := d.initClient(); err != nil {
+		return 0, err
+	}
+	if err := d.initPool(); err != nil {
+		return 0, err
+	}
+	wp := d.pool
+	if wp == nil {
+		wp = pool.NewWarmPool(d.client, pool.PoolConfig{PoolDir: d.config.PoolDir})
+	}
+	return wp.EvictState(ctx, dir, force)
+}
+
+// Run paths reported in diagnostics and stats.
+const (
+	runPathPool = stats.PathPool
+	runPathCold = stats.PathCold
+	runPathExec = "exec"
+)
+
 func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (int, error) {
+	ctx = telemetry.WithTraceParent(ctx, opts.TraceParent, "")
+	ctx, span := telemetry.Start(ctx, "run",
+		telemetry.AttrImage.String(opts.Image),
+		telemetry.AttrBinary.String(opts.Binary),
+	)
+
+	diag := newRunIODiagnostics(opts)
+	if !opts.ConfigLoadedAt.IsZero() {
+		diag.eventAt("config-load", opts.ConfigLoadedAt, nil)
+	}
+	if len(opts.PathMounts) > 0 {
+		diag.eventWithDetails("path-mounts", map[string]any{"mounts": opts.PathMounts})
+	}
+	warnings := opts.Stderr
+	if warnings == nil {
+		warnings = os.Stderr
+	}
+	opts.Stdout = diag.trace.wrapOutput(opts.Stdout)
+	opts.Stderr = diag.trace.wrapOutput(opts.Stderr)
+
+	stdout, stderr, closeCaptures, err := openCaptures(opts)
+	if err != nil {
+		telemetry.End(span, err)
+		return 1, err
+	}
+	defer closeCaptures()
+	var recording *runRecording
+	if opts.RecordDir != "" {
+		if recording, err = startRecording(opts); err != nil {
+			telemetry.End(span, err)
+			return 1, err
+		}
+		opts.Stdin, stdout, stderr = recording.wrap(opts.Stdin, stdout, stderr)
+	}
+	opts.Stdout, opts.Stderr = stdout, stderr
+
+	exitCode, err := d.dispatchRun(ctx, opts, diag)
+
+	diag.trace.mu.Lock()
+	span.SetAttributes(
+		telemetry.AttrExitCode.Int(exitCode),
+		telemetry.AttrRunPath.String(diag.trace.path),
+		telemetry.AttrContainerID.String(diag.trace.containerID),
+	)
+	diag.trace.mu.Unlock()
+	telemetry.End(span, err)
+
+	diag.eventWithDetails("exit", diag.exitDetails(exitCode))
+	if opts.ContainerID == "" {
+		d.recordRunStats(opts.Image, diag)
+	}
+	if recording != nil {
+		if recErr := recording.finish(d, opts, diag, exitCode, err); recErr != nil {
+			_, _ = fmt.Fprintf(warnings, "tuprwre: %v\n", recErr)
+		}
+	}
+	return exitCode, err
+}
+
+// dispatchRun picks the pool, exec or cold path for a run.
+func (d *DockerRuntime) dispatchRun(ctx context.Context, opts RunOptions, diag runIODiagnostics) (int, error) {
 	if err := d.initClient(); err != nil {
 		return 1, err
 	}
+	diag.event("docker-ping")
 
-	if !opts.NoPool && opts.ContainerID == "" && strings.EqualFold(strings.TrimSpace(opts.Runtime), "docker") {
+	if opts.ExpectedImageID != "" && opts.ContainerID == "" {
+		if err := d.verifyImageID(ctx, opts.Image, opts.ExpectedImageID); err != nil {
+			return 1, err
+		}
+	}
+
+	if !opts.NoPool && len(opts.PathMounts) == 0 && opts.ContainerID == "" && strings.EqualFold(strings.TrimSpace(opts.Runtime), "docker") {
 		if err := d.initPool(); err == nil && d.pool != nil {
-			exitCode, err := d.runViaPool(ctx, opts)
+			exitCode, err := d.runViaPool(ctx, opts, diag)
 			if err == nil {
 				return exitCode, nil
 			}
@@ -657,28 +1093,53 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 			if !errors.Is(err, pool.ErrPoolExhausted) {
 				return exitCode, err
 			}
+			diag.trace.fallback = true
 		}
 	}
 
 	// Exec path: if a container ID is provided, run via docker exec instead of
 	// creating a new container. This is the foundation for the warm pool.
 	if opts.ContainerID != "" {
-		return d.runViaExec(ctx, opts)
+		diag.trace.path = runPathExec
+		return d.runViaExec(ctx, opts, diag)
 	}
 
-	diag := runIODiagnostics{
-		textEnabled: opts.DebugIO,
-		jsonEnabled: opts.DebugIOJSON,
-		start:       time.Now(),
-		writer:      opts.Stderr,
-		runID:       uuid.NewString(),
+	diag.trace.path = runPathCold
+	return d.runCold(ctx, opts, diag)
+}
+
+// recordRunStats folds the run into the stats file; failures only cost the
+// sample.
+func (d *DockerRuntime) recordRunStats(image string, diag runIODiagnostics) {
+	if d.config == nil || d.config.StatsFile == "" {
+		return
 	}
-	if (diag.textEnabled || diag.jsonEnabled) && diag.writer == nil {
-		diag.writer = os.Stderr
+	t := diag.trace
+	t.mu.Lock()
+	run := stats.Run{
+		Image:    image,
+		Path:     t.path,
+		PoolHit:  t.poolHit,
+		PoolMiss: t.poolMiss,
+		Evicted:  t.evicted,
+		Fallback: t.fallback,
 	}
+	if !t.commandAt.IsZero() {
+		run.Overhead = t.commandAt.Sub(diag.start)
+	}
+	t.mu.Unlock()
+
+	_ = stats.Record(d.config.StatsFile, run)
+}
 
-	// Pull image if needed
-	if err := d.PullImage(ctx, opts.Image); err != nil {
+// runCold creates a fresh container for the run and removes it afterwards.
+func (d *DockerRuntime) runCold(ctx context.Context, opts RunOptions, diag runIODiagnostics) (int, error) {
+	// Create from the verified image ID when there is one so a retag after
+	// dispatchRun's check cannot swap the image; otherwise pull if needed.
+	image := opts.Image
+	if opts.ExpectedImageID != "" {
+		image = opts.ExpectedImageID
+	} else if err := d.PullImage(ctx, opts.Image); err != nil {
 		return 1, err
 	}
 
@@ -692,7 +1153,7 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 	cmd := append([]string{opts.Binary}, opts.Args...)
 
 	containerConfig := &container.Config{
-		Image:           opts.Image,
+		Image:           image,
 		Cmd:             cmd,
 		Tty:             false,
 		AttachStdin:     opts.Stdin != nil,
@@ -700,7 +1161,7 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 		AttachStderr:    true,
 		OpenStdin:       opts.Stdin != nil,
 		StdinOnce:       opts.Stdin != nil,
-		Env:             opts.Env,
+		Env:             opts.env(),
 		WorkingDir:      opts.WorkDir,
 		User:            fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid),
 		NetworkDisabled: opts.NoNetwork,
@@ -710,18 +1171,16 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 	hostConfig := &container.HostConfig{
 		AutoRemove:     false,
 		ReadonlyRootfs: true,
-		Tmpfs: map[string]string{
-			"/tmp": "size=64m,noexec",
-		},
 	}
+	opts.Hardening.Apply(hostConfig)
 
 	applyResourceLimits(hostConfig, ResourcePolicy{
 		Memory: opts.MemoryLimit,
 		CPUs:   opts.CPULimit,
 	})
 
-	if len(opts.Volumes) > 0 {
-		hostConfig.Binds = opts.Volumes
+	if binds := opts.binds(); len(binds) > 0 {
+		hostConfig.Binds = binds
 	}
 
 	// Create container (but don't start it yet)
@@ -731,13 +1190,10 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 		return 1, fmt.Errorf("failed to create container: %w", err)
 	}
 	diag.containerID = resp.ID
+	diag.trace.containerID = resp.ID
 	diag.event("create")
 
-	var captureFile *os.File
 	defer func() {
-		if captureFile != nil {
-			_ = captureFile.Close()
-		}
 		diag.event("cleanup")
 		removeOptions := container.RemoveOptions{
 			Force:         true,
@@ -746,33 +1202,24 @@ func (d *DockerRuntime) runWithContext(ctx context.Context, opts RunOptions) (in
 		_ = d.client.ContainerRemove(context.Background(), resp.ID, removeOptions)
 	}()
 
-	stdout := opts.Stdout
-	stderr := opts.Stderr
-	if opts.CaptureFile != "" {
-		captureFile, err = os.Create(opts.CaptureFile)
-		if err != nil {
-			return 1, fmt.Errorf("failed to create capture file: %w", err)
-		}
-
-		if stdout == nil {
-			stdout = io.Discard
-		}
-		if stderr == nil {
-			stderr = io.Discard
-		}
-
-		stdout = io.MultiWriter(stdout, captureFile)
-		stderr = io.MultiWriter(stderr, captureFile)
-	}
-
-	return d.runAttachedAndDrain(ctx, resp.ID, opts.Stdin, stdout, stderr, diag)
+	ctx, span := telemetry.Start(ctx, "run.exec", telemetry.AttrContainerID.String(resp.ID))
+	exitCode, err := d.runAttachedAndDrain(ctx, resp.ID, opts.Stdin, opts.Stdout, opts.Stderr, diag)
+	span.SetAttributes(telemetry.AttrExitCode.Int(exitCode))
+	telemetry.End(span, err)
+	return exitCode, err
 }
 
 // runViaExec routes a run through docker exec on an existing container.
 // This is the exec path used when RunOptions.ContainerID is set.
 // Container-level settings (NoNetwork, Volumes, ReadOnlyCwd, MemoryLimit, CPULimit)
 // are assumed to already be configured on the target container.
-func (d *DockerRuntime) runViaExec(ctx context.Context, opts RunOptions) (int, error) {
+func (d *DockerRuntime) runViaExec(ctx context.Context, opts RunOptions, diag runIODiagnostics) (int, error) {
+	// An existing container's mounts are fixed, so runs that need binds of
+	// their own cannot exec into one.
+	if opts.StateDir != "" || len(opts.Forward.Binds) > 0 || len(opts.PathMounts) > 0 {
+		return 1, fmt.Errorf("container %s cannot be given persistent state, forwarded credentials or path mounts; run without --container-id", opts.ContainerID)
+	}
+
 	currentUser, err := user.Current()
 	if err != nil {
 		return 1, fmt.Errorf("failed to get current user: %w", err)
@@ -789,51 +1236,72 @@ func (d *DockerRuntime) runViaExec(ctx context.Context, opts RunOptions) (int, e
 		stderr = io.Discard
 	}
 
-	var captureFile *os.File
-	if opts.CaptureFile != "" {
-		captureFile, err = os.Create(opts.CaptureFile)
-		if err != nil {
-			return 1, fmt.Errorf("failed to create capture file: %w", err)
-		}
-		defer captureFile.Close()
-		stdout = io.MultiWriter(stdout, captureFile)
-		stderr = io.MultiWriter(stderr, captureFile)
-	}
-
-	return d.ExecWithExitCode(ctx, ExecOptions{
+	diag.trace.containerID = opts.ContainerID
+	ctx, span := telemetry.Start(ctx, "run.exec", telemetry.AttrContainerID.String(opts.ContainerID))
+	exitCode, err := d.ExecWithExitCode(ctx, ExecOptions{
 		ContainerID: opts.ContainerID,
 		Cmd:         cmd,
-		Env:         opts.Env,
+		Env:         opts.env(),
 		WorkDir:     opts.WorkDir,
 		User:        fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid),
 		Stdin:       opts.Stdin,
 		Stdout:      stdout,
 		Stderr:      stderr,
+		diag:        diag,
 	})
+	span.SetAttributes(telemetry.AttrExitCode.Int(exitCode))
+	telemetry.End(span, err)
+	return exitCode, err
 }
 
-func (d *DockerRuntime) runViaPool(ctx context.Context, opts RunOptions) (int, error) {
+// poolKey derives the warm pool key for a run. The image is resolved to its
+// ID so containers from a since re-committed tag are never reused.
+func (d *DockerRuntime) poolKey(ctx context.Context, opts RunOptions) (pool.PoolKey, error) {
 	currentUser, err := user.Current()
 	if err != nil {
-		return 1, fmt.Errorf("failed to get current user: %w", err)
+		return pool.PoolKey{}, fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	key := pool.PoolKey{
+	imageID := opts.ExpectedImageID
+	if imageID == "" {
+		if img, err := d.client.ImageInspect(ctx, opts.Image); err == nil {
+			imageID = img.ID
+		}
+	}
+
+	return pool.PoolKey{
 		Image:     opts.Image,
+		ImageID:   imageID,
 		NoNetwork: opts.NoNetwork,
 		Memory:    opts.MemoryLimit,
 		CPUs:      opts.CPULimit,
 		User:      fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid),
 		Binds:     opts.Volumes,
+		Forward:   opts.Forward.Binds,
+		State:     opts.StateDir,
 		Runtime:   opts.Runtime,
-	}
+		Hardening: opts.Hardening,
+	}, nil
+}
 
-	lease, err := d.pool.Acquire(ctx, key)
+func (d *DockerRuntime) runViaPool(ctx context.Context, opts RunOptions, diag runIODiagnostics) (int, error) {
+	lease, key, err := d.acquireLease(ctx, opts)
 	if err != nil {
-		return 0, err
+		return 1, err
 	}
 	defer d.pool.Release(context.Background(), lease)
 
+	diag.containerID = lease.ContainerID
+	diag.trace.path = runPathPool
+	diag.trace.containerID = lease.ContainerID
+	diag.trace.poolHit = lease.Info.Reused
+	diag.trace.poolMiss = !lease.Info.Reused
+	diag.trace.evicted = lease.Info.Evicted
+	if !lease.Info.LookedUpAt.IsZero() {
+		diag.eventAt("pool-lookup", lease.Info.LookedUpAt, nil)
+	}
+	diag.eventWithDetails("lock", map[string]any{"reused": lease.Info.Reused})
+
 	cmd := append([]string{opts.Binary}, opts.Args...)
 
 	stdout := opts.Stdout
@@ -845,27 +1313,20 @@ func (d *DockerRuntime) runViaPool(ctx context.Context, opts RunOptions) (int, e
 		stderr = io.Discard
 	}
 
-	var captureFile *os.File
-	if opts.CaptureFile != "" {
-		captureFile, err = os.Create(opts.CaptureFile)
-		if err != nil {
-			return 1, fmt.Errorf("failed to create capture file: %w", err)
-		}
-		defer captureFile.Close()
-		stdout = io.MultiWriter(stdout, captureFile)
-		stderr = io.MultiWriter(stderr, captureFile)
-	}
-
-	exitCode, execErr := d.ExecWithExitCode(ctx, ExecOptions{
+	execCtx, span := telemetry.Start(ctx, "run.exec", telemetry.AttrContainerID.String(lease.ContainerID))
+	exitCode, execErr := d.ExecWithExitCode(execCtx, ExecOptions{
 		ContainerID: lease.ContainerID,
 		Cmd:         cmd,
-		Env:         opts.Env,
+		Env:         opts.env(),
 		WorkDir:     opts.WorkDir,
 		User:        key.User,
 		Stdin:       opts.Stdin,
 		Stdout:      stdout,
 		Stderr:      stderr,
+		diag:        diag,
 	})
+	span.SetAttributes(telemetry.AttrExitCode.Int(exitCode))
+	telemetry.End(span, execErr)
 
 	if execErr != nil {
 		lease.MarkUnhealthy()
@@ -875,6 +1336,28 @@ func (d *DockerRuntime) runViaPool(ctx context.Context, opts RunOptions) (int, e
 	return exitCode, nil
 }
 
+// acquireLease resolves the pool key for opts and leases a warm container.
+func (d *DockerRuntime) acquireLease(ctx context.Context, opts RunOptions) (*pool.Lease, pool.PoolKey, error) {
+	ctx, span := telemetry.Start(ctx, "run.pool_acquire", telemetry.AttrImage.String(opts.Image))
+	key, err := d.poolKey(ctx, opts)
+	if err != nil {
+		telemetry.End(span, err)
+		return nil, key, err
+	}
+
+	lease, err := d.pool.Acquire(ctx, key)
+	if err != nil {
+		telemetry.End(span, err)
+		return nil, key, err
+	}
+	span.SetAttributes(
+		telemetry.AttrPoolReused.Bool(lease.Info.Reused),
+		telemetry.AttrContainerID.String(lease.ContainerID),
+	)
+	telemetry.End(span, nil)
+	return lease, key, nil
+}
+
 // ListExecutables returns all executable files in the container's PATH.
 func (d *DockerRuntime) ListExecutables(containerID string) ([]string, error) {
 	ctx := context.Background()
@@ -1069,6 +1552,8 @@ func splitRepoTag(repoTag string) (string, string, bool) {
 
 // Close closes the Docker client connection.
 func (d *DockerRuntime) Close() error {
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
 	if d.client != nil {
 		return d.client.Close()
 	}
@@ -458,7 +458,7 @@ func TestDockerRuntimeRun_DebugIOEvents(t *testing.T) {
 	}
 
 	debugLog := stderr.String()
-	for _, event := range []string{"create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup"} {
+	for _, event := range []string{"docker-ping", "create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup", "exit"} {
 		if !strings.Contains(debugLog, event) {
 			t.Fatalf("missing debug event %q in %q", event, debugLog)
 		}
@@ -545,7 +545,7 @@ func TestDockerRuntimeRun_DebugIOJSONEvents(t *testing.T) {
 		t.Fatal("expected json diagnostic events")
 	}
 
-	expectedOrder := []string{"create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup"}
+	expectedOrder := []string{"docker-ping", "create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup", "exit"}
 	if len(events) != len(expectedOrder) {
 		t.Fatalf("unexpected event count: got=%d want=%d", len(events), len(expectedOrder))
 	}
@@ -563,7 +563,8 @@ func TestDockerRuntimeRun_DebugIOJSONEvents(t *testing.T) {
 		if evt.RunID != runID {
 			t.Fatalf("run_id mismatch at %d: got=%q want=%q", i, evt.RunID, runID)
 		}
-		if evt.ContainerID == "" {
+		// The container does not exist yet when the Docker ping completes.
+		if evt.ContainerID == "" && evt.Event != "docker-ping" {
 			t.Fatalf("container_id missing at %d event=%s", i, evt.Event)
 		}
 		if _, parseErr := time.Parse(time.RFC3339Nano, evt.Timestamp); parseErr != nil {
@@ -579,7 +580,7 @@ func TestDockerRuntimeRun_DebugIOJSONEvents(t *testing.T) {
 func TestDockerRuntimeRun_DebugIOJSONEventOrderAcrossScenarios(t *testing.T) {
 	rt := requireDockerRuntime(t)
 
-	baseOrder := []string{"create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup"}
+	baseOrder := []string{"docker-ping", "create", "attach", "wait-registered", "start", "wait-exit", "stream-eof", "cleanup", "exit"}
 
 	tests := []struct {
 		name   string
@@ -720,3 +721,59 @@ func TestDockerRuntimeRun_DebugIOTextAndJSONTogether(t *testing.T) {
 		t.Fatal("expected json debug diagnostics")
 	}
 }
+
+func TestRunIODiagnostics_ExitEventSummarizesTrace(t *testing.T) {
+	var diagOut bytes.Buffer
+	var stdout bytes.Buffer
+
+	diag := newRunIODiagnostics(RunOptions{
+		DebugIOJSON: true,
+		Stderr:      &diagOut,
+		InvokedAt:   time.Now().Add(-100 * time.Millisecond),
+	})
+	diag.trace.path = runPathPool
+	diag.trace.poolHit = true
+	diag.trace.containerID = "c1"
+	diag.trace.commandStarted()
+
+	out := diag.trace.wrapOutput(&stdout)
+	_, _ = out.Write([]byte("hi"))
+	diag.eventWithDetails("exit", diag.exitDetails(3))
+
+	var evt struct {
+		Event       string         `json:"event"`
+		ContainerID string         `json:"container_id"`
+		ElapsedMs   int64          `json:"elapsed_ms"`
+		Details     map[string]any `json:"details"`
+	}
+	if err := json.Unmarshal(diagOut.Bytes(), &evt); err != nil {
+		t.Fatalf("invalid diagnostic event %q: %v", diagOut.String(), err)
+	}
+	if evt.Event != "exit" || evt.ContainerID != "c1" {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+	if evt.ElapsedMs < 100 {
+		t.Fatalf("elapsed_ms = %d, want measured from InvokedAt", evt.ElapsedMs)
+	}
+	if evt.Details["path"] != "pool" || evt.Details["exit_code"] != float64(3) || evt.Details["pool_hit"] != true {
+		t.Fatalf("unexpected details: %+v", evt.Details)
+	}
+	for _, key := range []string{"overhead_ms", "first_byte_ms"} {
+		if ms, ok := evt.Details[key].(float64); !ok || ms < 100 {
+			t.Fatalf("%s = %v, want >= 100", key, evt.Details[key])
+		}
+	}
+	if stdout.String() != "hi" {
+		t.Fatalf("wrapped output = %q", stdout.String())
+	}
+}
+
+func TestRunIODiagnostics_TextIncludesDetails(t *testing.T) {
+	var diagOut bytes.Buffer
+	diag := newRunIODiagnostics(RunOptions{DebugIO: true, Stderr: &diagOut})
+	diag.eventWithDetails("lock", map[string]any{"reused": true})
+
+	if got := diagOut.String(); !strings.Contains(got, "lock reused=true") {
+		t.Fatalf("text diagnostics = %q, want details", got)
+	}
+}
@@ -0,0 +1,243 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// SecretsDir is where file secrets appear inside the install container. It
+// is a tmpfs, so nothing written there reaches the committed image.
+const SecretsDir = "/run/secrets"
+
+// secretEnvDir holds env secrets inside SecretsDir until the install
+// wrapper has exported them.
+const secretEnvDir = ".env"
+
+// maxSecretSize bounds a secret so that all of them fit the tmpfs.
+const maxSecretSize = 256 << 10
+
+// maxLeakScanSize skips large files when looking for leaked secrets;
+// installed binaries are not where tokens get copied to.
+const maxLeakScanSize = 4 << 20
+
+var (
+	secretIDPattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	secretEnvPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Secret is a value an install command can read without it being written
+// to the install command, the container config or the committed image.
+type Secret struct {
+	ID string
+	// Env exports the secret as the environment variable ID instead of the
+	// file SecretsDir/ID.
+	Env   bool
+	Value []byte
+}
+
+// ParseSecret parses an --secret spec, "id=ID,src=PATH" or "id=ID,env=VAR",
+// and reads the secret's value from the host.
+func ParseSecret(spec string) (Secret, error) {
+	var id, src, env string
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return Secret{}, fmt.Errorf("invalid secret %q (want id=ID,src=PATH or id=ID,env=VAR)", spec)
+		}
+		switch key {
+		case "id":
+			id = value
+		case "src", "source":
+			src = value
+		case "env":
+			env = value
+		default:
+			return Secret{}, fmt.Errorf("invalid secret %q: unknown field %q", spec, key)
+		}
+	}
+	if !secretIDPattern.MatchString(id) {
+		return Secret{}, fmt.Errorf("invalid secret %q: id must be letters, digits, '.', '_' or '-'", spec)
+	}
+	if (src == "") == (env == "") {
+		return Secret{}, fmt.Errorf("invalid secret %q: set exactly one of src= or env=", spec)
+	}
+
+	if env != "" {
+		if !secretEnvPattern.MatchString(id) {
+			return Secret{}, fmt.Errorf("invalid secret %q: an env secret's id must be a valid variable name", spec)
+		}
+		value, ok := os.LookupEnv(env)
+		if !ok || value == "" {
+			return Secret{}, fmt.Errorf("secret %s: environment variable %s is not set", id, env)
+		}
+		if len(value) > maxSecretSize {
+			return Secret{}, fmt.Errorf("secret %s is larger than %d bytes", id, maxSecretSize)
+		}
+		return Secret{ID: id, Env: true, Value: []byte(value)}, nil
+	}
+
+	if strings.HasPrefix(src, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			src = filepath.Join(home, src[2:])
+		}
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return Secret{}, fmt.Errorf("failed to read secret %s: %w", id, err)
+	}
+	if !info.Mode().IsRegular() {
+		return Secret{}, fmt.Errorf("secret %s: %s is not a regular file", id, src)
+	}
+	if info.Size() > maxSecretSize {
+		return Secret{}, fmt.Errorf("secret %s is larger than %d bytes", id, maxSecretSize)
+	}
+	value, err := os.ReadFile(src)
+	if err != nil {
+		return Secret{}, fmt.Errorf("failed to read secret %s: %w", id, err)
+	}
+	return Secret{ID: id, Value: value}, nil
+}
+
+// SecretIDs returns the IDs of secrets, which is all that may be recorded
+// about them.
+func SecretIDs(secrets []Secret) []string {
+	if len(secrets) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// secretsArchive packs secrets as the tar stream the install wrapper
+// extracts into SecretsDir.
+func secretsArchive(secrets []Secret) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: secretEnvDir + "/", Mode: 0o700}); err != nil {
+		return nil, err
+	}
+	for _, s := range secrets {
+		name := s.ID
+		if s.Env {
+			name = secretEnvDir + "/" + s.ID
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o400, Size: int64(len(s.Value))}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(s.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// secretsPrelude extracts the secrets archive from stdin into SecretsDir and
+// exports the env secrets, then removes their files.
+const secretsPrelude = `tar -xf - -C ` + SecretsDir + ` || { echo "tuprwre: failed to unpack install secrets" >&2; exit 1; }
+for f in ` + SecretsDir + `/` + secretEnvDir + `/*; do [ -f "$f" ] && export "${f##*/}=$(cat "$f")"; done
+rm -rf ` + SecretsDir + `/` + secretEnvDir + `
+`
+
+// applySecrets mounts the secrets tmpfs and opens stdin for the archive.
+func applySecrets(cfg *container.Config, hc *container.HostConfig, user string) {
+	mode := "0700"
+	if user != "" {
+		// The extracting user owns what it unpacks, but must be able to
+		// create files in the mount first.
+		mode = "1777"
+	}
+	if hc.Tmpfs == nil {
+		hc.Tmpfs = map[string]string{}
+	}
+	hc.Tmpfs[SecretsDir] = "size=1m,noexec,nosuid,mode=" + mode
+	cfg.OpenStdin = true
+	cfg.StdinOnce = true
+	cfg.AttachStdin = true
+}
+
+// checkSecretLeaks refuses a finished install container that wrote a
+// secret's value into its filesystem, before it can be committed.
+func (d *DockerRuntime) checkSecretLeaks(ctx context.Context, containerID string, secrets []Secret) error {
+	changes, err := d.client.ContainerDiff(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to list install changes: %w", err)
+	}
+	changed := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		if change.Kind != container.ChangeDelete {
+			changed[path.Clean("/"+change.Path)] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	reader, err := d.client.ContainerExport(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to export install container: %w", err)
+	}
+	defer reader.Close()
+
+	leakPath, id, err := findSecretInTar(reader, changed, secrets)
+	if err != nil {
+		return fmt.Errorf("failed to scan install container for secrets: %w", err)
+	}
+	if leakPath != "" {
+		return fmt.Errorf("the install wrote secret %q to %s; refusing to commit it into the image", id, leakPath)
+	}
+	return nil
+}
+
+// findSecretInTar returns the first changed regular file in the tar stream
+// that contains a secret's value verbatim, and that secret's ID.
+func findSecretInTar(r io.Reader, changed map[string]bool, secrets []Secret) (leakPath, id string, err error) {
+	var needles []Secret
+	for _, s := range secrets {
+		if value := bytes.TrimSpace(s.Value); len(value) > 0 {
+			needles = append(needles, Secret{ID: s.ID, Value: value})
+		}
+	}
+	if len(needles) == 0 {
+		return "", "", nil
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", "", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !changed[name] || hdr.Size > maxLeakScanSize {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return "", "", err
+		}
+		for _, s := range needles {
+			if bytes.Contains(content, s.Value) {
+				return name, s.ID, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestParseSecret(t *testing.T) {
+	dir := t.TempDir()
+	npmrc := filepath.Join(dir, ".npmrc")
+	if err := os.WriteFile(npmrc, []byte("//registry.npmjs.org/:_authToken=npm_abcdef\n"), 0o600); err != nil {
+		t.Fatalf("write npmrc: %v", err)
+	}
+	t.Setenv("GH_TOKEN", "ghp_0123456789")
+
+	file, err := ParseSecret("id=npm,src=" + npmrc)
+	if err != nil {
+		t.Fatalf("ParseSecret(src) failed: %v", err)
+	}
+	if file.ID != "npm" || file.Env || !strings.Contains(string(file.Value), "npm_abcdef") {
+		t.Fatalf("unexpected file secret: %+v", file)
+	}
+
+	env, err := ParseSecret("id=TOKEN,env=GH_TOKEN")
+	if err != nil {
+		t.Fatalf("ParseSecret(env) failed: %v", err)
+	}
+	if env.ID != "TOKEN" || !env.Env || string(env.Value) != "ghp_0123456789" {
+		t.Fatalf("unexpected env secret: %+v", env)
+	}
+
+	for _, spec := range []string{
+		"npm",
+		"id=npm",
+		"id=npm,src=" + npmrc + ",env=GH_TOKEN",
+		"id=../npm,src=" + npmrc,
+		"id=npm,src=" + filepath.Join(dir, "missing"),
+		"id=npm,src=" + dir,
+		"id=gh-token,env=GH_TOKEN",
+		"id=TOKEN,env=TUPRWRE_TEST_UNSET",
+		"id=npm,src=" + npmrc + ",mode=0400",
+	} {
+		if _, err := ParseSecret(spec); err == nil {
+			t.Errorf("ParseSecret(%q): expected error", spec)
+		}
+	}
+}
+
+func TestSecretsArchive(t *testing.T) {
+	archive, err := secretsArchive([]Secret{
+		{ID: "npm", Value: []byte("token-file")},
+		{ID: "TOKEN", Env: true, Value: []byte("token-env")},
+	})
+	if err != nil {
+		t.Fatalf("secretsArchive() failed: %v", err)
+	}
+
+	got := map[string]string{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read archive: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(content)
+	}
+	want := map[string]string{".env/": "", "npm": "token-file", ".env/TOKEN": "token-env"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("archive = %v, want %v", got, want)
+	}
+
+	policy := InstallPolicy{Secrets: []Secret{{ID: "npm", Value: []byte("x")}}}
+	cmd := policy.command("npm ci")
+	if len(cmd) != 4 || !strings.HasPrefix(cmd[2], secretsPrelude) || cmd[3] != "npm ci" {
+		t.Fatalf("command() = %v", cmd)
+	}
+	cfg := &container.Config{}
+	hc := &container.HostConfig{}
+	policy.apply(cfg, hc)
+	if !cfg.OpenStdin || !cfg.StdinOnce || !strings.Contains(hc.Tmpfs[SecretsDir], "mode=0700") {
+		t.Fatalf("secrets not wired up: %+v %+v", cfg, hc.Tmpfs)
+	}
+	if len(cfg.Env) != 0 {
+		t.Fatalf("secrets leaked into the container config: %v", cfg.Env)
+	}
+}
+
+func TestFindSecretInTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"root/.npmrc":       "//registry.npmjs.org/:_authToken=npm_abcdef\n",
+		"etc/untouched.txt": "npm_abcdef",
+		"usr/bin/tool":      "#!/bin/sh\n",
+	} {
+		_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+
+	secrets := []Secret{{ID: "TOKEN", Env: true, Value: []byte("npm_abcdef\n")}}
+	changed := map[string]bool{"/root/.npmrc": true, "/usr/bin/tool": true}
+	path, id, err := findSecretInTar(bytes.NewReader(buf.Bytes()), changed, secrets)
+	if err != nil {
+		t.Fatalf("findSecretInTar() failed: %v", err)
+	}
+	if path != "/root/.npmrc" || id != "TOKEN" {
+		t.Fatalf("findSecretInTar() = %q, %q", path, id)
+	}
+
+	// Files the install did not touch are base image content.
+	path, _, err = findSecretInTar(bytes.NewReader(buf.Bytes()), map[string]bool{"/usr/bin/tool": true}, secrets)
+	if err != nil || path != "" {
+		t.Fatalf("findSecretInTar() = %q, %v; want no leak", path, err)
+	}
+}
@@ -0,0 +1,181 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/telemetry"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+)
+
+// LabelStepKey marks an image committed by a multi-step install with the
+// cache key of the step that produced it.
+const LabelStepKey = "tuprwre.step.key"
+
+// BuildStep is one layer of a multi-step install.
+type BuildStep struct {
+	// Command is run with `sh -c` on top of the previous step's image.
+	Command string
+
+	// Files are host files the step depends on. Their contents are part of
+	// the cache key, so editing one invalidates the step.
+	Files []string
+
+	// Display is printed instead of Command, e.g. with credentials masked.
+	Display string
+}
+
+// StepCacheKey derives the cache key of step when run on top of the image
+// with ID parentImageID under policy.
+func StepCacheKey(parentImageID string, step BuildStep, policy InstallPolicy) (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "parent %s\ncommand %d\n%s\n", parentImageID, len(step.Command), step.Command)
+	_, _ = io.WriteString(h, policy.cacheSalt())
+
+	files := append([]string(nil), step.Files...)
+	sort.Strings(files)
+	for _, path := range files {
+		digest, err := fileDigest(path)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "file %s %s\n", filepath.Clean(path), digest)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read step file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read step file %s: %w", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BuildSteps runs steps in order on top of baseImage, committing each one as
+// its own image. A step whose cache key matches an image from an earlier
+// build is reused instead of run, unless noCache is set. Every step runs
+// under policy. It returns the ID of the final image.
+func (d *DockerRuntime) BuildSteps(ctx context.Context, baseImage string, steps []BuildStep, resources ResourcePolicy, policy InstallPolicy, noCache bool) (string, error) {
+	if err := d.initClient(); err != nil {
+		return "", err
+	}
+	if len(steps) == 0 {
+		return "", fmt.Errorf("no install steps provided")
+	}
+
+	if err := d.PullImage(ctx, baseImage); err != nil {
+		return "", err
+	}
+	base, err := d.client.ImageInspect(ctx, baseImage)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", baseImage, err)
+	}
+
+	parentID := base.ID
+	for i, step := range steps {
+		display := step.Display
+		if display == "" {
+			display = step.Command
+		}
+		fmt.Printf("Step %d/%d: %s\n", i+1, len(steps), display)
+		imageID, err := d.buildStep(ctx, parentID, step, resources, policy, noCache)
+		if err != nil {
+			return "", fmt.Errorf("step %d failed: %w", i+1, err)
+		}
+		parentID = imageID
+	}
+	return parentID, nil
+}
+
+func (d *DockerRuntime) buildStep(ctx context.Context, parentID string, step BuildStep, resources ResourcePolicy, policy InstallPolicy, noCache bool) (imageID string, err error) {
+	key, err := StepCacheKey(parentID, step, policy)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, span := telemetry.Start(ctx, "install.step", telemetry.AttrStepKey.String(key))
+	defer func() { telemetry.End(span, err) }()
+
+	if !noCache {
+		cached, err := d.findStepImage(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if cached != "" {
+			span.SetAttributes(telemetry.AttrStepCached.Bool(true))
+			fmt.Printf(" ---> Using cache %s\n", shortImageID(cached))
+			return cached, nil
+		}
+	}
+
+	containerID, err := d.CreateAndRunContainer(ctx, parentID, step.Command, resources, policy)
+	if containerID != "" {
+		defer d.CleanupContainer(context.Background(), containerID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := d.client.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Comment: "tuprwre install step",
+		Author:  "tuprwre",
+		Changes: []string{fmt.Sprintf("LABEL %s=%s", LabelStepKey, key)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit step: %w", err)
+	}
+	fmt.Printf(" ---> %s\n", shortImageID(resp.ID))
+	return resp.ID, nil
+}
+
+// findStepImage returns the ID of an image committed for the step cache key,
+// or "" when there is none.
+func (d *DockerRuntime) findStepImage(ctx context.Context, key string) (string, error) {
+	images, err := d.client.ImageList(ctx, image.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelStepKey+"="+key)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up step cache: %w", err)
+	}
+	if len(images) == 0 {
+		return "", nil
+	}
+	return images[0].ID, nil
+}
+
+// TagImage points imageName at the image with the given ID.
+func (d *DockerRuntime) TagImage(ctx context.Context, imageID, imageName string) error {
+	if err := d.initClient(); err != nil {
+		return err
+	}
+	if err := d.client.ImageTag(ctx, imageID, imageName); err != nil {
+		return fmt.Errorf("failed to tag image: %w", err)
+	}
+	fmt.Printf("Successfully tagged image: %s\n", imageName)
+	return nil
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
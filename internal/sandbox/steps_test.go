@@ -0,0 +1,60 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "setup.sh")
+	if err := os.WriteFile(script, []byte("echo one\n"), 0o644); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	step := BuildStep{Command: "sh /setup.sh", Files: []string{script}}
+	key, err := StepCacheKey("sha256:parent", step, InstallPolicy{})
+	if err != nil {
+		t.Fatalf("StepCacheKey() failed: %v", err)
+	}
+
+	again, err := StepCacheKey("sha256:parent", step, InstallPolicy{})
+	if err != nil {
+		t.Fatalf("StepCacheKey() failed: %v", err)
+	}
+	if again != key {
+		t.Fatalf("key is not deterministic: %s != %s", again, key)
+	}
+
+	otherParent, _ := StepCacheKey("sha256:other", step, InstallPolicy{})
+	otherCommand, _ := StepCacheKey("sha256:parent", BuildStep{Command: "sh /setup.sh -x", Files: step.Files}, InstallPolicy{})
+	if otherParent == key || otherCommand == key {
+		t.Fatal("key must change with the parent image and the command")
+	}
+
+	// Only the user a step runs as changes what it leaves behind.
+	hardened, _ := StepCacheKey("sha256:parent", step, InstallPolicy{CapDrop: []string{"ALL"}, Egress: EgressNone, Timeout: "1m0s"})
+	asUser, _ := StepCacheKey("sha256:parent", step, InstallPolicy{User: "1000"})
+	if hardened != key {
+		t.Fatal("key must not change with capability, network or time limits")
+	}
+	if asUser == key {
+		t.Fatal("key must change with the install user")
+	}
+
+	if err := os.WriteFile(script, []byte("echo two\n"), 0o644); err != nil {
+		t.Fatalf("rewrite script: %v", err)
+	}
+	edited, err := StepCacheKey("sha256:parent", step, InstallPolicy{})
+	if err != nil {
+		t.Fatalf("StepCacheKey() failed: %v", err)
+	}
+	if edited == key {
+		t.Fatal("key must change when a referenced file changes")
+	}
+
+	if _, err := StepCacheKey("sha256:parent", BuildStep{Command: "true", Files: []string{filepath.Join(dir, "missing")}}, InstallPolicy{}); err == nil {
+		t.Fatal("expected error for missing step file")
+	}
+}
@@ -0,0 +1,198 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Output formats.
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// PURL returns the package URL of p. Debian and Alpine packages are
+// namespaced by distro when it is known.
+func PURL(p Package, distro string) string {
+	name := p.Name
+	namespace := ""
+	switch p.Type {
+	case TypeDeb, TypeAPK:
+		namespace = distro
+	case TypePyPI:
+		name = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	case TypeNPM:
+		if scope, rest, ok := strings.Cut(name, "/"); ok && strings.HasPrefix(scope, "@") {
+			namespace, name = scope, rest
+		}
+	case TypeGolang:
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			namespace, name = name[:i], name[i+1:]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("pkg:" + p.Type + "/")
+	if namespace != "" {
+		for _, segment := range strings.Split(namespace, "/") {
+			b.WriteString(purlEscape(segment) + "/")
+		}
+	}
+	b.WriteString(purlEscape(name))
+	if p.Version != "" {
+		b.WriteString("@" + purlEscape(p.Version))
+	}
+	return b.String()
+}
+
+// purlEscape percent-encodes a purl segment; unlike a URL path, "@" must
+// be escaped too.
+func purlEscape(segment string) string {
+	return strings.ReplaceAll(url.PathEscape(segment), "@", "%40")
+}
+
+// Write renders s in format ("cyclonedx" or "spdx") as indented JSON.
+// toolVersion identifies the tuprwre build in the document.
+func Write(w io.Writer, s SBOM, format, toolVersion string) error {
+	var doc any
+	switch format {
+	case FormatCycloneDX:
+		doc = cycloneDX(s, toolVersion)
+	case FormatSPDX:
+		doc = spdx(s, toolVersion)
+	default:
+		return fmt.Errorf("unsupported SBOM format %q (supported: %s, %s)", format, FormatCycloneDX, FormatSPDX)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+type cdxComponent struct {
+	Type     string       `json:"type"`
+	BOMRef   string       `json:"bom-ref,omitempty"`
+	Name     string       `json:"name"`
+	Version  string       `json:"version,omitempty"`
+	PURL     string       `json:"purl,omitempty"`
+	Licenses []cdxLicense `json:"licenses,omitempty"`
+}
+
+type cdxLicense struct {
+	Expression string `json:"expression"`
+}
+
+func cycloneDX(s SBOM, toolVersion string) any {
+	components := make([]cdxComponent, 0, len(s.Packages))
+	seen := map[string]bool{}
+	for _, p := range s.Packages {
+		purl := PURL(p, s.Distro)
+		if seen[purl] {
+			continue
+		}
+		seen[purl] = true
+
+		component := cdxComponent{Type: "library", BOMRef: purl, Name: p.Name, Version: p.Version, PURL: purl}
+		if p.License != "" {
+			component.Licenses = []cdxLicense{{Expression: p.License}}
+		}
+		components = append(components, component)
+	}
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid.NewString(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": s.GeneratedAt,
+			"tools": map[string]any{
+				"components": []cdxComponent{{Type: "application", Name: "tuprwre", Version: toolVersion}},
+			},
+			"component": cdxComponent{Type: "container", BOMRef: "image", Name: s.Image, Version: s.ImageID},
+		},
+		"components": components,
+	}
+}
+
+type spdxPackage struct {
+	SPDXID           string       `json:"SPDXID"`
+	Name             string       `json:"name"`
+	VersionInfo      string       `json:"versionInfo,omitempty"`
+	DownloadLocation string       `json:"downloadLocation"`
+	LicenseConcluded string       `json:"licenseConcluded"`
+	LicenseDeclared  string       `json:"licenseDeclared"`
+	SourceInfo       string       `json:"sourceInfo,omitempty"`
+	ExternalRefs     []spdxExtRef `json:"externalRefs,omitempty"`
+	PrimaryPurpose   string       `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxExtRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+func spdx(s SBOM, toolVersion string) any {
+	const imageRef = "SPDXRef-Image"
+	packages := []spdxPackage{{
+		SPDXID:           imageRef,
+		Name:             s.Image,
+		VersionInfo:      s.ImageID,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		PrimaryPurpose:   "CONTAINER",
+	}}
+	relationships := []spdxRelationship{{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: imageRef}}
+
+	seen := map[string]bool{}
+	for _, p := range s.Packages {
+		purl := PURL(p, s.Distro)
+		if seen[purl] {
+			continue
+		}
+		seen[purl] = true
+
+		id := fmt.Sprintf("SPDXRef-Package-%d", len(packages))
+		// License strings from package databases are not always valid SPDX
+		// expressions, so they are not asserted.
+		packages = append(packages, spdxPackage{
+			SPDXID:           id,
+			Name:             p.Name,
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			SourceInfo:       "found at " + p.Location,
+			ExternalRefs:     []spdxExtRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: purl}},
+		})
+		relationships = append(relationships, spdxRelationship{Element: imageRef, Type: "CONTAINS", Related: id})
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              s.Image,
+		"documentNamespace": "urn:uuid:" + uuid.NewString(),
+		"creationInfo": map[string]any{
+			"created":  s.GeneratedAt,
+			"creators": []string{"Tool: tuprwre-" + toolVersion},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
@@ -0,0 +1,158 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// parseStanzas splits RFC 822 style "Key: value" records separated by blank
+// lines, as used by dpkg status and Python metadata. Continuation lines are
+// ignored.
+func parseStanzas(r io.Reader, fn func(fields map[string]string) bool) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	fields := map[string]string{}
+	flush := func() bool {
+		if len(fields) == 0 {
+			return true
+		}
+		more := fn(fields)
+		fields = map[string]string{}
+		return more
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if !flush() {
+				return
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if _, seen := fields[key]; !seen {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+}
+
+func parseDpkgStatus(r io.Reader) []Package {
+	var packages []Package
+	parseStanzas(r, func(fields map[string]string) bool {
+		status := strings.Fields(fields["Status"])
+		// Distroless status.d files carry no Status field.
+		if len(status) > 0 && status[len(status)-1] != "installed" {
+			return true
+		}
+		if fields["Package"] != "" {
+			// "Source: openssl (3.0.2-0ubuntu1)" names a differently versioned source.
+			source, _, _ := strings.Cut(fields["Source"], " ")
+			if source == fields["Package"] {
+				source = ""
+			}
+			packages = append(packages, Package{Type: TypeDeb, Name: fields["Package"], Version: fields["Version"], Source: source})
+		}
+		return true
+	})
+	return packages
+}
+
+func parseAPKInstalled(r io.Reader) []Package {
+	var packages []Package
+	var current Package
+	flush := func() {
+		if current.Name != "" {
+			current.Type = TypeAPK
+			if current.Source == current.Name {
+				current.Source = ""
+			}
+			packages = append(packages, current)
+		}
+		current = Package{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "P":
+			current.Name = value
+		case "V":
+			current.Version = value
+		case "L":
+			current.License = value
+		case "o":
+			current.Source = value
+		}
+	}
+	flush()
+	return packages
+}
+
+// parsePythonMetadata reads the header of a dist-info METADATA or egg-info
+// PKG-INFO file.
+func parsePythonMetadata(r io.Reader) []Package {
+	var pkg Package
+	parseStanzas(r, func(fields map[string]string) bool {
+		pkg = Package{Type: TypePyPI, Name: fields["Name"], Version: fields["Version"], License: fields["License"]}
+		return false
+	})
+	if pkg.Name == "" {
+		return nil
+	}
+	return []Package{pkg}
+}
+
+func parseNPMManifest(r io.Reader) []Package {
+	var manifest struct {
+		Name    string          `json:"name"`
+		Version string          `json:"version"`
+		License json.RawMessage `json:"license"`
+	}
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil || manifest.Name == "" || manifest.Version == "" {
+		return nil
+	}
+
+	license := ""
+	// "license" is usually an SPDX expression; older packages use an object.
+	_ = json.Unmarshal(manifest.License, &license)
+	return []Package{{Type: TypeNPM, Name: manifest.Name, Version: manifest.Version, License: license}}
+}
+
+func parseOSRelease(r io.Reader) OSRelease {
+	var release OSRelease
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			release.ID = value
+		case "VERSION_ID":
+			release.VersionID = value
+		}
+	}
+	return release
+}
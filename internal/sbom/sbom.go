@@ -0,0 +1,194 @@
+// Package sbom inventories the packages inside an image filesystem and
+// renders them as SPDX or CycloneDX JSON.
+package sbom
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Package types, as used in package URLs.
+const (
+	TypeDeb    = "deb"
+	TypeAPK    = "apk"
+	TypePyPI   = "pypi"
+	TypeNPM    = "npm"
+	TypeGolang = "golang"
+)
+
+// maxBinarySize bounds how much of an executable is read to look for Go
+// build info.
+const maxBinarySize = 512 << 20
+
+// Package is one installed package.
+type Package struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	License  string `json:"license,omitempty"`
+	Location string `json:"location"` // path inside the image it was found at
+
+	// Source is the distro source package a deb or apk was built from,
+	// when it differs from Name. Advisories are filed against it.
+	Source string `json:"source,omitempty"`
+}
+
+// SBOM is the package inventory of one image.
+type SBOM struct {
+	Image         string    `json:"image"`
+	ImageID       string    `json:"image_id,omitempty"`
+	Distro        string    `json:"distro,omitempty"`         // ID from /etc/os-release
+	DistroVersion string    `json:"distro_version,omitempty"` // VERSION_ID from /etc/os-release
+	GeneratedAt   string    `json:"generated_at"`
+	Packages      []Package `json:"packages"`
+}
+
+// OSRelease identifies an image's distribution release, from
+// /etc/os-release.
+type OSRelease struct {
+	ID        string // e.g. "debian"
+	VersionID string // e.g. "12"; empty for rolling releases
+}
+
+// Scan reads an image filesystem as a tar stream (e.g. from a container
+// export) and returns the packages it finds along with the distro release. It
+// understands dpkg status, the apk database, Python dist-info and egg-info
+// metadata, npm package.json files under node_modules and Go build info in
+// executables.
+func Scan(r io.Reader) ([]Package, OSRelease, error) {
+	var packages []Package
+	var release OSRelease
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, OSRelease{}, fmt.Errorf("failed to read image filesystem: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := "/" + strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		var found []Package
+		switch {
+		case name == "/etc/os-release" || (name == "/usr/lib/os-release" && release.ID == ""):
+			release = parseOSRelease(tr)
+			continue
+		case name == "/var/lib/dpkg/status" || strings.HasPrefix(name, "/var/lib/dpkg/status.d/"):
+			found = parseDpkgStatus(tr)
+		case name == "/lib/apk/db/installed":
+			found = parseAPKInstalled(tr)
+		case isPythonMetadata(name):
+			found = parsePythonMetadata(tr)
+		case isNPMManifest(name):
+			found = parseNPMManifest(tr)
+		case header.Mode&0o111 != 0 && header.Size > 4 && header.Size <= maxBinarySize && inBinDir(name):
+			found, err = parseGoBinary(tr, header.Size)
+			if err != nil {
+				return nil, OSRelease{}, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+		default:
+			continue
+		}
+
+		for _, pkg := range found {
+			pkg.Location = name
+			packages = append(packages, pkg)
+		}
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		a, b := packages[i], packages[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Location < b.Location
+	})
+	return packages, release, nil
+}
+
+func isPythonMetadata(name string) bool {
+	dir, file := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	switch {
+	case file == "METADATA" && strings.HasSuffix(dir, ".dist-info"):
+	case file == "PKG-INFO" && strings.HasSuffix(dir, ".egg-info"):
+	default:
+		return false
+	}
+	parent := path.Base(path.Dir(dir))
+	return parent == "site-packages" || parent == "dist-packages"
+}
+
+// isNPMManifest matches node_modules/<name>/package.json and
+// node_modules/@scope/<name>/package.json.
+func isNPMManifest(name string) bool {
+	i := strings.LastIndex(name, "/node_modules/")
+	if i < 0 || !strings.HasSuffix(name, "/package.json") {
+		return false
+	}
+	parts := strings.Split(strings.TrimSuffix(name[i+len("/node_modules/"):], "/package.json"), "/")
+	switch len(parts) {
+	case 1:
+		return parts[0] != "" && !strings.HasPrefix(parts[0], "@") && !strings.HasPrefix(parts[0], ".")
+	case 2:
+		return strings.HasPrefix(parts[0], "@") && parts[1] != ""
+	}
+	return false
+}
+
+func inBinDir(name string) bool {
+	for _, segment := range strings.Split(path.Dir(name), "/") {
+		if segment == "bin" || segment == "sbin" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGoBinary returns the main module, its dependencies and the Go
+// toolchain (as "stdlib") of a Go executable, or nothing for other files.
+func parseGoBinary(r io.Reader, size int64) ([]Package, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, []byte("\x7fELF")) {
+		return nil, nil
+	}
+	data := make([]byte, size)
+	copy(data, magic)
+	if _, err := io.ReadFull(r, data[4:]); err != nil {
+		return nil, err
+	}
+
+	info, err := buildinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil // not a Go binary
+	}
+
+	packages := []Package{{Type: TypeGolang, Name: "stdlib", Version: info.GoVersion}}
+	if info.Main.Path != "" {
+		packages = append(packages, Package{Type: TypeGolang, Name: info.Main.Path, Version: info.Main.Version})
+	}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		packages = append(packages, Package{Type: TypeGolang, Name: dep.Path, Version: dep.Version})
+	}
+	return packages, nil
+}
@@ -0,0 +1,130 @@
+package sbom
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeTar(t *testing.T, files map[string]string, modes map[string]int64) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		mode := modes[name]
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	return &buf
+}
+
+func TestScan(t *testing.T) {
+	self, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatalf("read test binary: %v", err)
+	}
+
+	files := map[string]string{
+		"etc/os-release": "NAME=\"Ubuntu\"\nID=ubuntu\nVERSION_ID=\"22.04\"\n",
+		"var/lib/dpkg/status": "Package: libssl3\nStatus: install ok installed\nSource: openssl (3.0.2-0ubuntu1)\nVersion: 3.0.2-0ubuntu1.10\n\n" +
+			"Package: jq\nStatus: install ok installed\nVersion: 1.6-2.1\nDescription: json\n  more\n\n" +
+			"Package: gone\nStatus: deinstall ok config-files\nVersion: 1.0\n",
+		"lib/apk/db/installed": "P:curl\nV:8.5.0-r0\nL:curl\n\nP:musl\nV:1.2.4-r2\n",
+		"usr/lib/python3/dist-packages/PyYAML-6.0.1.dist-info/METADATA": "Metadata-Version: 2.1\nName: PyYAML\nVersion: 6.0.1\nLicense: MIT\n\nName: not-a-header\n",
+		"usr/local/lib/node_modules/@scope/tool/package.json":           `{"name":"@scope/tool","version":"2.0.0","license":"ISC"}`,
+		"usr/local/lib/node_modules/@scope/tool/lib/package.json":       `{"name":"inner","version":"0.0.1"}`,
+		"usr/local/bin/gotool": string(self),
+		"usr/local/bin/script": "#!/bin/sh\necho hi\n",
+	}
+	modes := map[string]int64{"usr/local/bin/gotool": 0o755, "usr/local/bin/script": 0o755}
+
+	packages, release, err := Scan(writeTar(t, files, modes))
+	if err != nil {
+		t.Fatalf("Scan() failed: %v", err)
+	}
+	if release != (OSRelease{ID: "ubuntu", VersionID: "22.04"}) {
+		t.Fatalf("release = %+v, want ubuntu 22.04", release)
+	}
+
+	found := map[string]Package{}
+	for _, p := range packages {
+		found[p.Type+":"+p.Name] = p
+	}
+	for _, want := range []Package{
+		{Type: TypeDeb, Name: "jq", Version: "1.6-2.1", Location: "/var/lib/dpkg/status"},
+		{Type: TypeDeb, Name: "libssl3", Version: "3.0.2-0ubuntu1.10", Location: "/var/lib/dpkg/status", Source: "openssl"},
+		{Type: TypeAPK, Name: "curl", Version: "8.5.0-r0", License: "curl", Location: "/lib/apk/db/installed"},
+		{Type: TypeAPK, Name: "musl", Version: "1.2.4-r2", Location: "/lib/apk/db/installed"},
+		{Type: TypePyPI, Name: "PyYAML", Version: "6.0.1", License: "MIT", Location: "/usr/lib/python3/dist-packages/PyYAML-6.0.1.dist-info/METADATA"},
+		{Type: TypeNPM, Name: "@scope/tool", Version: "2.0.0", License: "ISC", Location: "/usr/local/lib/node_modules/@scope/tool/package.json"},
+	} {
+		if got := found[want.Type+":"+want.Name]; got != want {
+			t.Errorf("package %s = %+v, want %+v", want.Name, got, want)
+		}
+	}
+	for _, unwanted := range []string{"deb:gone", "npm:inner", "pypi:not-a-header"} {
+		if _, ok := found[unwanted]; ok {
+			t.Errorf("unexpected package %s", unwanted)
+		}
+	}
+	if stdlib := found["golang:stdlib"]; !strings.HasPrefix(stdlib.Version, "go") || stdlib.Location != "/usr/local/bin/gotool" {
+		t.Errorf("unexpected Go stdlib package: %+v", stdlib)
+	}
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		pkg  Package
+		want string
+	}{
+		{Package{Type: TypeDeb, Name: "jq", Version: "1.6-2.1"}, "pkg:deb/ubuntu/jq@1.6-2.1"},
+		{Package{Type: TypePyPI, Name: "Py_YAML", Version: "6.0.1"}, "pkg:pypi/py-yaml@6.0.1"},
+		{Package{Type: TypeNPM, Name: "@scope/tool", Version: "2.0.0"}, "pkg:npm/%40scope/tool@2.0.0"},
+		{Package{Type: TypeGolang, Name: "github.com/spf13/cobra", Version: "v1.8.0"}, "pkg:golang/github.com/spf13/cobra@v1.8.0"},
+	}
+	for _, tt := range tests {
+		if got := PURL(tt.pkg, "ubuntu"); got != tt.want {
+			t.Errorf("PURL(%+v) = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s := SBOM{
+		Image:       "tuprwre-jq:1",
+		ImageID:     "sha256:aaa",
+		Distro:      "ubuntu",
+		GeneratedAt: "2026-01-02T03:04:05Z",
+		Packages:    []Package{{Type: TypeDeb, Name: "jq", Version: "1.6", Location: "/var/lib/dpkg/status"}},
+	}
+
+	for _, format := range []string{FormatCycloneDX, FormatSPDX} {
+		var buf bytes.Buffer
+		if err := Write(&buf, s, format, "test"); err != nil {
+			t.Fatalf("Write(%s) failed: %v", format, err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("Write(%s) produced invalid JSON: %v", format, err)
+		}
+		if !strings.Contains(buf.String(), "pkg:deb/ubuntu/jq@1.6") {
+			t.Errorf("Write(%s) is missing the package purl:\n%s", format, buf.String())
+		}
+	}
+	if err := Write(&bytes.Buffer{}, s, "swid", "test"); err == nil {
+		t.Fatal("expected an unknown format to fail")
+	}
+}
@@ -6,24 +6,110 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/provenance"
+	"github.com/c4rb0nx1/tuprwre/internal/sandbox"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
 )
 
 // Metadata describes how a shim was created.
 type Metadata struct {
-	BinaryName        string   `json:"binary_name"`
-	InstallCommand    string   `json:"source_install_command"`
-	InstallMode       string   `json:"install_mode"`
-	InstallScriptPath string   `json:"install_script_path,omitempty"`
-	InstallScriptArgs []string `json:"install_script_args,omitempty"`
-	BaseImage         string   `json:"base_image"`
-	OutputImage       string   `json:"output_image"`
-	InstalledAt       string   `json:"installed_timestamp"`
-	InstallForceUsed  bool     `json:"install_force"`
-	Workspace         string   `json:"workspace,omitempty"`
+	BinaryName     string `json:"binary_name"`
+	InstallCommand string `json:"source_install_command"`
+	// InstallCommandHash is a salted hash of the original install command,
+	// set when credentials were masked out of InstallCommand.
+	InstallCommandHash string   `json:"install_command_hash,omitempty"`
+	InstallMode        string   `json:"install_mode"`
+	InstallScriptPath  string   `json:"install_script_path,omitempty"`
+	InstallScriptArgs  []string `json:"install_script_args,omitempty"`
+	InstallSteps       []string `json:"install_steps,omitempty"`
+	// InstallStepsHash and InstallScriptArgsHash are salted hashes of the
+	// original steps and script arguments, set when credentials were
+	// masked out of them.
+	InstallStepsHash      string `json:"install_steps_hash,omitempty"`
+	InstallScriptArgsHash string `json:"install_script_args_hash,omitempty"`
+	InstallDockerfile     string `json:"install_dockerfile,omitempty"`
+	InstallContext        string `json:"install_context,omitempty"`
+	ContextDigest         string `json:"context_digest,omitempty"`
+	SourceRef             string `json:"source_ref,omitempty"`
+	SourceDigest          string `json:"source_digest,omitempty"`
+	BaseImage             string `json:"base_image"`
+	OutputImage           string `json:"output_image"`
+	InstalledAt           string `json:"installed_timestamp"`
+	InstallForceUsed      bool   `json:"install_force"`
+	Workspace             string `json:"workspace,omitempty"`
+
+	// Hardening is the hardening profile the shim runs under; empty means
+	// the configured one.
+	Hardening string `json:"hardening,omitempty"`
+
+	// Forward lists the host credentials the shim's runs get, as canonical
+	// --forward specs (ssh-agent, gitconfig, file=PATH,env=VAR, ...).
+	Forward []string `json:"forward,omitempty"`
+
+	// State mounts a persistent per-shim home directory into its runs.
+	State bool `json:"state,omitempty"`
+
+	// PathPolicy is what runs do with arguments naming host paths outside
+	// the workspace; empty means the configured policy.
+	PathPolicy string `json:"path_policy,omitempty"`
+
+	// InstallPolicy is the hardening the install command ran under. It is
+	// nil when tuprwre did not run the install (--container, --from).
+	InstallPolicy *sandbox.InstallPolicy `json:"install_policy,omitempty"`
+
+	// InstallSecrets lists the IDs of the secrets the install was given.
+	// Their values are never stored.
+	InstallSecrets []string `json:"install_secrets,omitempty"`
+
+	// ImageID is the ID OutputImage resolved to at install time; Signature
+	// covers it together with the install source.
+	ImageID   string                `json:"image_id,omitempty"`
+	Signature *provenance.Signature `json:"signature,omitempty"`
+}
+
+// Statement returns the provenance statement Signature is made over.
+func (m Metadata) Statement() provenance.Statement {
+	return provenance.Statement{
+		Binary:         m.BinaryName,
+		ImageID:        m.ImageID,
+		BaseImage:      m.BaseImage,
+		InstallCommand: m.InstallCommand,
+		CommandHash:    m.InstallCommandHash,
+		InstallSteps:   m.InstallSteps,
+		StepsHash:      m.InstallStepsHash,
+		ContextDigest:  m.ContextDigest,
+		SourceDigest:   m.SourceDigest,
+		Hardening:      m.Hardening,
+		Forward:        m.Forward,
+		State:          m.State,
+		PathPolicy:     m.PathPolicy,
+	}
+}
+
+// FindMetadata returns the metadata of the shim that runs binaryName in
+// image, searching gens in order. ok is false when no shim matches.
+func FindMetadata(gens []*Generator, binaryName, image string) (Metadata, bool, error) {
+	if binaryName == "" || strings.ContainsAny(binaryName, `/\`) {
+		return Metadata{}, false, nil
+	}
+	for _, gen := range gens {
+		meta, err := gen.LoadMetadata(binaryName)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Metadata{}, false, err
+		}
+		if meta.OutputImage == image {
+			return meta, true, nil
+		}
+	}
+	return Metadata{}, false, nil
 }
 
 func (g *Generator) metadataDir() string {
-	return filepath.Join(g.config.BaseDir, "metadata")
+	return g.metaDir
 }
 
 // MetadataPath returns the path for a shim metadata file.
@@ -61,8 +147,42 @@ func (g *Generator) LoadMetadata(binaryName string) (Metadata, error) {
 	return metadata, nil
 }
 
-// RemoveMetadata deletes the metadata file for a shim.
+// SBOMPath returns the path of the SBOM stored for a shim.
+func (g *Generator) SBOMPath(binaryName string) string {
+	return filepath.Join(g.metadataDir(), "sbom", binaryName+".json")
+}
+
+// SaveSBOM stores the SBOM of the image behind a shim.
+func (g *Generator) SaveSBOM(binaryName string, s sbom.SBOM) error {
+	path := g.SBOMPath(binaryName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create SBOM directory: %w", err)
+	}
+
+	payload, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SBOM: %w", err)
+	}
+	return os.WriteFile(path, payload, 0o644)
+}
+
+// LoadSBOM reads the SBOM stored for a shim.
+func (g *Generator) LoadSBOM(binaryName string) (sbom.SBOM, error) {
+	var s sbom.SBOM
+
+	payload, err := os.ReadFile(g.SBOMPath(binaryName))
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return s, fmt.Errorf("failed to unmarshal SBOM: %w", err)
+	}
+	return s, nil
+}
+
+// RemoveMetadata deletes the metadata file for a shim, and its SBOM.
 func (g *Generator) RemoveMetadata(binaryName string) error {
+	_ = os.Remove(g.SBOMPath(binaryName))
 	return os.Remove(g.MetadataPath(binaryName))
 }
 
@@ -117,6 +237,7 @@ func (g *Generator) RemoveAllMetadata() ([]string, error) {
 		}
 		removed = append(removed, binaryName)
 	}
+	_ = os.RemoveAll(filepath.Join(g.metadataDir(), "sbom"))
 
 	return removed, firstErr
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
 )
 
 func TestMetadataRoundTripsScriptInstallFields(t *testing.T) {
@@ -150,3 +151,42 @@ func TestRemoveAllMetadata_CleansUp(t *testing.T) {
 		t.Fatalf("expected beta metadata removed, got err=%v", err)
 	}
 }
+
+func TestSBOMStoredAlongsideMetadata(t *testing.T) {
+	gen, _ := setupTestGenerator(t)
+
+	if err := gen.SaveMetadata(Metadata{BinaryName: "jq", OutputImage: "jq-image:latest"}); err != nil {
+		t.Fatalf("save metadata: %v", err)
+	}
+	want := sbom.SBOM{
+		Image:    "jq-image:latest",
+		Packages: []sbom.Package{{Type: sbom.TypeDeb, Name: "jq", Version: "1.6", Location: "/var/lib/dpkg/status"}},
+	}
+	if err := gen.SaveSBOM("jq", want); err != nil {
+		t.Fatalf("SaveSBOM() failed: %v", err)
+	}
+
+	// The SBOM must not show up as shim metadata.
+	all, err := gen.ListAllMetadata()
+	if err != nil {
+		t.Fatalf("ListAllMetadata() failed: %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("expected 1 metadata entry, got %d", len(all))
+	}
+
+	got, err := gen.LoadSBOM("jq")
+	if err != nil {
+		t.Fatalf("LoadSBOM() failed: %v", err)
+	}
+	if got.Image != want.Image || len(got.Packages) != 1 || got.Packages[0] != want.Packages[0] {
+		t.Fatalf("unexpected SBOM: %+v", got)
+	}
+
+	if err := gen.RemoveMetadata("jq"); err != nil {
+		t.Fatalf("RemoveMetadata() failed: %v", err)
+	}
+	if _, err := gen.LoadSBOM("jq"); !os.IsNotExist(err) {
+		t.Fatalf("expected SBOM removed with metadata, got err=%v", err)
+	}
+}
@@ -12,9 +12,23 @@ import (
 	"github.com/c4rb0nx1/tuprwre/internal/discovery"
 )
 
+// Scope identifies where a shim and its metadata are stored.
+type Scope string
+
+const (
+	// ScopeGlobal stores shims in config.ShimDir and metadata under BaseDir.
+	ScopeGlobal Scope = "global"
+	// ScopeWorkspace stores shims and metadata in config.WorkspaceDir, one
+	// directory per workspace under BaseDir.
+	ScopeWorkspace Scope = "workspace"
+)
+
 // Generator creates shim scripts for sandboxed binaries.
 type Generator struct {
-	config *config.Config
+	config  *config.Config
+	scope   Scope
+	shimDir string
+	metaDir string
 }
 
 // shimTemplate is the bash script template for proxying to Docker.
@@ -61,16 +75,84 @@ type shimData struct {
 	TuprwrePath string
 }
 
-// NewGenerator creates a new shim Generator.
+// NewGenerator creates a new shim Generator for the global scope.
 func NewGenerator(cfg *config.Config) *Generator {
 	return &Generator{
-		config: cfg,
+		config:  cfg,
+		scope:   ScopeGlobal,
+		shimDir: cfg.ShimDir,
+		metaDir: filepath.Join(cfg.BaseDir, "metadata"),
+	}
+}
+
+// NewScopedGenerator creates a shim Generator for the given scope.
+// The workspace scope is only available inside a workspace.
+func NewScopedGenerator(cfg *config.Config, scope Scope) (*Generator, error) {
+	switch scope {
+	case ScopeGlobal, "":
+		return NewGenerator(cfg), nil
+	case ScopeWorkspace:
+		if cfg.WorkspaceRoot == "" {
+			return nil, fmt.Errorf("workspace scope requires a workspace (run 'tuprwre init' first)")
+		}
+		return &Generator{
+			config:  cfg,
+			scope:   ScopeWorkspace,
+			shimDir: cfg.WorkspaceShimDir,
+			metaDir: filepath.Join(cfg.WorkspaceDir, "metadata"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown shim scope %q", scope)
 	}
 }
 
+// DefaultScope returns the scope new installs land in: the workspace when
+// one is active, global otherwise.
+func DefaultScope(cfg *config.Config) Scope {
+	if cfg.WorkspaceRoot != "" {
+		return ScopeWorkspace
+	}
+	return ScopeGlobal
+}
+
+// Generators returns one Generator per visible scope in lookup order
+// (workspace first, then global).
+func Generators(cfg *config.Config) []*Generator {
+	gens := make([]*Generator, 0, 2)
+	if ws, err := NewScopedGenerator(cfg, ScopeWorkspace); err == nil {
+		gens = append(gens, ws)
+	}
+	return append(gens, NewGenerator(cfg))
+}
+
+// Resolve returns the Generator whose scope owns binaryName, preferring
+// workspace shims over global ones. os.ErrNotExist is returned (wrapped)
+// when no scope has the shim.
+func Resolve(cfg *config.Config, binaryName string) (*Generator, error) {
+	for _, gen := range Generators(cfg) {
+		if _, err := os.Stat(gen.GetPath(binaryName)); err == nil {
+			return gen, nil
+		}
+	}
+	return nil, fmt.Errorf("shim %q not found: %w", binaryName, os.ErrNotExist)
+}
+
+// Scope returns the scope this Generator operates on.
+func (g *Generator) Scope() Scope {
+	return g.scope
+}
+
+// ShimDir returns the directory shims are written to for this scope.
+func (g *Generator) ShimDir() string {
+	return g.shimDir
+}
+
 // Create generates a shim script for the given binary.
 func (g *Generator) Create(binary discovery.Binary, imageName string, force bool) error {
-	shimPath := filepath.Join(g.config.ShimDir, binary.Name)
+	if err := os.MkdirAll(g.shimDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create shim directory: %w", err)
+	}
+	shimPath := filepath.Join(g.shimDir, binary.Name)
 
 	// Check if shim already exists
 	if _, err := os.Stat(shimPath); err == nil && !force {
@@ -121,7 +203,7 @@ func (g *Generator) Create(binary discovery.Binary, imageName string, force bool
 
 // Remove deletes a shim script.
 func (g *Generator) Remove(binaryName string) error {
-	shimPath := filepath.Join(g.config.ShimDir, binaryName)
+	shimPath := filepath.Join(g.shimDir, binaryName)
 	return os.Remove(shimPath)
 }
 
@@ -150,8 +232,11 @@ func (g *Generator) RemoveAll() ([]string, error) {
 // don't start with an alphanumeric character (e.g. "[", "test" shims
 // left over from earlier installs).
 func (g *Generator) List() ([]string, error) {
-	entries, err := os.ReadDir(g.config.ShimDir)
+	entries, err := os.ReadDir(g.shimDir)
 	if err != nil {
+		if os.IsNotExist(err) && g.scope == ScopeWorkspace {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to read shim directory: %w", err)
 	}
 
@@ -174,14 +259,29 @@ func isAlphanumeric(c byte) bool {
 
 // GetPath returns the full path to a shim.
 func (g *Generator) GetPath(binaryName string) string {
-	return filepath.Join(g.config.ShimDir, binaryName)
+	return filepath.Join(g.shimDir, binaryName)
+}
+
+// PathDirs returns the shim directories that belong on PATH, in lookup
+// order: the workspace shim directory (when inside a workspace and it
+// exists) followed by the global shim directory.
+func (g *Generator) PathDirs() []string {
+	dirs := make([]string, 0, 2)
+	if ws := g.config.WorkspaceShimDir; ws != "" {
+		if info, err := os.Stat(ws); err == nil && info.IsDir() {
+			dirs = append(dirs, ws)
+		}
+	}
+	return append(dirs, g.config.ShimDir)
 }
 
 // ValidateShimDir ensures the shim directory is in the user's PATH.
 func (g *Generator) ValidateShimDir() error {
-	path := os.Getenv("PATH")
-	if !strings.Contains(path, g.config.ShimDir) {
-		return fmt.Errorf("shim directory %s is not in PATH. Add:\n  export PATH=\"$HOME/.tuprwre/bin:$PATH\"", g.config.ShimDir)
+	shimDir := filepath.Clean(g.shimDir)
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if entry != "" && filepath.Clean(entry) == shimDir {
+			return nil
+		}
 	}
-	return nil
+	return fmt.Errorf("shim directory %s is not in PATH. Add:\n  eval \"$(tuprwre env)\"", g.shimDir)
 }
@@ -1,6 +1,7 @@
 package shim
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -377,3 +378,107 @@ func TestRemoveMetadata(t *testing.T) {
 		t.Fatal("expected error loading removed metadata")
 	}
 }
+
+func setupWorkspaceConfig(t *testing.T) *config.Config {
+	t.Helper()
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+
+	workspaceRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspaceRoot, ".tuprwre"), 0o755); err != nil {
+		t.Fatalf("create workspace dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspaceRoot, ".tuprwre", "config.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("write workspace config: %v", err)
+	}
+	t.Chdir(workspaceRoot)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+func TestNewScopedGenerator_WorkspacePaths(t *testing.T) {
+	cfg := setupWorkspaceConfig(t)
+
+	gen, err := NewScopedGenerator(cfg, ScopeWorkspace)
+	if err != nil {
+		t.Fatalf("NewScopedGenerator() failed: %v", err)
+	}
+
+	binary := discovery.Binary{Name: "mytool", Path: "/usr/local/bin/mytool"}
+	if err := gen.Create(binary, "toolset:latest", false); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if want := filepath.Join(cfg.WorkspaceDir, "bin", "mytool"); gen.GetPath("mytool") != want {
+		t.Fatalf("GetPath() = %q, want %q", gen.GetPath("mytool"), want)
+	}
+	if _, err := os.Stat(gen.GetPath("mytool")); err != nil {
+		t.Fatalf("workspace shim not written: %v", err)
+	}
+	if want := filepath.Join(cfg.WorkspaceDir, "metadata", "mytool.json"); gen.MetadataPath("mytool") != want {
+		t.Fatalf("MetadataPath() = %q, want %q", gen.MetadataPath("mytool"), want)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.ShimDir, "mytool")); !os.IsNotExist(err) {
+		t.Fatalf("expected no global shim, stat err=%v", err)
+	}
+	// Nothing is written into the repository itself.
+	if _, err := os.Stat(filepath.Join(cfg.WorkspaceRoot, ".tuprwre", "bin")); !os.IsNotExist(err) {
+		t.Fatalf("expected no shim directory in the workspace, stat err=%v", err)
+	}
+}
+
+func TestNewScopedGenerator_WorkspaceRequiresWorkspace(t *testing.T) {
+	gen, _ := setupTestGenerator(t)
+	if _, err := NewScopedGenerator(gen.config, ScopeWorkspace); err == nil {
+		t.Fatal("expected error outside a workspace")
+	}
+	if DefaultScope(gen.config) != ScopeGlobal {
+		t.Fatalf("DefaultScope() = %q, want %q", DefaultScope(gen.config), ScopeGlobal)
+	}
+}
+
+func TestResolve_PrefersWorkspaceShim(t *testing.T) {
+	cfg := setupWorkspaceConfig(t)
+	wsGen, err := NewScopedGenerator(cfg, ScopeWorkspace)
+	if err != nil {
+		t.Fatalf("NewScopedGenerator() failed: %v", err)
+	}
+	globalGen := NewGenerator(cfg)
+
+	binary := discovery.Binary{Name: "tool"}
+	for _, gen := range []*Generator{wsGen, globalGen} {
+		if err := gen.Create(binary, "img", false); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	if err := globalGen.Create(discovery.Binary{Name: "other"}, "img", false); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	got, err := Resolve(cfg, "tool")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if got.Scope() != ScopeWorkspace {
+		t.Fatalf("Resolve(tool) scope = %q, want workspace", got.Scope())
+	}
+
+	got, err = Resolve(cfg, "other")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if got.Scope() != ScopeGlobal {
+		t.Fatalf("Resolve(other) scope = %q, want global", got.Scope())
+	}
+
+	if _, err := Resolve(cfg, "missing"); !os.IsNotExist(errors.Unwrap(err)) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+
+	dirs := wsGen.PathDirs()
+	if len(dirs) != 2 || dirs[0] != cfg.WorkspaceShimDir || dirs[1] != cfg.ShimDir {
+		t.Fatalf("PathDirs() = %v", dirs)
+	}
+}
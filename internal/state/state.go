@@ -0,0 +1,121 @@
+// Package state keeps the per-shim home directories that persist tool
+// caches, configs and history between sandboxed runs.
+package state
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Home is where a shim's state directory is mounted inside the container,
+// and what HOME is set to.
+const Home = "/home/tuprwre"
+
+// Entry describes one shim's state directory.
+type Entry struct {
+	Shim     string    `json:"shim"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Path returns the state directory of shimName under stateDir.
+func Path(stateDir, shimName string) (string, error) {
+	if shimName == "" || shimName == "." || shimName == ".." || strings.ContainsAny(shimName, `/\`) {
+		return "", fmt.Errorf("invalid shim name %q", shimName)
+	}
+	return filepath.Join(stateDir, shimName), nil
+}
+
+// Ensure returns the state directory of shimName, creating it when needed.
+// It is private to the user: tools keep credentials and history there.
+func Ensure(stateDir, shimName string) (string, error) {
+	dir, err := Path(stateDir, shimName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return dir, nil
+}
+
+// List returns every shim's state directory under stateDir, sorted by shim
+// name, with its size.
+func List(stateDir string) ([]Entry, error) {
+	dirents, err := os.ReadDir(stateDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, d := range dirents {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := Stat(stateDir, d.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Shim < entries[j].Shim })
+	return entries, nil
+}
+
+// Stat describes the state directory of shimName. The error satisfies
+// os.IsNotExist when the shim has none.
+func Stat(stateDir, shimName string) (Entry, error) {
+	dir, err := Path(stateDir, shimName)
+	if err != nil {
+		return Entry{}, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Shim: shimName, Path: dir, Modified: info.ModTime()}
+
+	// Unreadable corners (a tool's 0700 cache owned by someone else) are
+	// skipped rather than failing the whole listing.
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			entry.Size += info.Size()
+		}
+		if info.ModTime().After(entry.Modified) {
+			entry.Modified = info.ModTime()
+		}
+		return nil
+	})
+	return entry, nil
+}
+
+// Remove deletes the state directory of shimName.
+func Remove(stateDir, shimName string) error {
+	dir, err := Path(stateDir, shimName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove state of %s: %w", shimName, err)
+	}
+	return nil
+}
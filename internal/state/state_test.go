@@ -0,0 +1,72 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureCreatesPrivateDirectory(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), "state")
+	dir, err := Ensure(stateDir, "npm")
+	if err != nil {
+		t.Fatalf("Ensure() failed: %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Fatalf("state dir mode = %o, want 700", perm)
+	}
+
+	for _, name := range []string{"", ".", "..", "../npm", `a\b`} {
+		if _, err := Ensure(stateDir, name); err == nil {
+			t.Errorf("Ensure(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestListStatRemove(t *testing.T) {
+	stateDir := t.TempDir()
+	for name, size := range map[string]int{"npm": 10, "gh": 3} {
+		dir, err := Ensure(stateDir, name)
+		if err != nil {
+			t.Fatalf("Ensure(%s): %v", name, err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, ".cache"), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".cache", "blob"), make([]byte, size), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "stray"), nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	entries, err := List(stateDir)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Shim != "gh" || entries[1].Shim != "npm" {
+		t.Fatalf("List() = %+v, want gh and npm", entries)
+	}
+	if entries[0].Size != 3 || entries[1].Size != 10 {
+		t.Fatalf("sizes = %d, %d; want 3, 10", entries[0].Size, entries[1].Size)
+	}
+
+	if err := Remove(stateDir, "npm"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := Stat(stateDir, "npm"); !os.IsNotExist(err) {
+		t.Fatalf("Stat() after Remove = %v, want not-exist", err)
+	}
+	if err := Remove(stateDir, "npm"); !os.IsNotExist(err) {
+		t.Fatalf("second Remove() = %v, want not-exist", err)
+	}
+
+	if entries, err := List(filepath.Join(stateDir, "missing")); err != nil || len(entries) != 0 {
+		t.Fatalf("List(missing) = %v, %v", entries, err)
+	}
+}
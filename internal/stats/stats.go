@@ -0,0 +1,225 @@
+// Package stats persists warm pool counters and per-image shim overhead
+// samples across tuprwre invocations.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// maxSamplesPerImage bounds the stats file; older samples are dropped first.
+const maxSamplesPerImage = 200
+
+// Run paths recorded in samples.
+const (
+	PathPool = "pool"
+	PathCold = "cold"
+)
+
+// Counters are cumulative warm pool outcomes.
+type Counters struct {
+	PoolHits   int64 `json:"pool_hits"`
+	PoolMisses int64 `json:"pool_misses"`
+	Evictions  int64 `json:"evictions"`
+	Fallbacks  int64 `json:"fallbacks"`
+}
+
+// Sample is one invocation's overhead: time from `tuprwre run` starting to
+// the command starting inside the container.
+type Sample struct {
+	Path       string    `json:"path"`
+	OverheadMs int64     `json:"overhead_ms"`
+	At         time.Time `json:"at"`
+}
+
+// File is the on-disk stats document.
+type File struct {
+	Counters
+	Images map[string][]Sample `json:"images,omitempty"`
+}
+
+// Run describes one finished invocation to fold into the stats file.
+type Run struct {
+	Image    string
+	Path     string
+	PoolHit  bool
+	PoolMiss bool
+	Evicted  int
+	Fallback bool
+	// Overhead is zero when the command never started.
+	Overhead time.Duration
+}
+
+// Record folds run into the stats file at path. Concurrent invocations are
+// serialized with a lock file next to it.
+func Record(path string, run Run) error {
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	if run.PoolHit {
+		f.PoolHits++
+	}
+	if run.PoolMiss {
+		f.PoolMisses++
+	}
+	if run.Fallback {
+		f.Fallbacks++
+	}
+	f.Evictions += int64(run.Evicted)
+
+	if run.Image != "" && run.Overhead > 0 {
+		if f.Images == nil {
+			f.Images = make(map[string][]Sample)
+		}
+		samples := append(f.Images[run.Image], Sample{
+			Path:       run.Path,
+			OverheadMs: run.Overhead.Milliseconds(),
+			At:         time.Now().UTC(),
+		})
+		if len(samples) > maxSamplesPerImage {
+			samples = samples[len(samples)-maxSamplesPerImage:]
+		}
+		f.Images[run.Image] = samples
+	}
+
+	return write(path, f)
+}
+
+// Load reads the stats file at path. A missing file yields empty stats.
+func Load(path string) (*File, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &File{}, nil
+		}
+		return nil, fmt.Errorf("failed to read stats file: %w", err)
+	}
+
+	var f File
+	if err := json.Unmarshal(payload, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse stats file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Reset removes the stats file at path.
+func Reset(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stats file: %w", err)
+	}
+	return nil
+}
+
+// ImageSummary aggregates the overhead samples of one image.
+type ImageSummary struct {
+	Image     string        `json:"image"`
+	Runs      int           `json:"runs"`
+	PoolRuns  int           `json:"pool_runs"`
+	ColdRuns  int           `json:"cold_runs"`
+	P50       time.Duration `json:"p50_ns"`
+	P95       time.Duration `json:"p95_ns"`
+	PoolP50   time.Duration `json:"pool_p50_ns,omitempty"`
+	ColdP50   time.Duration `json:"cold_p50_ns,omitempty"`
+	LastRunAt time.Time     `json:"last_run_at"`
+}
+
+// Summaries returns per-image overhead percentiles sorted by image name.
+func (f *File) Summaries() []ImageSummary {
+	out := make([]ImageSummary, 0, len(f.Images))
+	for image, samples := range f.Images {
+		if len(samples) == 0 {
+			continue
+		}
+		var all, pool, cold []time.Duration
+		summary := ImageSummary{Image: image, Runs: len(samples)}
+		for _, s := range samples {
+			d := time.Duration(s.OverheadMs) * time.Millisecond
+			all = append(all, d)
+			switch s.Path {
+			case PathPool:
+				pool = append(pool, d)
+			case PathCold:
+				cold = append(cold, d)
+			}
+			if s.At.After(summary.LastRunAt) {
+				summary.LastRunAt = s.At
+			}
+		}
+		summary.PoolRuns = len(pool)
+		summary.ColdRuns = len(cold)
+		summary.P50 = Percentile(all, 50)
+		summary.P95 = Percentile(all, 95)
+		summary.PoolP50 = Percentile(pool, 50)
+		summary.ColdP50 = Percentile(cold, 50)
+		out = append(out, summary)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Image < out[j].Image })
+	return out
+}
+
+// Percentile returns the nearest-rank p-th percentile of samples, or zero
+// for no samples.
+func Percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create stats directory: %w", err)
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to lock stats file: %w", err)
+	}
+	return func() { _ = f.Close() }, nil
+}
+
+func write(path string, f *File) error {
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
+		return fmt.Errorf("failed to write stats temp file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace stats file: %w", err)
+	}
+	return nil
+}
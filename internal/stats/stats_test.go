@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAccumulatesCountersAndSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+
+	runs := []Run{
+		{Image: "tuprwre-jq:latest", Path: PathPool, PoolMiss: true, Overhead: 400 * time.Millisecond},
+		{Image: "tuprwre-jq:latest", Path: PathPool, PoolHit: true, Overhead: 40 * time.Millisecond},
+		{Image: "tuprwre-jq:latest", Path: PathCold, Fallback: true, Evicted: 2, Overhead: 900 * time.Millisecond},
+		{Image: "tuprwre-node:latest", Path: PathPool, PoolHit: true},
+	}
+	for _, run := range runs {
+		if err := Record(path, run); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Counters{PoolHits: 2, PoolMisses: 1, Evictions: 2, Fallbacks: 1}
+	if f.Counters != want {
+		t.Fatalf("counters = %+v, want %+v", f.Counters, want)
+	}
+
+	summaries := f.Summaries()
+	if len(summaries) != 1 {
+		t.Fatalf("summaries = %+v, want only the image with overhead samples", summaries)
+	}
+	s := summaries[0]
+	if s.Runs != 3 || s.PoolRuns != 2 || s.ColdRuns != 1 {
+		t.Fatalf("run counts = %+v", s)
+	}
+	if s.P50 != 400*time.Millisecond || s.P95 != 900*time.Millisecond {
+		t.Fatalf("p50/p95 = %s/%s, want 400ms/900ms", s.P50, s.P95)
+	}
+}
+
+func TestRecordTrimsSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	seed := &File{Images: map[string][]Sample{"img": make([]Sample, maxSamplesPerImage)}}
+	if err := write(path, seed); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	if err := Record(path, Run{Image: "img", Path: PathPool, Overhead: 7 * time.Millisecond}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	samples := f.Images["img"]
+	if len(samples) != maxSamplesPerImage {
+		t.Fatalf("samples = %d, want %d", len(samples), maxSamplesPerImage)
+	}
+	if last := samples[len(samples)-1]; last.OverheadMs != 7 {
+		t.Fatalf("newest sample = %+v, want the recorded run", last)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	if got := Percentile(samples, 50); got != 50*time.Millisecond {
+		t.Fatalf("p50 = %s, want 50ms", got)
+	}
+	if got := Percentile(samples, 95); got != 95*time.Millisecond {
+		t.Fatalf("p95 = %s, want 95ms", got)
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Fatalf("empty p50 = %s, want 0", got)
+	}
+}
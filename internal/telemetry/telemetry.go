@@ -0,0 +1,138 @@
+// Package telemetry wires opt-in OpenTelemetry tracing for tuprwre.
+//
+// Tracing is enabled by the standard OTEL_EXPORTER_OTLP_ENDPOINT /
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables or the "tracing"
+// config block, and exports over OTLP/HTTP. When disabled, the global no-op
+// tracer is used and spans cost next to nothing.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/c4rb0nx1/tuprwre"
+
+// Span attribute keys shared by install and run spans.
+const (
+	AttrImage       = attribute.Key("tuprwre.image")
+	AttrBinary      = attribute.Key("tuprwre.binary")
+	AttrExitCode    = attribute.Key("tuprwre.exit_code")
+	AttrRunPath     = attribute.Key("tuprwre.run_path")
+	AttrPoolReused  = attribute.Key("tuprwre.pool.reused")
+	AttrContainerID = attribute.Key("tuprwre.container_id")
+	AttrBinaries    = attribute.Key("tuprwre.binaries")
+	AttrStepKey     = attribute.Key("tuprwre.step.key")
+	AttrStepCached  = attribute.Key("tuprwre.step.cached")
+)
+
+// Enabled reports whether tracing is configured through the environment or
+// cfg. OTEL_SDK_DISABLED=true always wins.
+func Enabled(cfg *config.Config) bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return true
+	}
+	return cfg != nil && cfg.Tracing.Endpoint != ""
+}
+
+// Setup installs an OTLP/HTTP tracer provider when tracing is enabled. The
+// returned shutdown flushes pending spans and must be called before exit.
+func Setup(ctx context.Context, cfg *config.Config, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if !Enabled(cfg) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the OTEL_EXPORTER_OTLP_* variables itself; the
+	// config block only applies when they are unset.
+	var opts []otlptracehttp.Option
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Tracing.Endpoint))
+		if len(cfg.Tracing.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Tracing.Headers))
+		}
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(
+		semconv.ServiceName("tuprwre"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		res = sdkresource.Default()
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tuprwre tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span with the tuprwre tracer.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// WithTraceParent returns ctx carrying the remote span context described by
+// a W3C traceparent (and optional tracestate) header value. ctx is returned
+// unchanged when traceparent is empty or invalid, or ctx already has a span.
+func WithTraceParent(ctx context.Context, traceparent, tracestate string) context.Context {
+	if traceparent == "" || trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	if tracestate != "" {
+		carrier["tracestate"] = tracestate
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// FromEnv applies the TRACEPARENT/TRACESTATE environment variables (as set
+// by agent frameworks) to ctx.
+func FromEnv(ctx context.Context) context.Context {
+	return WithTraceParent(ctx, os.Getenv("TRACEPARENT"), os.Getenv("TRACESTATE"))
+}
+
+// TraceParent renders the span context in ctx as a traceparent value, or ""
+// when there is none.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
@@ -0,0 +1,214 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is a minimal OTLP/HTTP trace receiver.
+type collector struct {
+	mu      sync.Mutex
+	spans   []*tracepb.Span
+	headers http.Header
+}
+
+func startCollector(t *testing.T) (*collector, string) {
+	t.Helper()
+
+	c := &collector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		c.headers = r.Header.Clone()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+		c.mu.Unlock()
+
+		payload, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv.URL
+}
+
+func (c *collector) spanNames() map[string]*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make(map[string]*tracepb.Span, len(c.spans))
+	for _, span := range c.spans {
+		names[span.Name] = span
+	}
+	return names
+}
+
+func clearOTELEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"OTEL_SDK_DISABLED",
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_HEADERS",
+		"TRACEPARENT",
+		"TRACESTATE",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func flush(t *testing.T, shutdown func(context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	clearOTELEnv(t)
+
+	if Enabled(&config.Config{}) {
+		t.Fatal("Enabled() = true without endpoint")
+	}
+	if !Enabled(&config.Config{Tracing: config.TracingConfig{Endpoint: "http://localhost:4318"}}) {
+		t.Fatal("Enabled() = false with config endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	if !Enabled(nil) {
+		t.Fatal("Enabled() = false with OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if Enabled(nil) {
+		t.Fatal("Enabled() = true with OTEL_SDK_DISABLED=true")
+	}
+}
+
+func TestSetup_ExportsSpansUnderTraceParent(t *testing.T) {
+	clearOTELEnv(t)
+	coll, endpoint := startCollector(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", endpoint)
+	t.Setenv("TRACEPARENT", "00-"+traceID+"-"+parentID+"-01")
+
+	shutdown, err := Setup(context.Background(), &config.Config{}, "test")
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+
+	ctx, root := Start(FromEnv(context.Background()), "run", AttrImage.String("alpine:3.20"), AttrBinary.String("jq"))
+	_, child := Start(ctx, "run.exec")
+	child.SetAttributes(AttrExitCode.Int(3))
+	End(child, nil)
+	End(root, nil)
+	flush(t, shutdown)
+
+	spans := coll.spanNames()
+	run, ok := spans["run"]
+	if !ok {
+		t.Fatalf("run span not exported; got %v", spans)
+	}
+	exec, ok := spans["run.exec"]
+	if !ok {
+		t.Fatalf("run.exec span not exported; got %v", spans)
+	}
+
+	if got := hex.EncodeToString(run.TraceId); got != traceID {
+		t.Fatalf("run trace ID = %s, want %s", got, traceID)
+	}
+	if got := hex.EncodeToString(run.ParentSpanId); got != parentID {
+		t.Fatalf("run parent span ID = %s, want %s", got, parentID)
+	}
+	if string(exec.ParentSpanId) != string(run.SpanId) {
+		t.Fatal("run.exec is not a child of run")
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range run.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["tuprwre.image"] != "alpine:3.20" || attrs["tuprwre.binary"] != "jq" {
+		t.Fatalf("run attributes = %v", attrs)
+	}
+	if got := exec.Attributes[0].Value.GetIntValue(); exec.Attributes[0].Key != "tuprwre.exit_code" || got != 3 {
+		t.Fatalf("run.exec attributes = %v", exec.Attributes)
+	}
+}
+
+func TestSetup_UsesConfigEndpointAndHeaders(t *testing.T) {
+	clearOTELEnv(t)
+	coll, endpoint := startCollector(t)
+
+	cfg := &config.Config{Tracing: config.TracingConfig{
+		Endpoint: endpoint,
+		Headers:  map[string]string{"x-tuprwre-token": "secret"},
+	}}
+	shutdown, err := Setup(context.Background(), cfg, "test")
+	if err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+
+	_, span := Start(context.Background(), "install")
+	End(span, nil)
+	flush(t, shutdown)
+
+	if _, ok := coll.spanNames()["install"]; !ok {
+		t.Fatal("install span not exported")
+	}
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+	if got := coll.headers.Get("x-tuprwre-token"); got != "secret" {
+		t.Fatalf("header = %q, want secret", got)
+	}
+}
+
+func TestWithTraceParent(t *testing.T) {
+	ctx := WithTraceParent(context.Background(), "not-a-traceparent", "")
+	if TraceParent(ctx) != "" {
+		t.Fatal("invalid traceparent should leave ctx without a span context")
+	}
+
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx = WithTraceParent(context.Background(), tp, "")
+	if got := TraceParent(ctx); got != tp {
+		t.Fatalf("TraceParent() = %q, want %q", got, tp)
+	}
+
+	// An existing span context is never replaced.
+	other := "00-11111111111111111111111111111111-2222222222222222-01"
+	if got := TraceParent(WithTraceParent(ctx, other, "")); got != tp {
+		t.Fatalf("TraceParent() = %q, want %q", got, tp)
+	}
+}
@@ -0,0 +1,272 @@
+// Package toolset bundles the shims generated from one image together with
+// the image itself, so an installed tool can move between machines.
+package toolset
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+	"github.com/distribution/reference"
+)
+
+// Archive entry names.
+const (
+	ManifestEntry   = "tuprwre.json"
+	DockerfileEntry = "Dockerfile"
+	ImageEntry      = "image.tar"
+)
+
+// ManifestVersion is the archive format version written by WriteArchive.
+const ManifestVersion = 1
+
+// Manifest describes an exported toolset.
+type Manifest struct {
+	Version int             `json:"version"`
+	Image   string          `json:"image"`
+	ImageID string          `json:"image_id,omitempty"`
+	Shims   []shim.Metadata `json:"shims"`
+}
+
+// Find collects the toolset name refers to: the image behind the shim called
+// name, or the image called name. The first scope that knows name wins.
+func Find(gens []*shim.Generator, name string) (*shim.Generator, Manifest, error) {
+	for _, gen := range gens {
+		all, err := gen.ListAllMetadata()
+		if err != nil {
+			return nil, Manifest{}, err
+		}
+
+		image := ""
+		for _, meta := range all {
+			if meta.BinaryName == name {
+				image = meta.OutputImage
+				break
+			}
+		}
+		if image == "" {
+			image = name
+		}
+
+		manifest := Manifest{Version: ManifestVersion, Image: image}
+		for _, meta := range all {
+			if meta.OutputImage == image {
+				manifest.Shims = append(manifest.Shims, meta)
+			}
+		}
+		if len(manifest.Shims) > 0 {
+			sort.Slice(manifest.Shims, func(i, j int) bool {
+				return manifest.Shims[i].BinaryName < manifest.Shims[j].BinaryName
+			})
+			return gen, manifest, nil
+		}
+	}
+	return nil, Manifest{}, fmt.Errorf("no shim or image named %q: %w", name, os.ErrNotExist)
+}
+
+// Dockerfile renders an image built from baseImage by running commands in
+// order, each with `sh -c` as in an install container.
+func Dockerfile(baseImage string, commands []string) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Reconstructed by tuprwre from install metadata.\n")
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	for _, command := range commands {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode([]string{"sh", "-c", command}); err != nil {
+			return "", fmt.Errorf("failed to encode command: %w", err)
+		}
+		fmt.Fprintf(&b, "RUN %s", buf.String())
+	}
+	return b.String(), nil
+}
+
+// WriteArchive writes m, dockerfile and the image tarball at imagePath to w
+// as a tar archive.
+func WriteArchive(w io.Writer, m Manifest, dockerfile, imagePath string) error {
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeEntry(tw, ManifestEntry, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, DockerfileEntry, int64(len(dockerfile)), strings.NewReader(dockerfile)); err != nil {
+		return err
+	}
+
+	image, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read image tarball: %w", err)
+	}
+	defer image.Close()
+	info, err := image.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read image tarball: %w", err)
+	}
+	if err := writeEntry(tw, ImageEntry, info.Size(), image); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadArchive reads an archive written by WriteArchive. The manifest is
+// validated before anything is loaded, and the image tarball is only handed
+// to loadImage once its tags are checked to be exactly the manifest's image,
+// so an archive cannot retag other images on load. It returns the manifest
+// and the Dockerfile.
+func ReadArchive(r io.Reader, loadImage func(io.Reader) error) (Manifest, string, error) {
+	var manifest Manifest
+	var dockerfile string
+	seen := map[string]bool{}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Manifest{}, "", fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		switch header.Name {
+		case ManifestEntry:
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return Manifest{}, "", fmt.Errorf("failed to decode manifest: %w", err)
+			}
+			if err := manifest.validate(); err != nil {
+				return Manifest{}, "", err
+			}
+		case DockerfileEntry:
+			payload, err := io.ReadAll(tr)
+			if err != nil {
+				return Manifest{}, "", fmt.Errorf("failed to read Dockerfile: %w", err)
+			}
+			dockerfile = string(payload)
+		case ImageEntry:
+			if !seen[ManifestEntry] {
+				return Manifest{}, "", fmt.Errorf("not a tuprwre toolset archive (%s must precede %s)", ManifestEntry, ImageEntry)
+			}
+			if err := loadCheckedImage(tr, manifest.Image, loadImage); err != nil {
+				return Manifest{}, "", err
+			}
+		default:
+			continue
+		}
+		seen[header.Name] = true
+	}
+
+	if !seen[ManifestEntry] || !seen[ImageEntry] {
+		return Manifest{}, "", fmt.Errorf("not a tuprwre toolset archive (missing %s or %s)", ManifestEntry, ImageEntry)
+	}
+	return manifest, dockerfile, nil
+}
+
+func (m Manifest) validate() error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("unsupported toolset archive version %d", m.Version)
+	}
+	if m.Image == "" || len(m.Shims) == 0 {
+		return fmt.Errorf("toolset archive manifest has no image or shims")
+	}
+	return nil
+}
+
+// loadCheckedImage spools the docker-save tarball in r to a temporary file,
+// checks that it tags nothing but image, and hands it to loadImage.
+func loadCheckedImage(r io.Reader, image string, loadImage func(io.Reader) error) error {
+	tmp, err := os.CreateTemp("", "tuprwre-image-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to spool image tarball: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, r); err != nil {
+		return fmt.Errorf("failed to spool image tarball: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read image tarball: %w", err)
+	}
+	tags, err := imageTags(tmp)
+	if err != nil {
+		return err
+	}
+	want, err := normalizeTag(image)
+	if err != nil {
+		return err
+	}
+	if len(tags) != 1 || tags[0] != want {
+		return fmt.Errorf("image tarball tags %v, manifest expects exactly [%s]", tags, want)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read image tarball: %w", err)
+	}
+	return loadImage(tmp)
+}
+
+// imageTags returns every RepoTag in the manifest.json of a docker-save
+// tarball, normalized.
+func imageTags(r io.Reader) ([]string, error) {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("image tarball has no manifest.json")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image tarball: %w", err)
+		}
+		if header.Name != "manifest.json" {
+			continue
+		}
+		var entries []struct {
+			RepoTags []string
+		}
+		if err := json.NewDecoder(tr).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("failed to decode image tarball manifest: %w", err)
+		}
+		var tags []string
+		for _, entry := range entries {
+			for _, tag := range entry.RepoTags {
+				normalized, err := normalizeTag(tag)
+				if err != nil {
+					return nil, err
+				}
+				tags = append(tags, normalized)
+			}
+		}
+		return tags, nil
+	}
+}
+
+// normalizeTag returns ref in its familiar form with an explicit tag, as
+// docker save writes RepoTags.
+func normalizeTag(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", ref, err)
+	}
+	return reference.FamiliarString(reference.TagNameOnly(named)), nil
+}
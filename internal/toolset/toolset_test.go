@@ -0,0 +1,177 @@
+package toolset
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/config"
+	"github.com/c4rb0nx1/tuprwre/internal/shim"
+)
+
+func seedGenerator(t *testing.T, metas ...shim.Metadata) *shim.Generator {
+	t.Helper()
+	t.Setenv("TUPRWRE_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	gen := shim.NewGenerator(cfg)
+	for _, meta := range metas {
+		if err := gen.SaveMetadata(meta); err != nil {
+			t.Fatalf("seed metadata: %v", err)
+		}
+	}
+	return gen
+}
+
+func TestFind(t *testing.T) {
+	gen := seedGenerator(t,
+		shim.Metadata{BinaryName: "node", OutputImage: "tuprwre-node:1"},
+		shim.Metadata{BinaryName: "npm", OutputImage: "tuprwre-node:1"},
+		shim.Metadata{BinaryName: "jq", OutputImage: "tuprwre-jq:1"},
+	)
+
+	_, byShim, err := Find([]*shim.Generator{gen}, "npm")
+	if err != nil {
+		t.Fatalf("Find(npm) failed: %v", err)
+	}
+	if byShim.Image != "tuprwre-node:1" || len(byShim.Shims) != 2 || byShim.Shims[0].BinaryName != "node" {
+		t.Fatalf("Find(npm) = %+v", byShim)
+	}
+
+	_, byImage, err := Find([]*shim.Generator{gen}, "tuprwre-jq:1")
+	if err != nil {
+		t.Fatalf("Find(image) failed: %v", err)
+	}
+	if len(byImage.Shims) != 1 || byImage.Shims[0].BinaryName != "jq" {
+		t.Fatalf("Find(image) = %+v", byImage)
+	}
+
+	if _, _, err := Find([]*shim.Generator{gen}, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Find(missing) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	got, err := Dockerfile("ubuntu:22.04", []string{"apt-get update", `echo "a && b" > /x`})
+	if err != nil {
+		t.Fatalf("Dockerfile() failed: %v", err)
+	}
+	want := "# Reconstructed by tuprwre from install metadata.\n" +
+		"FROM ubuntu:22.04\n" +
+		`RUN ["sh","-c","apt-get update"]` + "\n" +
+		`RUN ["sh","-c","echo \"a && b\" > /x"]` + "\n"
+	if got != want {
+		t.Fatalf("Dockerfile() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// writeImageTarball writes a docker-save style tarball tagged tags.
+func writeImageTarball(t *testing.T, tags ...string) string {
+	t.Helper()
+	manifest, err := json.Marshal([]map[string]any{{"Config": "config.json", "RepoTags": tags}})
+	if err != nil {
+		t.Fatalf("encode manifest.json: %v", err)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeEntry(tw, "manifest.json", int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		t.Fatalf("write manifest.json: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tarball: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+	return path
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	imagePath := writeImageTarball(t, "tuprwre-jq:1")
+	imageBytes, err := os.ReadFile(imagePath)
+	if err != nil {
+		t.Fatalf("read image: %v", err)
+	}
+
+	m := Manifest{
+		Version: ManifestVersion,
+		Image:   "tuprwre-jq:1",
+		ImageID: "sha256:abc",
+		Shims:   []shim.Metadata{{BinaryName: "jq", OutputImage: "tuprwre-jq:1"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, m, "FROM alpine\n", imagePath); err != nil {
+		t.Fatalf("WriteArchive() failed: %v", err)
+	}
+
+	var loaded []byte
+	got, dockerfile, err := ReadArchive(&buf, func(r io.Reader) error {
+		var err error
+		loaded, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadArchive() failed: %v", err)
+	}
+	if !bytes.Equal(loaded, imageBytes) {
+		t.Fatalf("loaded image = %q", loaded)
+	}
+	if dockerfile != "FROM alpine\n" || got.ImageID != "sha256:abc" || got.Shims[0].BinaryName != "jq" {
+		t.Fatalf("ReadArchive() = %+v, %q", got, dockerfile)
+	}
+}
+
+func TestReadArchiveRejectsForeignTar(t *testing.T) {
+	_, _, err := ReadArchive(strings.NewReader(""), func(io.Reader) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "not a tuprwre toolset archive") {
+		t.Fatalf("ReadArchive() error = %v", err)
+	}
+}
+
+func TestReadArchiveRejectsForeignTags(t *testing.T) {
+	m := Manifest{
+		Version: ManifestVersion,
+		Image:   "tuprwre-jq:1",
+		Shims:   []shim.Metadata{{BinaryName: "jq", OutputImage: "tuprwre-jq:1"}},
+	}
+	for _, tags := range [][]string{
+		{"tuprwre-jq:1", "alpine:3.20"},
+		{"alpine:3.20"},
+		nil,
+	} {
+		var buf bytes.Buffer
+		if err := WriteArchive(&buf, m, "", writeImageTarball(t, tags...)); err != nil {
+			t.Fatalf("WriteArchive() failed: %v", err)
+		}
+		loaded := false
+		_, _, err := ReadArchive(&buf, func(io.Reader) error {
+			loaded = true
+			return nil
+		})
+		if err == nil || loaded {
+			t.Fatalf("tags %v: ReadArchive() error = %v, loaded = %v; want a refusal before loading", tags, err, loaded)
+		}
+	}
+
+	// A bad manifest is refused before the image is loaded.
+	m.Version = ManifestVersion + 1
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, m, "", writeImageTarball(t, "tuprwre-jq:1")); err != nil {
+		t.Fatalf("WriteArchive() failed: %v", err)
+	}
+	if _, _, err := ReadArchive(&buf, func(io.Reader) error {
+		t.Fatal("image loaded for an unsupported manifest")
+		return nil
+	}); err == nil || !strings.Contains(err.Error(), "unsupported toolset archive version") {
+		t.Fatalf("ReadArchive() error = %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package vuln
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3BaseScore computes the base score of a CVSS v3.x vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". ok is false for vectors
+// it cannot read.
+func cvss3BaseScore(vector string) (score float64, ok bool) {
+	if !strings.HasPrefix(vector, "CVSS:3.") {
+		return 0, false
+	}
+	metrics := map[string]string{}
+	for _, field := range strings.Split(vector, "/")[1:] {
+		key, value, found := strings.Cut(field, ":")
+		if !found {
+			return 0, false
+		}
+		metrics[key] = value
+	}
+
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+	w := map[string]float64{}
+	for metric, values := range weights {
+		v, found := values[metrics[metric]]
+		if !found {
+			return 0, false
+		}
+		w[metric] = v
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, false
+	}
+	pr := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		pr = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	}
+	privileges, found := pr[metrics["PR"]]
+	if !found {
+		return 0, false
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * privileges * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp is the CVSS v3.1 Roundup function.
+func roundUp(x float64) float64 {
+	scaled := int(math.Round(x * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return (math.Floor(float64(scaled)/10000) + 1) / 10
+}
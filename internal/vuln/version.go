@@ -0,0 +1,248 @@
+package vuln
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareFunc orders two versions of one ecosystem: <0, 0 or >0.
+type compareFunc func(a, b string) int
+
+// comparator returns the version ordering for an OSV range. SEMVER ranges
+// always use semantic versioning; ECOSYSTEM ranges use the ecosystem's own
+// scheme.
+func comparator(ecosystem, rangeType string) compareFunc {
+	if rangeType == "SEMVER" {
+		return compareSemver
+	}
+	switch ecosystem {
+	case "Debian", "Ubuntu":
+		return compareDebian
+	case "Go", "npm":
+		return compareSemver
+	default:
+		// Alpine and PyPI: close enough for range checks without
+		// implementing apk and PEP 440 in full.
+		return compareNatural
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// compareSemver orders semantic versions. A leading "v" is ignored and
+// missing minor or patch components count as zero.
+func compareSemver(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	a, _, _ = strings.Cut(a, "+")
+	b, _, _ = strings.Cut(b, "+")
+	coreA, preA, _ := strings.Cut(a, "-")
+	coreB, preB, _ := strings.Cut(b, "-")
+
+	partsA, partsB := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < 3; i++ {
+		if c := compareNumeric(part(partsA, i), part(partsB, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	idsA, idsB := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(idsA) && i < len(idsB); i++ {
+		_, errA := strconv.Atoi(idsA[i])
+		_, errB := strconv.Atoi(idsB[i])
+		var c int
+		switch {
+		case errA == nil && errB == nil:
+			c = compareNumeric(idsA[i], idsB[i])
+		case errA == nil:
+			c = -1
+		case errB == nil:
+			c = 1
+		default:
+			c = strings.Compare(idsA[i], idsB[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return sign(len(idsA) - len(idsB))
+}
+
+func part(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareNumeric compares decimal strings of any length.
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// preReleaseMarkers sort before the release they are attached to, e.g.
+// 1.0rc1 < 1.0 and 1.2_beta2 < 1.2.
+var preReleaseMarkers = map[string]bool{
+	"a": true, "alpha": true, "b": true, "beta": true, "c": true,
+	"rc": true, "pre": true, "preview": true, "dev": true,
+}
+
+// compareNatural compares versions as runs of digits and letters,
+// numerically where both runs are numbers.
+func compareNatural(a, b string) int {
+	tokensA, tokensB := tokenize(a), tokenize(b)
+	for i := 0; i < len(tokensA) && i < len(tokensB); i++ {
+		x, y := tokensA[i], tokensB[i]
+		xNum, yNum := isDigit(x[0]), isDigit(y[0])
+		var c int
+		switch {
+		case xNum && yNum:
+			c = compareNumeric(x, y)
+		case xNum:
+			c = 1 // 1.0.1 > 1.0rc1, 1.0.1 > 1.0-r1
+		case yNum:
+			c = -1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(tokensA) > len(tokensB):
+		if preReleaseMarkers[tokensA[len(tokensB)]] {
+			return -1
+		}
+		return 1
+	case len(tokensB) > len(tokensA):
+		if preReleaseMarkers[tokensB[len(tokensA)]] {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+func tokenize(version string) []string {
+	var tokens []string
+	start := -1
+	for i := 0; i <= len(version); i++ {
+		boundary := i == len(version) || !isAlnum(version[i]) ||
+			(start >= 0 && isDigit(version[i]) != isDigit(version[start]))
+		if boundary && start >= 0 {
+			tokens = append(tokens, strings.ToLower(version[start:i]))
+			start = -1
+		}
+		if i < len(version) && isAlnum(version[i]) && start < 0 {
+			start = i
+		}
+	}
+	return tokens
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func isAlnum(c byte) bool { return isDigit(c) || isAlpha(c) }
+
+// compareDebian implements dpkg's version ordering:
+// [epoch:]upstream[-revision], where "~" sorts before anything.
+func compareDebian(a, b string) int {
+	epochA, upstreamA, revisionA := splitDebian(a)
+	epochB, upstreamB, revisionB := splitDebian(b)
+	if c := compareNumeric(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := verrevcmp(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return verrevcmp(revisionA, revisionB)
+}
+
+func splitDebian(version string) (epoch, upstream, revision string) {
+	epoch = "0"
+	if e, rest, ok := strings.Cut(version, ":"); ok {
+		epoch, version = e, rest
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		return epoch, version[:i], version[i+1:]
+	}
+	return epoch, version, ""
+}
+
+func debianOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			if c := debianOrder(a, i) - debianOrder(b, j); c != 0 {
+				return sign(c)
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
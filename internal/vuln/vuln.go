@@ -0,0 +1,490 @@
+// Package vuln matches SBOM packages against a local database of OSV
+// advisories, so audits work without network access.
+package vuln
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+)
+
+// DirName is the advisory database's directory under the tuprwre base
+// directory.
+const DirName = "advisories"
+
+// Severity ranks how bad an advisory is. SeverityUnknown sorts lowest.
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"unknown", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// MarshalJSON renders the severity by name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// ParseSeverity reads a severity name as used by --fail-on and OSV
+// database_specific fields ("moderate" is medium).
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return SeverityLow, nil
+	case "medium", "moderate":
+		return SeverityMedium, nil
+	case "high", "important":
+		return SeverityHigh, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q (expected low, medium, high or critical)", name)
+}
+
+func severityFromScore(score float64) Severity {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// Advisory is the subset of the OSV schema used for matching.
+type Advisory struct {
+	ID               string          `json:"id"`
+	Aliases          []string        `json:"aliases,omitempty"`
+	Summary          string          `json:"summary,omitempty"`
+	Withdrawn        string          `json:"withdrawn,omitempty"`
+	Severity         []osvSeverity   `json:"severity,omitempty"`
+	Affected         []affected      `json:"affected"`
+	DatabaseSpecific json.RawMessage `json:"database_specific,omitempty"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges            []affectedRange `json:"ranges,omitempty"`
+	Versions          []string        `json:"versions,omitempty"`
+	Severity          []osvSeverity   `json:"severity,omitempty"`
+	EcosystemSpecific json.RawMessage `json:"ecosystem_specific,omitempty"`
+}
+
+type affectedRange struct {
+	Type   string  `json:"type"`
+	Events []event `json:"events"`
+}
+
+type event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// Finding is a package affected by an advisory.
+type Finding struct {
+	Package  sbom.Package `json:"package"`
+	ID       string       `json:"id"`
+	Aliases  []string     `json:"aliases,omitempty"`
+	Summary  string       `json:"summary,omitempty"`
+	Severity Severity     `json:"severity"`
+	Fixed    string       `json:"fixed,omitempty"`
+}
+
+// DB is an advisory database loaded into memory.
+type DB struct {
+	// byPackage indexes advisories by "<ecosystem>/<normalized name>".
+	byPackage map[string][]*Advisory
+	count     int
+}
+
+// Len returns the number of advisories loaded.
+func (db *DB) Len() int { return db.count }
+
+// Open loads every advisory in dir. A missing directory is an empty
+// database.
+func Open(dir string) (*DB, error) {
+	db := &DB{byPackage: map[string][]*Advisory{}}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory database: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		payload, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read advisory %s: %w", entry.Name(), err)
+		}
+		var adv Advisory
+		if err := json.Unmarshal(payload, &adv); err != nil {
+			return nil, fmt.Errorf("failed to parse advisory %s: %w", entry.Name(), err)
+		}
+		db.add(&adv)
+	}
+	return db, nil
+}
+
+func (db *DB) add(adv *Advisory) {
+	if adv.Withdrawn != "" {
+		return
+	}
+	db.count++
+	seen := map[string]bool{}
+	for _, a := range adv.Affected {
+		key := indexKey(ecosystemBase(a.Package.Ecosystem), a.Package.Name)
+		if !seen[key] {
+			seen[key] = true
+			db.byPackage[key] = append(db.byPackage[key], adv)
+		}
+	}
+}
+
+// ecosystemBase drops the release suffix: "Ubuntu:22.04:LTS" -> "Ubuntu".
+func ecosystemBase(ecosystem string) string {
+	base, _, _ := strings.Cut(ecosystem, ":")
+	return base
+}
+
+// osvRelease maps an os-release VERSION_ID to the release OSV names in
+// ecosystem suffixes: "12" for Debian:12, "v3.18" for Alpine:v3.18 and
+// "22.04" for Ubuntu:22.04:LTS. It returns "" when the release is unknown.
+func osvRelease(ecosystem, versionID string) string {
+	if versionID == "" {
+		return ""
+	}
+	switch ecosystem {
+	case "Debian":
+		major, _, _ := strings.Cut(versionID, ".")
+		return major
+	case "Alpine":
+		parts := strings.SplitN(versionID, ".", 3)
+		if len(parts) < 2 {
+			return ""
+		}
+		return "v" + parts[0] + "." + parts[1]
+	case "Ubuntu":
+		return versionID
+	}
+	return ""
+}
+
+// inRelease reports whether an affected entry's ecosystem applies to
+// release. Entries without a release suffix apply to every release, and
+// every entry applies when the image's release is unknown.
+func inRelease(ecosystem, release string) bool {
+	_, suffix, ok := strings.Cut(ecosystem, ":")
+	if !ok || release == "" {
+		return true
+	}
+	// Ubuntu suffixes carry qualifiers: "Pro:22.04:LTS".
+	return slices.Contains(strings.Split(suffix, ":"), release)
+}
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+func indexKey(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		name = pypiSeparators.ReplaceAllString(strings.ToLower(name), "-")
+	}
+	return ecosystem + "/" + name
+}
+
+// packageEcosystem maps an SBOM package to its OSV ecosystem, name and
+// version.
+func packageEcosystem(p sbom.Package, distro string) (ecosystem, name, version string) {
+	name, version = p.Name, p.Version
+	if p.Source != "" {
+		name = p.Source
+	}
+	switch p.Type {
+	case sbom.TypeDeb:
+		if distro == "ubuntu" {
+			return "Ubuntu", name, version
+		}
+		return "Debian", name, version
+	case sbom.TypeAPK:
+		return "Alpine", name, version
+	case sbom.TypePyPI:
+		return "PyPI", name, version
+	case sbom.TypeNPM:
+		return "npm", name, version
+	case sbom.TypeGolang:
+		// OSV Go versions carry no "v" or "go" prefix.
+		version = strings.TrimPrefix(version, "v")
+		if name == "stdlib" {
+			version = strings.TrimPrefix(version, "go")
+		}
+		return "Go", name, version
+	}
+	return "", "", ""
+}
+
+// Match returns the findings for every package in s, most severe first.
+// Distro advisories are matched against the image's release only, e.g.
+// Debian:12 entries for a Debian 12 image.
+func (db *DB) Match(s sbom.SBOM) []Finding {
+	var findings []Finding
+	seen := map[string]bool{}
+	for _, p := range s.Packages {
+		ecosystem, name, version := packageEcosystem(p, s.Distro)
+		if ecosystem == "" || version == "" || version == "(devel)" {
+			continue
+		}
+		release := osvRelease(ecosystem, s.DistroVersion)
+		for _, adv := range db.byPackage[indexKey(ecosystem, name)] {
+			for _, a := range adv.Affected {
+				if ecosystemBase(a.Package.Ecosystem) != ecosystem || indexKey(ecosystem, a.Package.Name) != indexKey(ecosystem, name) ||
+					!inRelease(a.Package.Ecosystem, release) {
+					continue
+				}
+				isAffected, fixed := a.affects(ecosystem, version)
+				if !isAffected {
+					continue
+				}
+				key := adv.ID + "\x00" + p.Type + "\x00" + p.Name + "\x00" + p.Version
+				if seen[key] {
+					break
+				}
+				seen[key] = true
+				findings = append(findings, Finding{
+					Package:  p,
+					ID:       adv.ID,
+					Aliases:  adv.Aliases,
+					Summary:  adv.Summary,
+					Severity: adv.severity(a),
+					Fixed:    fixed,
+				})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		if findings[i].Package.Name != findings[j].Package.Name {
+			return findings[i].Package.Name < findings[j].Package.Name
+		}
+		return findings[i].ID < findings[j].ID
+	})
+	return findings
+}
+
+// affects reports whether version falls in a, and the version that fixes
+// it when the advisory names one.
+func (a affected) affects(ecosystem, version string) (bool, string) {
+	for _, v := range a.Versions {
+		if v == version {
+			return true, ""
+		}
+	}
+
+	for _, r := range a.Ranges {
+		if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+			continue // GIT ranges need commit history
+		}
+		compare := comparator(ecosystem, r.Type)
+
+		// Events are evaluated in version order, "0" being the lowest.
+		events := append([]event(nil), r.Events...)
+		sort.SliceStable(events, func(i, j int) bool {
+			return compareEvent(compare, events[i], events[j]) < 0
+		})
+
+		isAffected, fixed := false, ""
+		for _, e := range events {
+			switch {
+			case e.Introduced != "":
+				if e.Introduced == "0" || compare(version, e.Introduced) >= 0 {
+					isAffected, fixed = true, ""
+				}
+			case e.Fixed != "":
+				if compare(version, e.Fixed) >= 0 {
+					isAffected = false
+				} else if isAffected && fixed == "" {
+					fixed = e.Fixed
+				}
+			case e.LastAffected != "":
+				if compare(version, e.LastAffected) > 0 {
+					isAffected = false
+				}
+			case e.Limit != "":
+				if compare(version, e.Limit) >= 0 {
+					isAffected = false
+				}
+			}
+		}
+		if isAffected {
+			return true, fixed
+		}
+	}
+	return false, ""
+}
+
+func eventVersion(e event) string {
+	for _, v := range []string{e.Introduced, e.Fixed, e.LastAffected, e.Limit} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func compareEvent(compare compareFunc, a, b event) int {
+	va, vb := eventVersion(a), eventVersion(b)
+	switch {
+	case va == vb:
+		return 0
+	case a.Introduced == "0":
+		return -1
+	case b.Introduced == "0":
+		return 1
+	}
+	return compare(va, vb)
+}
+
+// severity picks the most specific severity the advisory states for a:
+// an ecosystem-specific rating, then the database's, then the highest CVSS
+// v3 score.
+func (adv *Advisory) severity(a affected) Severity {
+	for _, raw := range []json.RawMessage{a.EcosystemSpecific, adv.DatabaseSpecific} {
+		var specific struct {
+			Severity string `json:"severity"`
+		}
+		if len(raw) > 0 && json.Unmarshal(raw, &specific) == nil {
+			if s, err := ParseSeverity(specific.Severity); err == nil {
+				return s
+			}
+		}
+	}
+
+	best := SeverityUnknown
+	for _, sev := range append(append([]osvSeverity(nil), a.Severity...), adv.Severity...) {
+		var s Severity
+		if score, ok := cvss3BaseScore(sev.Score); ok {
+			s = severityFromScore(score)
+		} else if parsed, err := ParseSeverity(sev.Score); err == nil {
+			s = parsed // e.g. Ubuntu's {"type": "Ubuntu", "score": "medium"}
+		}
+		if s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Import copies the advisories in path into dir. path may hold one OSV
+// advisory, a JSON array of them, or be a zip archive of advisory files as
+// published by osv.dev. It returns the number imported.
+func Import(dir, path string) (int, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var docs []json.RawMessage
+	if bytes.HasPrefix(payload, []byte("PK")) {
+		docs, err = zipAdvisories(payload)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		trimmed := bytes.TrimSpace(payload)
+		if bytes.HasPrefix(trimmed, []byte("[")) {
+			if err := json.Unmarshal(trimmed, &docs); err != nil {
+				return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+		} else {
+			docs = []json.RawMessage{trimmed}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create advisory database: %w", err)
+	}
+	imported := 0
+	for _, doc := range docs {
+		var adv Advisory
+		if err := json.Unmarshal(doc, &adv); err != nil {
+			return imported, fmt.Errorf("failed to parse advisory: %w", err)
+		}
+		if adv.ID == "" {
+			return imported, fmt.Errorf("advisory without an id in %s", path)
+		}
+		name := unsafeFileChars.ReplaceAllString(adv.ID, "_") + ".json"
+		if err := os.WriteFile(filepath.Join(dir, name), doc, 0o644); err != nil {
+			return imported, fmt.Errorf("failed to store advisory %s: %w", adv.ID, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+func zipAdvisories(payload []byte) ([]json.RawMessage, error) {
+	zr, err := zip.NewReader(bytes.NewReader(payload), int64(len(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open advisory archive: %w", err)
+	}
+
+	var docs []json.RawMessage
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || filepath.Ext(f.Name) != ".json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		doc, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
@@ -0,0 +1,229 @@
+package vuln
+
+import (
+	"archive/zip"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c4rb0nx1/tuprwre/internal/sbom"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		compare compareFunc
+		a, b    string
+		want    int
+	}{
+		{compareDebian, "1.6-2.1", "1.6-2.1", 0},
+		{compareDebian, "1.6~rc1-1", "1.6-1", -1},
+		{compareDebian, "1:1.0-1", "2.0-1", 1},
+		{compareDebian, "3.0.2-0ubuntu1.10", "3.0.2-0ubuntu1.9", 1},
+		{compareDebian, "1.0+dfsg-1", "1.0-1", 1},
+		{compareSemver, "v1.2.3", "1.2.3", 0},
+		{compareSemver, "1.2.3-rc.1", "1.2.3", -1},
+		{compareSemver, "1.10.0", "1.9.9", 1},
+		{compareSemver, "1.2", "1.2.0", 0},
+		{compareNatural, "1.36.1-r15", "1.36.1-r2", 1},
+		{compareNatural, "2.0.0rc1", "2.0.0", -1},
+		{compareNatural, "1.0.1", "1.0-r1", 1},
+	}
+	for _, tt := range tests {
+		if got := sign(tt.compare(tt.a, tt.b)); got != tt.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(tt.compare(tt.b, tt.a)); got != -tt.want {
+			t.Errorf("compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	tests := map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10.0,
+		"CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N": 5.5,
+		"CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N": 3.1,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N": 0,
+	}
+	for vector, want := range tests {
+		got, ok := cvss3BaseScore(vector)
+		if !ok || math.Abs(got-want) > 1e-9 {
+			t.Errorf("cvss3BaseScore(%q) = %v, %v; want %v", vector, got, ok, want)
+		}
+	}
+	if _, ok := cvss3BaseScore("AV:N/AC:L/Au:N/C:P/I:P/A:P"); ok {
+		t.Fatal("CVSS v2 vector was scored")
+	}
+}
+
+const (
+	jqAdvisory = `{
+  "id": "DEBIAN-CVE-2023-0001",
+  "aliases": ["CVE-2023-0001"],
+  "summary": "jq heap overflow",
+  "affected": [{
+    "package": {"ecosystem": "Debian:12", "name": "jq"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-2.2"}]}],
+    "ecosystem_specific": {"severity": "high"}
+  }]
+}`
+	requestsAdvisory = `{
+  "id": "GHSA-aaaa-bbbb-cccc",
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "Requests"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "2.0.0"}, {"fixed": "2.31.0"}]}]
+  }]
+}`
+	opensslAdvisory = `{
+  "id": "ALPINE-CVE-2024-0002",
+  "affected": [{
+    "package": {"ecosystem": "Alpine:v3.19", "name": "openssl"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"last_affected": "3.1.4-r4"}]}]
+  }],
+  "database_specific": {"severity": "moderate"}
+}`
+	withdrawnAdvisory = `{"id": "GO-2024-0003", "withdrawn": "2024-02-01T00:00:00Z",
+  "affected": [{"package": {"ecosystem": "Go", "name": "stdlib"}, "versions": ["1.21.0"]}]}`
+)
+
+func TestImportAndMatch(t *testing.T) {
+	src := t.TempDir()
+	arrayPath := filepath.Join(src, "advisories.json")
+	if err := os.WriteFile(arrayPath, []byte("["+jqAdvisory+","+requestsAdvisory+"]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(src, "all.zip")
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	for name, doc := range map[string]string{"ALPINE-CVE-2024-0002.json": opensslAdvisory, "GO-2024-0003.json": withdrawnAdvisory} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), DirName)
+	for path, want := range map[string]int{arrayPath: 2, zipPath: 2} {
+		n, err := Import(dir, path)
+		if err != nil {
+			t.Fatalf("Import(%s): %v", path, err)
+		}
+		if n != want {
+			t.Fatalf("Import(%s) = %d, want %d", path, n, want)
+		}
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if db.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3 (withdrawn advisories skipped)", db.Len())
+	}
+
+	findings := db.Match(sbom.SBOM{
+		Distro: "debian",
+		Packages: []sbom.Package{
+			{Type: sbom.TypeDeb, Name: "jq", Version: "1.6-2.1"},
+			{Type: sbom.TypeDeb, Name: "libssl3", Version: "3.1.4-r1", Source: "openssl"},
+			{Type: sbom.TypePyPI, Name: "requests", Version: "2.28.1"},
+			{Type: sbom.TypePyPI, Name: "requests", Version: "2.31.0"},
+			{Type: sbom.TypeAPK, Name: "libcrypto3", Version: "3.1.4-r1", Source: "openssl"},
+			{Type: sbom.TypeAPK, Name: "libssl3", Version: "3.1.4-r5", Source: "openssl"},
+			{Type: sbom.TypeGolang, Name: "stdlib", Version: "go1.21.0"},
+		},
+	})
+
+	type result struct {
+		name, id string
+		severity Severity
+		fixed    string
+	}
+	want := []result{
+		{"requests", "GHSA-aaaa-bbbb-cccc", SeverityCritical, "2.31.0"},
+		{"jq", "DEBIAN-CVE-2023-0001", SeverityHigh, "1.6-2.2"},
+		{"libcrypto3", "ALPINE-CVE-2024-0002", SeverityMedium, ""},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("Match() = %+v, want %d findings", findings, len(want))
+	}
+	for i, f := range findings {
+		got := result{f.Package.Name, f.ID, f.Severity, f.Fixed}
+		if got != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestMatchRelease(t *testing.T) {
+	dir := t.TempDir()
+	for name, doc := range map[string]string{
+		"jq.json": `{"id": "DEBIAN-CVE-2024-0004", "affected": [
+  {"package": {"ecosystem": "Debian:11", "name": "jq"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}]}]},
+  {"package": {"ecosystem": "Debian:12", "name": "jq"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-2.1"}]}]}]}`,
+		"openssl.json": opensslAdvisory,
+		"curl.json": `{"id": "UBUNTU-CVE-2024-0005", "affected": [
+  {"package": {"ecosystem": "Ubuntu:Pro:22.04:LTS", "name": "curl"}, "versions": ["7.81.0-1ubuntu1.15"]}]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	for _, tc := range []struct {
+		distro, version string
+		pkg             sbom.Package
+		want            int
+	}{
+		{"debian", "12", sbom.Package{Type: sbom.TypeDeb, Name: "jq", Version: "1.6-2.1"}, 0},
+		{"debian", "11", sbom.Package{Type: sbom.TypeDeb, Name: "jq", Version: "1.6-2.1"}, 1},
+		{"debian", "", sbom.Package{Type: sbom.TypeDeb, Name: "jq", Version: "1.6-2.1"}, 1},
+		{"alpine", "3.19.1", sbom.Package{Type: sbom.TypeAPK, Name: "openssl", Version: "3.1.4-r1"}, 1},
+		{"alpine", "3.18.4", sbom.Package{Type: sbom.TypeAPK, Name: "openssl", Version: "3.1.4-r1"}, 0},
+		{"ubuntu", "22.04", sbom.Package{Type: sbom.TypeDeb, Name: "curl", Version: "7.81.0-1ubuntu1.15"}, 1},
+		{"ubuntu", "24.04", sbom.Package{Type: sbom.TypeDeb, Name: "curl", Version: "7.81.0-1ubuntu1.15"}, 0},
+	} {
+		findings := db.Match(sbom.SBOM{Distro: tc.distro, DistroVersion: tc.version, Packages: []sbom.Package{tc.pkg}})
+		if len(findings) != tc.want {
+			t.Errorf("Match(%s %s, %s) = %+v, want %d findings", tc.distro, tc.version, tc.pkg.Name, findings, tc.want)
+		}
+	}
+}
+
+func TestOpenMissingDirectory(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if db.Len() != 0 || len(db.Match(sbom.SBOM{Packages: []sbom.Package{{Type: sbom.TypeNPM, Name: "x", Version: "1"}}})) != 0 {
+		t.Fatal("empty database reported advisories")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("Moderate"); err != nil || s != SeverityMedium {
+		t.Fatalf("ParseSeverity(Moderate) = %v, %v", s, err)
+	}
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Fatal("expected an error for an unknown severity")
+	}
+}
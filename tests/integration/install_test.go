@@ -45,3 +45,95 @@ func TestInstallAndShimExecution(t *testing.T) {
 		t.Fatalf("unexpected jq --version output: %q", string(jqOut))
 	}
 }
+
+func TestInstallRecordsSBOM(t *testing.T) {
+	_, env := setupTest(t)
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"install", "--base-image", testImage, "--", "apk add --no-cache jq",
+	)
+	if exitCode != 0 {
+		t.Fatalf("install failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	for format, want := range map[string]string{"cyclonedx": `"bomFormat": "CycloneDX"`, "spdx": `"spdxVersion": "SPDX-2.3"`} {
+		stdout, stderr, exitCode = runBinary(t, env, "sbom", "jq", "--format", format)
+		if exitCode != 0 {
+			t.Fatalf("sbom --format %s failed (exit %d):\nstdout: %s\nstderr: %s", format, exitCode, stdout, stderr)
+		}
+		if !strings.Contains(stdout, want) || !strings.Contains(stdout, "pkg:apk/alpine/jq@") {
+			t.Fatalf("sbom --format %s is missing jq:\n%s", format, stdout)
+		}
+	}
+}
+
+func TestInstallPolicy(t *testing.T) {
+	tuprwreDir, env := setupTest(t)
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"install", "--base-image", testImage, "--egress", "none", "--",
+		"wget -q -O /dev/null http://example.com",
+	)
+	if exitCode == 0 {
+		t.Fatalf("expected --egress none to block the network:\nstdout: %s\nstderr: %s", stdout, stderr)
+	}
+
+	stdout, stderr, exitCode = runBinary(t, env,
+		"install", "--base-image", testImage, "--timeout", "2s", "--", "sleep 60",
+	)
+	if exitCode == 0 || !strings.Contains(stdout+stderr, "timed out after 2s") {
+		t.Fatalf("expected --timeout to kill the install (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	stdout, stderr, exitCode = runBinary(t, env,
+		"install", "--base-image", testImage,
+		"--egress", "none", "--cap-drop", "ALL", "--no-new-privileges", "--pids-limit", "64",
+		"--", `printf '#!/bin/sh\necho hardened\n' > /usr/local/bin/hardened-tool && chmod +x /usr/local/bin/hardened-tool`,
+	)
+	if exitCode != 0 {
+		t.Fatalf("hardened install failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	metadata, err := os.ReadFile(filepath.Join(tuprwreDir, "metadata", "hardened-tool.json"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	for _, want := range []string{`"install_policy"`, `"egress": "none"`, `"ALL"`, `"pids_limit": 64`} {
+		if !strings.Contains(string(metadata), want) {
+			t.Fatalf("metadata missing %s:\n%s", want, metadata)
+		}
+	}
+}
+
+func TestInstallSecrets(t *testing.T) {
+	tuprwreDir, env := setupTest(t)
+	secretFile := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(secretFile, []byte("machine example.com password file-secret-123\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	env = append(env, "TUPRWRE_TEST_TOKEN=env-secret-456")
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"install", "--base-image", testImage,
+		"--secret", "id=netrc,src="+secretFile, "--secret", "id=TOKEN,env=TUPRWRE_TEST_TOKEN",
+		"--", `grep -q file-secret-123 /run/secrets/netrc && [ "$TOKEN" = env-secret-456 ] && printf '#!/bin/sh\necho ok\n' > /usr/local/bin/secret-tool && chmod +x /usr/local/bin/secret-tool`,
+	)
+	if exitCode != 0 {
+		t.Fatalf("install with secrets failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+	metadata, err := os.ReadFile(filepath.Join(tuprwreDir, "metadata", "secret-tool.json"))
+	if err != nil {
+		t.Fatalf("read metadata: %v", err)
+	}
+	if strings.Contains(string(metadata), "secret-123") || strings.Contains(string(metadata), "secret-456") || !strings.Contains(string(metadata), `"TOKEN"`) {
+		t.Fatalf("metadata must record secret IDs only:\n%s", metadata)
+	}
+
+	stdout, stderr, exitCode = runBinary(t, env,
+		"install", "--base-image", testImage, "--secret", "id=TOKEN,env=TUPRWRE_TEST_TOKEN",
+		"--", `echo "$TOKEN" > /root/.token && printf '#!/bin/sh\n' > /usr/local/bin/leaky-tool && chmod +x /usr/local/bin/leaky-tool`,
+	)
+	if exitCode == 0 || !strings.Contains(stdout+stderr, "refusing to commit") {
+		t.Fatalf("expected a leaked secret to stop the install (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build integration
+
+package integration
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startRegistry runs a throwaway registry:2 container and returns its
+// localhost address.
+func startRegistry(t *testing.T) string {
+	t.Helper()
+
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::5000", "registry:2").Output()
+	if err != nil {
+		t.Skipf("cannot start registry:2 container: %v", err)
+	}
+	containerID := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "rm", "-f", containerID).Run()
+	})
+
+	out, err = exec.Command("docker", "port", containerID, "5000/tcp").Output()
+	if err != nil {
+		t.Fatalf("failed to read registry port: %v", err)
+	}
+	hostPort := strings.Fields(string(out))[0]
+	_, port, _ := strings.Cut(hostPort, ":")
+	return "localhost:" + port
+}
+
+func TestPublishAndInstallFromRegistry(t *testing.T) {
+	registry := startRegistry(t)
+	ref := registry + "/tuprwre/jq:test"
+
+	_, publisherEnv := setupTest(t)
+	stdout, stderr, exitCode := runBinary(t, publisherEnv,
+		"install", "--base-image", testImage, "--", "apk add --no-cache jq",
+	)
+	if exitCode != 0 {
+		t.Fatalf("install failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	stdout, stderr, exitCode = runBinary(t, publisherEnv, "publish", "jq", "--to", ref)
+	if exitCode != 0 {
+		t.Fatalf("publish failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "rmi", "-f", ref).Run()
+	})
+	if !strings.Contains(stdout, "Digest: sha256:") {
+		t.Fatalf("publish did not report a digest:\n%s", stdout)
+	}
+	// Tags are only signed after confirmation, so install the pinned
+	// reference publish prints.
+	_, pinned, found := strings.Cut(stdout, "Install with: tuprwre install --from ")
+	if !found {
+		t.Fatalf("publish did not print a pinned install command:\n%s", stdout)
+	}
+	pinned = strings.TrimSpace(pinned)
+
+	// A second, empty tuprwre home stands in for another machine.
+	consumerDir, consumerEnv := setupTest(t)
+	stdout, stderr, exitCode = runBinary(t, consumerEnv, "install", "--from", pinned)
+	if exitCode != 0 {
+		t.Fatalf("install --from failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	jqCmd := exec.Command(filepath.Join(consumerDir, "bin", "jq"), "--version")
+	jqCmd.Env = consumerEnv
+	jqOut, err := jqCmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("jq --version via shim failed: %v\noutput: %s", err, string(jqOut))
+	}
+	if !strings.Contains(string(jqOut), "jq-") {
+		t.Fatalf("unexpected jq --version output: %q", string(jqOut))
+	}
+}
@@ -3,6 +3,10 @@
 package integration
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +29,197 @@ func TestRunNoNetworkBlocked(t *testing.T) {
 		t.Fatalf("baseline run failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
 	}
 }
+
+func TestRunHardeningProfiles(t *testing.T) {
+	_, env := setupTest(t)
+
+	// Cold path, then the warm pool.
+	for _, noPool := range []bool{true, false} {
+		args := []string{"run", "--image", testImage, "--hardening", "strict"}
+		if noPool {
+			args = append(args, "--no-pool")
+		}
+		args = append(args, "--", "sh", "-c", "grep -E '^(NoNewPrivs|CapBnd)' /proc/self/status; ulimit -n")
+		stdout, stderr, exitCode := runBinary(t, env, args...)
+		if exitCode != 0 {
+			t.Fatalf("strict run failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+		}
+		for _, want := range []string{"NoNewPrivs:\t1", "CapBnd:\t0000000000000000", "1024"} {
+			if !strings.Contains(stdout, want) {
+				t.Fatalf("strict run output missing %q:\n%s", want, stdout)
+			}
+		}
+	}
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"run", "--image", testImage, "--no-pool", "--hardening", "default", "--",
+		"sh", "-c", "grep '^CapBnd' /proc/self/status",
+	)
+	if exitCode != 0 {
+		t.Fatalf("default run failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+	if strings.Contains(stdout, "0000000000000000") {
+		t.Fatalf("default profile dropped all capabilities:\n%s", stdout)
+	}
+}
+
+func TestRunForwardCredentials(t *testing.T) {
+	_, env := setupTest(t)
+	credentials := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(credentials, []byte("forwarded-token\n"), 0o644); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+
+	// Cold path, then the warm pool.
+	for _, noPool := range []bool{true, false} {
+		args := []string{"run", "--image", testImage, "--forward", "file=" + credentials + ",env=TOOL_CREDENTIALS"}
+		if noPool {
+			args = append(args, "--no-pool")
+		}
+		args = append(args, "--", "sh", "-c", `cat "$TOOL_CREDENTIALS" && ! touch "$TOOL_CREDENTIALS" 2>/dev/null`)
+		stdout, stderr, exitCode := runBinary(t, env, args...)
+		if exitCode != 0 || !strings.Contains(stdout, "forwarded-token") {
+			t.Fatalf("forwarded run failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+		}
+	}
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"run", "--image", testImage, "--no-pool", "--forward", "file=/nonexistent/credentials", "--", "true",
+	)
+	if exitCode != 0 || !strings.Contains(stderr, "not forwarding file=/nonexistent/credentials") {
+		t.Fatalf("expected a missing forward to be skipped (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+}
+
+func TestRunPersistentState(t *testing.T) {
+	tuprwreDir, env := setupTest(t)
+
+	// Cold path, then the warm pool, both see the first run's file.
+	for i, noPool := range []bool{true, true, false} {
+		args := []string{"run", "--image", testImage, "--state"}
+		if noPool {
+			args = append(args, "--no-pool")
+		}
+		script := `cat "$HOME/.tool-history"`
+		if i == 0 {
+			script = `test "$HOME" = /home/tuprwre && echo remembered > "$HOME/.tool-history"`
+		}
+		args = append(args, "--", "sh", "-c", script)
+		stdout, stderr, exitCode := runBinary(t, env, args...)
+		if exitCode != 0 {
+			t.Fatalf("state run %d failed (exit %d):\nstdout: %s\nstderr: %s", i, exitCode, stdout, stderr)
+		}
+		if i > 0 && !strings.Contains(stdout, "remembered") {
+			t.Fatalf("state run %d did not see persisted file:\n%s", i, stdout)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tuprwreDir, "state", "sh", ".tool-history")); err != nil {
+		t.Fatalf("expected state under the tuprwre dir: %v", err)
+	}
+}
+
+func TestRunPathPolicy(t *testing.T) {
+	_, env := setupTest(t)
+	outside := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(outside, []byte("outside-the-workspace\n"), 0o644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	stdout, stderr, exitCode := runBinary(t, env,
+		"run", "--image", testImage, "--path-policy", "mount", "--debug-io", "--", "cat", outside,
+	)
+	if exitCode != 0 || !strings.Contains(stdout, "outside-the-workspace") {
+		t.Fatalf("mounted run failed (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+	if !strings.Contains(stderr, "path-mounts") || !strings.Contains(stderr, filepath.Dir(outside)) {
+		t.Fatalf("expected the path mount in debug-io output:\n%s", stderr)
+	}
+
+	stdout, stderr, exitCode = runBinary(t, env,
+		"run", "--image", testImage, "--path-policy", "refuse", "--", "cat", outside,
+	)
+	if exitCode == 0 || !strings.Contains(stderr, "outside the mounted workspace") {
+		t.Fatalf("expected the refuse policy to fail the run (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+}
+
+func TestRunCaptureAndRecord(t *testing.T) {
+	_, env := setupTest(t)
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out.log")
+	errPath := filepath.Join(dir, "err.log")
+	recordDir := filepath.Join(dir, "record")
+
+	_, stderr, exitCode := runBinary(t, env,
+		"run", "--image", testImage, "--capture-stdout", outPath, "--capture-stderr", errPath, "--record="+recordDir, "--",
+		"sh", "-c", "echo to-stdout; echo to-stderr >&2; exit 4",
+	)
+	if exitCode != 4 {
+		t.Fatalf("expected exit code 4, got %d:\n%s", exitCode, stderr)
+	}
+	for path, want := range map[string]string{
+		outPath:                            "to-stdout\n",
+		errPath:                            "to-stderr\n",
+		filepath.Join(recordDir, "stdout"): "to-stdout\n",
+		filepath.Join(recordDir, "stderr"): "to-stderr\n",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v; want %q", path, got, err, want)
+		}
+	}
+
+	payload, err := os.ReadFile(filepath.Join(recordDir, "record.json"))
+	if err != nil {
+		t.Fatalf("read record: %v", err)
+	}
+	var rec struct {
+		Argv     []string `json:"argv"`
+		ImageID  string   `json:"image_id"`
+		ExitCode int      `json:"exit_code"`
+		Path     string   `json:"path"`
+		Mounts   []string `json:"mounts"`
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		t.Fatalf("parse record: %v", err)
+	}
+	if rec.ExitCode != 4 || rec.ImageID == "" || rec.Path == "" || len(rec.Mounts) == 0 || len(rec.Argv) != 3 || rec.Argv[0] != "sh" {
+		t.Fatalf("unexpected record:\n%s", payload)
+	}
+}
+
+func TestRunRecordAndReplay(t *testing.T) {
+	_, env := setupTest(t)
+	workspace := t.TempDir()
+	t.Chdir(workspace)
+	dataPath := filepath.Join(workspace, "data.txt")
+	if err := os.WriteFile(dataPath, []byte("recorded\n"), 0o644); err != nil {
+		t.Fatalf("write data: %v", err)
+	}
+	recordDir := filepath.Join(t.TempDir(), "record")
+
+	_, stderr, exitCode := runBinary(t, env,
+		"run", "--image", testImage, "--record="+recordDir, "--record-snapshot", "--", "cat", "data.txt",
+	)
+	if exitCode != 0 {
+		t.Fatalf("recorded run failed (exit %d):\n%s", exitCode, stderr)
+	}
+
+	// The replay reads the snapshot, not the workspace.
+	if err := os.WriteFile(dataPath, []byte("changed\n"), 0o644); err != nil {
+		t.Fatalf("rewrite data: %v", err)
+	}
+	stdout, stderr, exitCode := runBinary(t, env, "replay", recordDir)
+	if exitCode != 0 || !strings.Contains(stdout, "matches the recording") {
+		t.Fatalf("expected the replay to match (exit %d):\nstdout: %s\nstderr: %s", exitCode, stdout, stderr)
+	}
+
+	if err := os.WriteFile(filepath.Join(recordDir, "stdout"), []byte("other\n"), 0o600); err != nil {
+		t.Fatalf("tamper with record: %v", err)
+	}
+	stdout, _, exitCode = runBinary(t, env, "replay", recordDir)
+	if exitCode == 0 || !strings.Contains(stdout, "-other") || !strings.Contains(stdout, "+recorded") {
+		t.Fatalf("expected the replay to report a diff (exit %d):\n%s", exitCode, stdout)
+	}
+}